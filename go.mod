@@ -2,7 +2,10 @@ module github.com/georgecpp/mimir
 
 go 1.21.1
 
-require github.com/slack-go/slack v0.12.3
+require (
+	github.com/slack-go/slack v0.12.3
+	go.etcd.io/bbolt v1.3.7
+)
 
 require (
 	github.com/bytedance/sonic v1.9.1 // indirect
@@ -31,8 +34,6 @@ require (
 	github.com/spf13/cast v1.5.1 // indirect
 	github.com/spf13/pflag v1.0.5 // indirect
 	github.com/subosito/gotenv v1.6.0 // indirect
-	github.com/tidwall/match v1.1.1 // indirect
-	github.com/tidwall/pretty v1.2.0 // indirect
 	github.com/twitchyliquid64/golang-asm v0.15.1 // indirect
 	github.com/ugorji/go/codec v1.2.11 // indirect
 	go.uber.org/atomic v1.9.0 // indirect
@@ -50,8 +51,6 @@ require (
 
 require (
 	github.com/gin-gonic/gin v1.9.1
-	github.com/go-resty/resty/v2 v2.9.1
 	github.com/gorilla/websocket v1.5.0 // indirect
 	github.com/spf13/viper v1.17.0
-	github.com/tidwall/gjson v1.17.0
 )
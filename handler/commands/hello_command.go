@@ -1,14 +1,26 @@
 package commands
 
 import (
+	"context"
 	"fmt"
 	"time"
 
+	"github.com/georgecpp/mimir/handler/registry"
 	"github.com/slack-go/slack"
 )
 
+func init() {
+	registry.Default.Command(registry.Route{
+		Name:        "/hello",
+		Usage:       "/hello [text]",
+		Description: "Say hello to Mimir",
+	}, func(ctx context.Context, command slack.SlashCommand, client *slack.Client) (interface{}, error) {
+		return nil, HandleHelloCommand(ctx, command, client)
+	})
+}
+
 // HandleHelloCommand will take care of /hello submissions
-func HandleHelloCommand(command slack.SlashCommand, client *slack.Client) error {
+func HandleHelloCommand(ctx context.Context, command slack.SlashCommand, client *slack.Client) error {
 	// The Input is found in the text field so
 	// Create the attachment and assigned based on the message
 	attachment := slack.Attachment{}
@@ -29,9 +41,9 @@ func HandleHelloCommand(command slack.SlashCommand, client *slack.Client) error
 
 	// Send the message to the channel
 	// The Channel is available in the command.ChannelID
-	_, _, err := client.PostMessage(command.ChannelID, slack.MsgOptionAttachments(attachment))
+	_, _, err := client.PostMessageContext(ctx, command.ChannelID, slack.MsgOptionAttachments(attachment))
 	if err != nil {
 		return fmt.Errorf("failed to post message: %w", err)
 	}
 	return nil
-}
\ No newline at end of file
+}
@@ -1,9 +1,21 @@
 package commands
 
-import "github.com/slack-go/slack"
+import (
+	"context"
+
+	"github.com/georgecpp/mimir/handler/registry"
+	"github.com/slack-go/slack"
+)
+
+func init() {
+	registry.Default.Command(registry.Route{
+		Name:        "/was-this-article-helpful",
+		Description: "Ask whether an article was helpful",
+	}, HandleIsArticleGood)
+}
 
 // handleIsArticleGood will trigger a Yes or No question to the initializer
-func HandleIsArticleGood(command slack.SlashCommand, client *slack.Client) (interface{}, error) {
+func HandleIsArticleGood(ctx context.Context, command slack.SlashCommand, client *slack.Client) (interface{}, error) {
 	// Create the attachment and assigned based on the message
 	attachment := slack.Attachment{}
 
@@ -11,7 +23,7 @@ func HandleIsArticleGood(command slack.SlashCommand, client *slack.Client) (inte
 	checkbox := slack.NewCheckboxGroupsBlockElement("answer",
 		slack.NewOptionBlockObject("yes", &slack.TextBlockObject{Text: "Yes", Type: slack.MarkdownType}, &slack.TextBlockObject{Text: "Did you enjoy it?", Type: slack.MarkdownType}),
 		slack.NewOptionBlockObject("no", &slack.TextBlockObject{Text: "No", Type: slack.MarkdownType}, &slack.TextBlockObject{Text: "Did you Dislike it?", Type: slack.MarkdownType}),
-	)	
+	)
 	// Create the Accessory that will be included in the Block and add the checkbox to it
 	accessory := slack.NewAccessory(checkbox)
 	// Add blocks to the attachment
@@ -31,4 +43,4 @@ func HandleIsArticleGood(command slack.SlashCommand, client *slack.Client) (inte
 
 	attachment.Color = "#4af030"
 	return attachment, nil
-}
\ No newline at end of file
+}
@@ -1,15 +1,27 @@
 package commands
 
 import (
+	"context"
 	"fmt"
 	"math/rand"
 
+	"github.com/georgecpp/mimir/handler/registry"
 	"github.com/georgecpp/mimir/misc"
 	"github.com/slack-go/slack"
 )
 
+func init() {
+	registry.Default.Command(registry.Route{
+		Name:        "/meme",
+		Usage:       "/meme [subreddit]",
+		Description: "Post a random top meme, from a subreddit of your choice",
+	}, func(ctx context.Context, command slack.SlashCommand, client *slack.Client) (interface{}, error) {
+		return nil, HandleMemeCommand(ctx, command, client)
+	})
+}
+
 // HandleMemeCommand will take care of /meme submissions
-func HandleMemeCommand(command slack.SlashCommand, client *slack.Client) error {
+func HandleMemeCommand(ctx context.Context, command slack.SlashCommand, client *slack.Client) error {
 	var subreddit string
 
 	// Check if a subreddit was provided as an argument
@@ -21,18 +33,23 @@ func HandleMemeCommand(command slack.SlashCommand, client *slack.Client) error {
 		subreddit = subreddits[rand.Intn(len(subreddits))]
 	}
 
-	ch := make(chan string)
+	// Buffered so the fetcher never blocks once we stop waiting for it
+	ch := make(chan string, 1)
 
 	// Fetch a random meme
 	go misc.FetchMeme(subreddit, ch)
 
-	// Get the meme URL
-	memeURL := <-ch
-	close(ch)
+	// Get the meme URL, unless the request times out first
+	var memeURL string
+	select {
+	case memeURL = <-ch:
+	case <-ctx.Done():
+		return fmt.Errorf("timed out fetching a meme from subreddit %s: %w", subreddit, ctx.Err())
+	}
 
 	if memeURL != "" {
 		// Post the meme to the Slack channel
-		_, _, err := client.PostMessage(command.ChannelID, slack.MsgOptionText(memeURL, false))
+		_, _, err := client.PostMessageContext(ctx, command.ChannelID, slack.MsgOptionText(memeURL, false))
 		if err != nil {
 			return fmt.Errorf("failed to post message: %w", err)
 		}
@@ -0,0 +1,27 @@
+package commands
+
+import (
+	"context"
+
+	"github.com/georgecpp/mimir/handler/registry"
+	"github.com/georgecpp/mimir/misc"
+	"github.com/slack-go/slack"
+)
+
+func init() {
+	registry.Default.Command(registry.Route{
+		Name:        "/myqueue",
+		Description: "See and remove your requests in the DJ queue",
+	}, HandleMyQueueCommand)
+}
+
+// HandleMyQueueCommand lists the invoking user's waiting requests, each with a button that removes it
+func HandleMyQueueCommand(ctx context.Context, command slack.SlashCommand, client *slack.Client) (interface{}, error) {
+	upcoming := misc.DJ.Upcoming(misc.Shared.AccountKey(command.TeamID, command.UserID))
+
+	// Only the user who asked sees their requests
+	return slack.WebhookMessage{
+		ResponseType: slack.ResponseTypeEphemeral,
+		Blocks:       &slack.Blocks{BlockSet: misc.BuildMyQueue(upcoming, command.UserID)},
+	}, nil
+}
@@ -0,0 +1,138 @@
+package commands
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/georgecpp/mimir/handler/registry"
+	"github.com/georgecpp/mimir/misc"
+	"github.com/slack-go/slack"
+)
+
+const (
+	// defaultPlaylistLength is how many tracks /playlist create takes when not told
+	defaultPlaylistLength = 20
+	// maxPlaylistLength bounds how many tracks /playlist create puts in a playlist
+	maxPlaylistLength = 100
+)
+
+// playlistUsage explains the subcommands of /playlist
+const playlistUsage = "Usage: /playlist list, /playlist play <name> or /playlist create [number of tracks] [name]"
+
+func init() {
+	registry.Default.Command(registry.Route{
+		Name:        "/playlist",
+		Description: "List and play your playlists, or make one from the music of the channel",
+		Scopes:      []string{"playlist-read-private", "playlist-modify-public", "user-read-playback-state", "user-modify-playback-state"},
+	}, HandlePlaylistCommand)
+}
+
+// HandlePlaylistCommand runs /playlist list, /playlist play <name> and /playlist create [n] [name]
+// on the invoking user's account
+func HandlePlaylistCommand(ctx context.Context, command slack.SlashCommand, client *slack.Client) (interface{}, error) {
+	accountKey := misc.Shared.AccountKey(command.TeamID, command.UserID)
+	spotify := misc.SpotifyForAccount(accountKey)
+	subcommand, args, _ := strings.Cut(strings.TrimSpace(command.Text), " ")
+	args = strings.TrimSpace(args)
+
+	switch strings.ToLower(subcommand) {
+	case "", "list":
+		playlists, err := spotify.GetPlaylists(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("GetPlaylists failed with error: %w", err)
+		}
+		blocks, err := misc.BuildPlaylistList(playlists)
+		if err != nil {
+			return nil, err
+		}
+		// Only the user who asked sees their playlists
+		return slack.WebhookMessage{
+			ResponseType: slack.ResponseTypeEphemeral,
+			Blocks:       &slack.Blocks{BlockSet: blocks},
+		}, nil
+
+	case "play":
+		if args == "" {
+			return nil, misc.UserError("Which playlist? " + playlistUsage)
+		}
+		playlists, err := spotify.GetPlaylists(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("GetPlaylists failed with error: %w", err)
+		}
+		playlist, ok := misc.FindPlaylist(playlists, args)
+		if !ok {
+			return nil, misc.UserError(fmt.Sprintf("You have no playlist called %q, see /playlist list.", args))
+		}
+		deviceId, err := spotify.GetActiveDevice(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("GetActiveDevice failed with error: %w", err)
+		}
+		if err := spotify.PlayURI(ctx, playlist.URI, deviceId); err != nil {
+			return nil, fmt.Errorf("PlayURI failed with error: %w", err)
+		}
+		misc.RecordAudit(misc.AuditEntry{
+			At:          time.Now(),
+			Action:      misc.AuditPlay,
+			SlackUserID: command.UserID,
+			UserName:    command.UserName,
+			TeamID:      command.TeamID,
+			ChannelID:   command.ChannelID,
+			AccountKey:  accountKey,
+			Track:       playlist.Name,
+			TrackURI:    playlist.URI,
+		})
+		return slack.WebhookMessage{
+			ResponseType: slack.ResponseTypeInChannel,
+			Text:         fmt.Sprintf("▶️ <@%s> started the playlist *%s*", command.UserID, playlist.Name),
+		}, nil
+
+	case "create":
+		return createChannelPlaylist(ctx, spotify, command, args)
+	}
+	return nil, misc.UserError(fmt.Sprintf("I don't know /playlist %s. %s", subcommand, playlistUsage))
+}
+
+// createChannelPlaylist makes a playlist of the last tracks played in the channel, "[n] [name]"
+// saying how many and what to call it
+func createChannelPlaylist(ctx context.Context, spotify *misc.SpotifyClient, command slack.SlashCommand, args string) (interface{}, error) {
+	length := defaultPlaylistLength
+	if first, rest, _ := strings.Cut(args, " "); first != "" {
+		if n, err := strconv.Atoi(first); err == nil {
+			if n < 1 || n > maxPlaylistLength {
+				return nil, misc.UserError(fmt.Sprintf("A playlist can take 1 to %d tracks at once.", maxPlaylistLength))
+			}
+			length, args = n, strings.TrimSpace(rest)
+		}
+	}
+	name := args
+	if name == "" {
+		name = fmt.Sprintf("#%s — %s", command.ChannelName, time.Now().Format("2 Jan 2006"))
+	}
+
+	tracks, err := misc.ChannelTracks(command.ChannelID, length)
+	if err != nil {
+		return nil, err
+	}
+	if len(tracks) == 0 {
+		return nil, misc.UserError("I haven't seen anything play in this channel yet. Post a dashboard with /spotify and let the music play!")
+	}
+	uris := make([]string, len(tracks))
+	for i, track := range tracks {
+		uris[i] = track.TrackURI
+	}
+
+	playlist, err := spotify.CreatePlaylist(ctx, name, fmt.Sprintf("The last %d tracks played in #%s", len(tracks), command.ChannelName))
+	if err != nil {
+		return nil, fmt.Errorf("CreatePlaylist failed with error: %w", err)
+	}
+	if err := spotify.AddToPlaylist(ctx, playlist.ID, uris); err != nil {
+		return nil, fmt.Errorf("AddToPlaylist failed with error: %w", err)
+	}
+	return slack.WebhookMessage{
+		ResponseType: slack.ResponseTypeInChannel,
+		Text:         fmt.Sprintf("🎶 <@%s> made the playlist *%s* from the last %d tracks played here: %s", command.UserID, playlist.Name, len(tracks), playlist.ExternalURLs.Spotify),
+	}, nil
+}
@@ -0,0 +1,83 @@
+package commands
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/georgecpp/mimir/handler/registry"
+	"github.com/georgecpp/mimir/misc"
+	"github.com/slack-go/slack"
+)
+
+// queueAddResults is how many search results /queue-add offers
+const queueAddResults = 5
+
+func init() {
+	registry.Default.Command(registry.Route{
+		Name:        "/queue-add",
+		Usage:       "/queue-add <song, artist or album>",
+		Description: "Search Spotify and add a track to the queue",
+		Scopes:      []string{"user-modify-playback-state"},
+	}, HandleQueueAddCommand)
+}
+
+// HandleQueueAddCommand searches Spotify and shows the top tracks, each with a button that queues it
+func HandleQueueAddCommand(ctx context.Context, command slack.SlashCommand, client *slack.Client) (interface{}, error) {
+	query := strings.TrimSpace(command.Text)
+	if query == "" {
+		return nil, misc.UserError("Tell me what to look for, e.g. `/queue-add dancing queen`")
+	}
+
+	accountKey := misc.Shared.AccountKey(command.TeamID, command.UserID)
+	tracks, err := misc.SpotifyForAccount(accountKey).SearchTracks(ctx, query, queueAddResults)
+	if err != nil {
+		return nil, fmt.Errorf("SearchTracks failed with error: %w", err)
+	}
+	if len(tracks) == 0 {
+		return nil, misc.UserError(fmt.Sprintf("Spotify found nothing for \"%s\"", query))
+	}
+
+	headerText := slack.NewTextBlockObject(slack.MarkdownType, fmt.Sprintf("*🔎 Results for \"%s\"*", query), false, false)
+	blocks := []slack.Block{slack.NewSectionBlock(headerText, nil, nil)}
+	for i, track := range tracks {
+		resultBlocks, err := buildSearchResultBlocks(i, track)
+		if err != nil {
+			return nil, err
+		}
+		blocks = append(blocks, resultBlocks...)
+	}
+
+	// Only the user who searched sees the results
+	return slack.WebhookMessage{
+		ResponseType: slack.ResponseTypeEphemeral,
+		Blocks:       &slack.Blocks{BlockSet: blocks},
+	}, nil
+}
+
+// buildSearchResultBlocks renders a track with its album art and an "Add to queue" button
+func buildSearchResultBlocks(position int, track misc.SpotifyTrack) ([]slack.Block, error) {
+	choice, err := json.Marshal(misc.TrackChoice{
+		URI:    track.URI,
+		Name:   track.Name,
+		Artist: strings.Join(track.ArtistNames(), ", "),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode track choice: %w", err)
+	}
+
+	songText := slack.NewTextBlockObject(slack.MarkdownType,
+		fmt.Sprintf("*%s*\n%s\n%s · %s", track.Name, strings.Join(track.ArtistNames(), ", "), track.Album.Name, misc.FormatDuration(track.DurationMs)),
+		false, false)
+	var accessory *slack.Accessory
+	if imageURL := track.Album.ImageURL(); imageURL != "" {
+		accessory = slack.NewAccessory(slack.NewImageBlockElement(imageURL, "album logo"))
+	}
+
+	addButton := slack.NewButtonBlockElement("queue_add", string(choice), slack.NewTextBlockObject(slack.PlainTextType, "➕ Add to queue", false, false))
+	return []slack.Block{
+		slack.NewSectionBlock(songText, nil, accessory, slack.SectionBlockOptionBlockID(fmt.Sprintf("result_%d", position))),
+		slack.NewActionBlock(fmt.Sprintf("add_%d", position), addButton),
+	}, nil
+}
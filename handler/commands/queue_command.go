@@ -1,100 +1,42 @@
 package commands
 
 import (
+	"context"
 	"fmt"
+	"github.com/georgecpp/mimir/handler/registry"
 	"github.com/georgecpp/mimir/misc"
 	"github.com/slack-go/slack"
 )
 
-func HandleQueueCommand(command slack.SlashCommand, client *slack.Client) (interface{}, error) {
-	accessToken := misc.Shared.GetSpotifyAccessToken() // Retrieve the Spotify access token
-
-	// Check if the access token is set
-	if accessToken == "" {
-		// Access token is not set, return an error message
-		errorMessage := "Not connected to Spotify. Run /spotify-auth to enable this!"
-
-		attachment := slack.Attachment{
-			Color: "#FF0000", // Red color
-			Text:  errorMessage,
-		}
-
-		_, _, err := client.PostMessage(command.ChannelID, slack.MsgOptionAttachments(attachment))
-		if err != nil {
-			return nil, fmt.Errorf("failed to post message: %w", err)
-		}
+func init() {
+	registry.Default.Command(registry.Route{
+		Name:        "/queue",
+		Description: "Show what's playing and the whole Spotify queue, page by page",
+		Scopes:      []string{"user-read-playback-state", "user-read-currently-playing"},
+	}, HandleQueueCommand)
+}
 
-		return nil, nil
-	}
+func HandleQueueCommand(ctx context.Context, command slack.SlashCommand, client *slack.Client) (interface{}, error) {
+	// Resolve the Spotify account of the invoking user, or the shared party account.
+	// The registry's auth middleware already made sure it is connected.
+	accountKey := misc.Shared.AccountKey(command.TeamID, command.UserID)
+	spotify := misc.SpotifyForAccount(accountKey)
 
-	// Get the currently playing track nice and tidy.
-	_, err := misc.GetCurrentPlayingTrack()
+	// Nothing playing, no active device and the like are rendered for the user by misc.BuildErrorAttachment
+	queue, err := spotify.CurrentQueue(ctx)
 	if err != nil {
-		if err.Error() == "no currently playing track" {
-			// No currently playing track, return a message
-			noTrackMessage := "No track is currently playing."
-			attachment := slack.Attachment{
-				Color: "#36a64f", // Green color
-				Text:  noTrackMessage,
-			}
-
-			_, _, err := client.PostMessage(command.ChannelID, slack.MsgOptionAttachments(attachment))
-			if err != nil {
-				return nil, fmt.Errorf("failed to post message: %w", err)
-			}
-
-			return nil, nil
-		}
-		if err.Error() == "failed to retrieve active device" {
-			// No currently playing track, return a message
-			noActiveDeviceMessage := "No active device is currently playing anything!"
-			attachment := slack.Attachment{
-				Color: "#36a64f", // Green color
-				Text:  noActiveDeviceMessage,
-			}
-
-			_, _, err := client.PostMessage(command.ChannelID, slack.MsgOptionAttachments(attachment))
-			if err != nil {
-				return nil, fmt.Errorf("failed to post message: %w", err)
-			}
-
-			return nil, nil
-		}
-		return nil, fmt.Errorf("GetCurrentPlayingTrack failed with error: %w", err)
-	}
-
-	myQueueData, err := misc.GetUserQueue()
-
-	headerText := slack.NewTextBlockObject("mrkdwn", "*🎶 Next in the queue 🎶*", false, false)
-	headerSection := slack.NewSectionBlock(headerText, nil, nil)
-	var queueBlocks []slack.Block
-	queueBlocks = append([]slack.Block{headerSection}, queueBlocks...)
-
-	for i, item := range myQueueData[:4] {
-		position := i + 1 // Adjust to start the count from 1 instead of 0
-		// Create the section title dynamically with the position in the queue
-		sectionTitle := slack.NewTextBlockObject("plain_text", fmt.Sprintf("Song #%d", position), false, false)
-		imageBlock := slack.NewImageBlockElement(item.AlbumLogo, "album logo")
-		// Create a text block for the song information
-		songText := fmt.Sprintf("*%s*\n%s\n%s", item.SongTitle, item.Artist, item.Duration)
-		songBlock := slack.NewTextBlockObject("mrkdwn", songText, false, false)
-
-		// Create a section block with the song's text and image blocks as fields
-		sectionBlock := slack.NewSectionBlock(sectionTitle, []*slack.TextBlockObject{songBlock}, slack.NewAccessory(imageBlock))
-
-		// Append the section block to the list of queue blocks
-		queueBlocks = append(queueBlocks, sectionBlock)
+		return nil, err
 	}
 
-	attachment := slack.Attachment{
-		Blocks: slack.Blocks{
-			BlockSet: queueBlocks,
-		},
+	attachment, err := misc.BuildQueueAttachment(queue, 0, accountKey)
+	if err != nil {
+		return nil, err
 	}
-	_, _, err = client.PostMessage(command.ChannelID, slack.MsgOptionAttachments(attachment))
+	// Posted as a regular message, the Previous and Next buttons edit it in place
+	_, _, err = client.PostMessageContext(ctx, command.ChannelID, slack.MsgOptionAttachments(attachment))
 	if err != nil {
 		return nil, fmt.Errorf("failed to post message: %w", err)
 	}
 
-	return attachment, nil
+	return nil, nil
 }
@@ -1,13 +1,13 @@
 package commands
 
 import (
-	"crypto/rand"
-	"encoding/base64"
+	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"net/http"
-	"strings"
 
+	"github.com/georgecpp/mimir/handler/registry"
 	"github.com/georgecpp/mimir/misc"
 	"github.com/slack-go/slack"
 )
@@ -18,20 +18,32 @@ type TinyURLResponse struct {
 	} `json:"data"`
 }
 
-func HandleSpotifyAuthCommand(command slack.SlashCommand, client *slack.Client) error {
+func init() {
+	registry.Default.Command(registry.Route{
+		Name:        "/spotify-auth",
+		Description: "Connect your Spotify account",
+	}, func(ctx context.Context, command slack.SlashCommand, client *slack.Client) (interface{}, error) {
+		return nil, HandleSpotifyAuthCommand(ctx, command, client)
+	})
+}
+
+func HandleSpotifyAuthCommand(ctx context.Context, command slack.SlashCommand, client *slack.Client) error {
 	config, err := misc.LoadConfig("../../")
 	if err != nil {
 		return fmt.Errorf("[spotify-auth] failed to load config: %w", err)
 	}
 
-	state, err := generateRandomString(16)
+	// Record the state with the requesting user so the callback stores the token under their account
+	accountKey := misc.Shared.AccountKey(command.TeamID, command.UserID)
+	authState, err := misc.AuthStates.Issue(accountKey, command.UserID, config.SpotifyUsePkce)
 	if err != nil {
-		return fmt.Errorf("[spotify-auth] failed to generate random state: %w", err)
+		return fmt.Errorf("[spotify-auth] failed to issue oauth state: %w", err)
 	}
 
-	scope := config.SpotifyAuthorizeScopesString
-
-	authURL := fmt.Sprintf("%s?%s", config.SpotifyAuthorizeBaseUrl, buildQueryParams(config, state, scope))
+	authURL, err := misc.BuildSpotifyAuthorizeURL(config, authState)
+	if err != nil {
+		return fmt.Errorf("[spotify-auth] failed to build authorize url: %w", err)
+	}
 
 	// Shorten the URL using TinyURL
 	shortenedURL, err := shortenURL(config, authURL)
@@ -39,9 +51,9 @@ func HandleSpotifyAuthCommand(command slack.SlashCommand, client *slack.Client)
 		return fmt.Errorf("[spotify-auth] failed to shorten URL: %w", err)
 	}
 
-	// Send the shortened URL to the user
+	// Send the shortened URL only to the user, the link authorizes their account
 	message := fmt.Sprintf("Let's go!\nClick here: %s to authenticate with Spotify and let's get this party started 🎶", shortenedURL)
-	_, _, err = client.PostMessage(command.ChannelID, slack.MsgOptionText(message, false))
+	_, err = client.PostEphemeralContext(ctx, command.ChannelID, command.UserID, slack.MsgOptionText(message, false))
 	if err != nil {
 		return fmt.Errorf("[spotify-auth] failed to post message: %w", err)
 	}
@@ -52,7 +64,12 @@ func HandleSpotifyAuthCommand(command slack.SlashCommand, client *slack.Client)
 func shortenURL(config misc.Config, longURL string) (string, error) {
 	client := &http.Client{}
 
-	payload := strings.NewReader(fmt.Sprintf(`{"url": "%s"}`, longURL)) // Construct the JSON payload
+	// Marshal the payload so the already encoded URL is not mangled by hand formatting
+	body, err := json.Marshal(map[string]string{"url": longURL})
+	if err != nil {
+		return "", err
+	}
+	payload := bytes.NewReader(body)
 
 	req, err := http.NewRequest("POST", config.TinyUrlApiCreateUrl, payload)
 
@@ -76,29 +93,3 @@ func shortenURL(config misc.Config, longURL string) (string, error) {
 
 	return response.Data.TinyURL, nil
 }
-
-func generateRandomString(length int) (string, error) {
-	b := make([]byte, length)
-	_, err := rand.Read(b)
-	if err != nil {
-		return "", err
-	}
-	return base64.URLEncoding.EncodeToString(b), nil
-}
-
-func buildQueryParams(config misc.Config, state string, scope string) string {
-	params := map[string]string{
-		"response_type": "code",
-		"client_id":     config.SpotifyClientId,
-		"scope":         scope,
-		"redirect_uri":  config.SpotifyRedirectUri,
-		"state":         state,
-	}
-
-	var parts []string
-	for key, value := range params {
-		parts = append(parts, fmt.Sprintf("%s=%s", key, value))
-	}
-
-	return strings.Join(parts, "&")
-}
\ No newline at end of file
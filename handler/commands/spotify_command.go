@@ -1,82 +1,58 @@
 package commands
 
 import (
+	"context"
 	"fmt"
+	"github.com/georgecpp/mimir/handler/registry"
 	"github.com/georgecpp/mimir/misc"
 	"github.com/slack-go/slack"
+	"log"
 )
 
-func HandleSpotifyCommand(command slack.SlashCommand, client *slack.Client) (interface{}, error) {
-
-	accessToken := misc.Shared.GetSpotifyAccessToken() // Retrieve the Spotify access token
-	// Check if the access token is set
-	if accessToken == "" {
-		// Access token is not set, return an error message
-		errorMessage := "Not connected to Spotify. Run /spotify-auth to enable this!"
-
-		attachment := slack.Attachment{
-			Color: "#FF0000", // Red color
-			Text:  errorMessage,
-		}
-
-		_, _, err := client.PostMessage(command.ChannelID, slack.MsgOptionAttachments(attachment))
-		if err != nil {
-			return nil, fmt.Errorf("failed to post message: %w", err)
-		}
+func init() {
+	registry.Default.Command(registry.Route{
+		Name:        "/spotify",
+		Description: "Post a Spotify dashboard with playback controls",
+		Scopes:      []string{"user-read-playback-state", "user-read-currently-playing"},
+	}, HandleSpotifyCommand)
+}
 
-		return nil, nil
-	}
+func HandleSpotifyCommand(ctx context.Context, command slack.SlashCommand, client *slack.Client) (interface{}, error) {
 
-	// Get the currently playing track nice and tidy.
-	currentPlayingTrack, err := misc.GetCurrentPlayingTrack()
+	// Resolve the Spotify account of the invoking user, or the shared party account.
+	// The registry's auth middleware already made sure it is connected.
+	accountKey := misc.Shared.AccountKey(command.TeamID, command.UserID)
+	spotify := misc.SpotifyForAccount(accountKey)
+	// Get the currently playing track nice and tidy, waking the default device if nothing is active.
+	currentPlayingTrack, err := spotify.GetDashboardTrack(ctx, true)
+	// Nothing playing, no active device and the like are rendered for the user by misc.BuildErrorAttachment
 	if err != nil {
-		if err.Error() == "no currently playing track" {
-			// No currently playing track, return a message
-			noTrackMessage := "No track is currently playing."
-			attachment := slack.Attachment{
-				Color: "#36a64f", // Green color
-				Text:  noTrackMessage,
-			}
-
-			_, _, err := client.PostMessage(command.ChannelID, slack.MsgOptionAttachments(attachment))
-			if err != nil {
-				return nil, fmt.Errorf("failed to post message: %w", err)
-			}
-
-			return nil, nil
-		}
-		if err.Error() == "failed to retrieve active device" {
-			// No currently playing track, return a message
-			noActiveDeviceMessage := "No active device is currently playing anything!"
-			attachment := slack.Attachment{
-				Color: "#36a64f", // Green color
-				Text:  noActiveDeviceMessage,
-			}
-
-			_, _, err := client.PostMessage(command.ChannelID, slack.MsgOptionAttachments(attachment))
-			if err != nil {
-				return nil, fmt.Errorf("failed to post message: %w", err)
-			}
-
-			return nil, nil
-		}
-		return nil, fmt.Errorf("GetCurrentPlayingTrack failed with error: %w", err)
+		return nil, fmt.Errorf("GetDashboardTrack failed with error: %w", err)
 	}
+	currentPlayingTrack.RequestedBy = misc.QueueRequests.RequestedBy(accountKey, currentPlayingTrack.TrackURI)
 	spotifyAttachment := misc.BuildSpotifyAttachment(currentPlayingTrack, "/spotify", command.UserName)
 
 	// Post the message to the channel
-	_, slackMessageTimestamp, err := client.PostMessage(command.ChannelID, slack.MsgOptionAttachments(spotifyAttachment))
+	_, slackMessageTimestamp, err := client.PostMessageContext(ctx, command.ChannelID, slack.MsgOptionAttachments(spotifyAttachment))
 	if err != nil {
 		return nil, fmt.Errorf("failed to post message: %w", err)
 	}
 
-	misc.MySpotifyDashboard.CreateSpotifyDashboard(
+	// Only the newest dashboards of a channel stay interactive
+	_, retired := misc.Dashboards.Create(
 		currentPlayingTrack,
 		slackMessageTimestamp,
 		command.ChannelID,
+		accountKey,
+		"/spotify",
+		command.UserName,
 	)
+	for _, dashboard := range retired {
+		if err := dashboard.Retire(ctx, client, "A newer dashboard was posted below."); err != nil {
+			log.Printf("failed to retire Spotify dashboard: %v", err)
+		}
+	}
 
-	misc.StartSpotifyPolling(client)
-
-	return spotifyAttachment, nil
+	// The dashboard was posted to the channel, there is nothing to respond with
+	return nil, nil
 }
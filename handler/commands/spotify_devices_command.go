@@ -0,0 +1,34 @@
+package commands
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/georgecpp/mimir/handler/registry"
+	"github.com/georgecpp/mimir/misc"
+	"github.com/slack-go/slack"
+)
+
+func init() {
+	registry.Default.Command(registry.Route{
+		Name:        "/spotify-devices",
+		Description: "List your Spotify devices and move playback to one of them",
+		Scopes:      []string{"user-read-playback-state"},
+	}, HandleSpotifyDevicesCommand)
+}
+
+// HandleSpotifyDevicesCommand lists the devices of the invoking user's account, each with a button
+// that moves playback to it
+func HandleSpotifyDevicesCommand(ctx context.Context, command slack.SlashCommand, client *slack.Client) (interface{}, error) {
+	spotify := misc.SpotifyForAccount(misc.Shared.AccountKey(command.TeamID, command.UserID))
+	devices, err := spotify.GetDevices(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("GetDevices failed with error: %w", err)
+	}
+
+	// Only the user who asked sees the list
+	return slack.WebhookMessage{
+		ResponseType: slack.ResponseTypeEphemeral,
+		Blocks:       &slack.Blocks{BlockSet: misc.BuildDeviceList(devices, spotify.DefaultDevice())},
+	}, nil
+}
@@ -0,0 +1,67 @@
+package commands
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/georgecpp/mimir/handler/registry"
+	"github.com/georgecpp/mimir/misc"
+	"github.com/slack-go/slack"
+)
+
+// historyExportLimit bounds how many actions one /spotify-history reads
+const historyExportLimit = 5000
+
+func init() {
+	registry.Default.Command(registry.Route{
+		Name:        "/spotify-history",
+		Description: "Show or export who skipped, paused, queued and moved the music in this channel",
+	}, HandleSpotifyHistoryCommand)
+}
+
+// HandleSpotifyHistoryCommand lists the playback actions matching "[@user] [since] [csv|json]",
+// or uploads them all to the channel as a file when a format is given. It only reads the actions
+// taken in the channel it was run in, the history must not leak what happened in other channels.
+func HandleSpotifyHistoryCommand(ctx context.Context, command slack.SlashCommand, client *slack.Client) (interface{}, error) {
+	request, err := misc.ParseHistoryRequest(command.Text, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	request.Query.TeamID = command.TeamID
+	request.Query.ChannelID = command.ChannelID
+	request.Query.Limit = historyExportLimit
+	entries, err := misc.AuditTrail.Query(request.Query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the audit trail: %w", err)
+	}
+
+	if request.Format == "" {
+		// Only the user who asked sees the list
+		return slack.WebhookMessage{
+			ResponseType: slack.ResponseTypeEphemeral,
+			Blocks:       &slack.Blocks{BlockSet: misc.BuildAuditHistory(entries, request.Query)},
+		}, nil
+	}
+
+	content, err := misc.ExportAudit(entries, request.Format)
+	if err != nil {
+		return nil, err
+	}
+	filename := fmt.Sprintf("spotify-history-%s.%s", time.Now().Format("2006-01-02"), request.Format)
+	_, err = client.UploadFileContext(ctx, slack.FileUploadParameters{
+		Content:        string(content),
+		Filetype:       request.Format,
+		Filename:       filename,
+		Title:          "Spotify playback history",
+		InitialComment: fmt.Sprintf("<@%s> exported %d playback actions", command.UserID, len(entries)),
+		Channels:       []string{command.ChannelID},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to upload %s: %w", filename, err)
+	}
+	return slack.WebhookMessage{
+		ResponseType: slack.ResponseTypeEphemeral,
+		Text:         fmt.Sprintf("📎 Exported %d actions to %s", len(entries), filename),
+	}, nil
+}
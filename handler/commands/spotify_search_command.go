@@ -0,0 +1,37 @@
+package commands
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/georgecpp/mimir/handler/registry"
+	"github.com/georgecpp/mimir/misc"
+	"github.com/slack-go/slack"
+)
+
+func init() {
+	// Searching needs a login but no particular scope, the modal's buttons check their own
+	registry.Default.Command(registry.Route{
+		Name:        "/spotify-search",
+		Description: "Search Spotify as you type, then play, queue or share a result",
+	}, HandleSpotifySearchCommand)
+}
+
+// HandleSpotifySearchCommand opens the search modal for the invoking user's account,
+// sharing results to the channel the command was sent from
+func HandleSpotifySearchCommand(ctx context.Context, command slack.SlashCommand, client *slack.Client) (interface{}, error) {
+	accountKey := misc.Shared.AccountKey(command.TeamID, command.UserID)
+	if !misc.Shared.HasSpotifyToken(accountKey) {
+		return nil, misc.ErrNotAuthenticated
+	}
+
+	modal, err := misc.BuildSpotifySearchModal(misc.SearchContext{ChannelID: command.ChannelID, AccountKey: accountKey}, nil, "")
+	if err != nil {
+		return nil, err
+	}
+	if _, err := client.OpenViewContext(ctx, command.TriggerID, modal); err != nil {
+		return nil, fmt.Errorf("failed to open search modal: %w", err)
+	}
+	// The modal is the response
+	return nil, nil
+}
@@ -0,0 +1,42 @@
+package commands
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/georgecpp/mimir/handler/registry"
+	"github.com/georgecpp/mimir/misc"
+	"github.com/slack-go/slack"
+)
+
+func init() {
+	registry.Default.Command(registry.Route{
+		Name:        "/spotify-stats",
+		Description: "Show the listening report of this channel, of the past week unless told how far back to look",
+	}, HandleSpotifyStatsCommand)
+}
+
+// HandleSpotifyStatsCommand shows the listening report since "[since]", a week ago by default
+func HandleSpotifyStatsCommand(ctx context.Context, command slack.SlashCommand, client *slack.Client) (interface{}, error) {
+	now := time.Now()
+	from := now.AddDate(0, 0, -7)
+	if arg := strings.TrimSpace(command.Text); arg != "" {
+		since, ok := misc.ParseSince(arg, now)
+		if !ok {
+			return nil, misc.UserError(fmt.Sprintf("I don't understand %q. Usage: /spotify-stats [since, e.g. 3d, 4w or 2024-05-01]", arg))
+		}
+		from = since
+	}
+
+	stats, err := misc.GatherListeningStats(command.TeamID, command.ChannelID, from, now)
+	if err != nil {
+		return nil, err
+	}
+	// Only the user who asked sees the report, the weekly one goes to the whole channel
+	return slack.WebhookMessage{
+		ResponseType: slack.ResponseTypeEphemeral,
+		Blocks:       &slack.Blocks{BlockSet: misc.BuildListeningReport(stats)},
+	}, nil
+}
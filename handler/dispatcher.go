@@ -0,0 +1,114 @@
+package handler
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/slack-go/slack"
+)
+
+// Dispatcher runs handlers on a bounded pool of workers so the socket mode
+// listener can acknowledge Slack right away and never blocks on Spotify or Reddit
+type Dispatcher struct {
+	jobs    chan func(ctx context.Context)
+	timeout time.Duration
+	ctx     context.Context
+	wg      sync.WaitGroup
+}
+
+// NewDispatcher starts workers goroutines that take jobs from a queue of queueSize,
+// each job gets a context derived from ctx that times out after timeout
+func NewDispatcher(ctx context.Context, workers int, queueSize int, timeout time.Duration) *Dispatcher {
+	d := &Dispatcher{
+		jobs:    make(chan func(ctx context.Context), queueSize),
+		timeout: timeout,
+		ctx:     ctx,
+	}
+	for i := 0; i < workers; i++ {
+		d.wg.Add(1)
+		go d.work()
+	}
+	return d
+}
+
+func (d *Dispatcher) work() {
+	defer d.wg.Done()
+	for job := range d.jobs {
+		d.run(job)
+	}
+}
+
+// run executes one job, a panicking job must not take its worker down with it
+func (d *Dispatcher) run(job func(ctx context.Context)) {
+	ctx, cancel := context.WithTimeout(d.ctx, d.timeout)
+	defer cancel()
+	defer func() {
+		if r := recover(); r != nil {
+			log.Printf("recovered from panic in worker: %v", r)
+		}
+	}()
+	job(ctx)
+}
+
+// Submit queues a job, returning false when every worker is busy and the queue is full
+func (d *Dispatcher) Submit(job func(ctx context.Context)) bool {
+	select {
+	case d.jobs <- job:
+		return true
+	default:
+		return false
+	}
+}
+
+// Shutdown stops accepting jobs and waits for the queued ones to finish
+func (d *Dispatcher) Shutdown() {
+	close(d.jobs)
+	d.wg.Wait()
+}
+
+// Respond delivers a handler's payload after the request was acknowledged,
+// through the response_url when Slack gave us one and chat.postMessage otherwise
+func Respond(ctx context.Context, client *slack.Client, responseURL string, channelID string, payload interface{}) error {
+	if payload == nil {
+		return nil
+	}
+
+	var msg slack.WebhookMessage
+	switch p := payload.(type) {
+	case slack.Attachment:
+		msg.Attachments = []slack.Attachment{p}
+	case slack.WebhookMessage:
+		msg = p
+	case string:
+		msg.Text = p
+	default:
+		return fmt.Errorf("cannot deliver payload of type %T", payload)
+	}
+
+	if responseURL != "" {
+		if err := slack.PostWebhookContext(ctx, responseURL, &msg); err != nil {
+			return fmt.Errorf("failed to post to response_url: %w", err)
+		}
+		return nil
+	}
+
+	if channelID == "" {
+		log.Printf("dropping payload without response_url or channel: %v", payload)
+		return nil
+	}
+	options := []slack.MsgOption{
+		slack.MsgOptionText(msg.Text, false),
+		slack.MsgOptionAttachments(msg.Attachments...),
+	}
+	if msg.Blocks != nil {
+		options = append(options, slack.MsgOptionBlocks(msg.Blocks.BlockSet...))
+	}
+	_, _, err := client.PostMessageContext(ctx, channelID, options...)
+	if err != nil {
+		return fmt.Errorf("failed to post message: %w", err)
+	}
+	return nil
+}
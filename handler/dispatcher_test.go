@@ -0,0 +1,93 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/slack-go/slack"
+)
+
+func TestDispatcherRejectsWhenSaturated(t *testing.T) {
+	d := NewDispatcher(context.Background(), 1, 1, time.Second)
+	release := make(chan struct{})
+	started := make(chan struct{})
+
+	if !d.Submit(func(ctx context.Context) { close(started); <-release }) {
+		t.Fatal("first job rejected")
+	}
+	<-started
+	if !d.Submit(func(ctx context.Context) {}) {
+		t.Fatal("queued job rejected")
+	}
+	if d.Submit(func(ctx context.Context) {}) {
+		t.Error("job accepted although the worker is busy and the queue is full")
+	}
+
+	close(release)
+	d.Shutdown()
+}
+
+func TestDispatcherJobsTimeOut(t *testing.T) {
+	d := NewDispatcher(context.Background(), 1, 1, 10*time.Millisecond)
+	done := make(chan error, 1)
+	d.Submit(func(ctx context.Context) {
+		<-ctx.Done()
+		done <- ctx.Err()
+	})
+
+	select {
+	case err := <-done:
+		if err != context.DeadlineExceeded {
+			t.Errorf("ctx.Err() = %v, want DeadlineExceeded", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("job context never timed out")
+	}
+	d.Shutdown()
+}
+
+func TestRespondUsesResponseURL(t *testing.T) {
+	received := make(chan slack.WebhookMessage, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var msg slack.WebhookMessage
+		json.NewDecoder(r.Body).Decode(&msg)
+		received <- msg
+	}))
+	defer server.Close()
+
+	attachment := slack.Attachment{Text: "Did you think this article was helpful?"}
+	if err := Respond(context.Background(), nil, server.URL, "C1", attachment); err != nil {
+		t.Fatalf("Respond returned error: %v", err)
+	}
+
+	msg := <-received
+	if len(msg.Attachments) != 1 || msg.Attachments[0].Text != attachment.Text {
+		t.Errorf("response_url got %+v", msg)
+	}
+}
+
+func TestRespondPostsBlocksWithoutResponseURL(t *testing.T) {
+	received := make(chan string, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		received <- r.Form.Get("blocks")
+		w.Write([]byte(`{"ok":true,"channel":"C1","ts":"1.1"}`))
+	}))
+	defer server.Close()
+	client := slack.New("xoxb-test", slack.OptionAPIURL(server.URL+"/"))
+
+	header := slack.NewSectionBlock(slack.NewTextBlockObject(slack.MarkdownType, "*📜 Your Spotify playlists*", false, false), nil, nil)
+	msg := slack.WebhookMessage{Blocks: &slack.Blocks{BlockSet: []slack.Block{header}}}
+	if err := Respond(context.Background(), client, "", "C1", msg); err != nil {
+		t.Fatalf("Respond returned error: %v", err)
+	}
+
+	if blocks := <-received; !strings.Contains(blocks, "Your Spotify playlists") {
+		t.Errorf("chat.postMessage got blocks %q, want the handler's blocks", blocks)
+	}
+}
@@ -0,0 +1,119 @@
+package handler
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"runtime/debug"
+	"time"
+
+	"github.com/georgecpp/mimir/misc"
+	"github.com/slack-go/slack"
+	"github.com/slack-go/slack/slackevents"
+)
+
+// Request describes what triggered a handler, for logging and for replying to the user
+type Request struct {
+	Type        string // "command", "interaction" or "event"
+	Name        string // e.g. "/spotify", "skip_next", "app_mention"
+	UserID      string
+	ChannelID   string
+	ResponseURL string
+}
+
+func (r Request) String() string {
+	return fmt.Sprintf("%s %s user=%s channel=%s", r.Type, r.Name, r.UserID, r.ChannelID)
+}
+
+// Execute runs a handler, delivers its payload and turns errors and panics into
+// a log line plus an ephemeral reply, so one failed request never takes the bot down
+func Execute(ctx context.Context, client *slack.Client, req Request, handle func(ctx context.Context) (interface{}, error)) {
+	payload, err := recoverHandler(ctx, handle)
+	if err != nil {
+		ReportError(ctx, client, req, err)
+		return
+	}
+	if err := Respond(ctx, client, req.ResponseURL, req.ChannelID, payload); err != nil {
+		log.Printf("[%s] failed to respond: %v", req, err)
+	}
+}
+
+// recoverHandler calls handle, converting a panic into an internal error
+func recoverHandler(ctx context.Context, handle func(ctx context.Context) (interface{}, error)) (payload interface{}, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic: %v\n%s", r, debug.Stack())
+		}
+	}()
+	return handle(ctx)
+}
+
+// ReportError logs err with the request it belongs to and tells the user what went wrong
+func ReportError(ctx context.Context, client *slack.Client, req Request, err error) {
+	kind := misc.KindOf(err)
+	log.Printf("[%s] %s error: %v", req, kind, err)
+
+	// The request context may be what timed out, the reply still deserves a chance
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), 5*time.Second)
+	defer cancel()
+
+	if replyErr := replyEphemeral(ctx, client, req, misc.BuildErrorAttachment(err)); replyErr != nil {
+		log.Printf("[%s] failed to send error reply: %v", req, replyErr)
+	}
+}
+
+// replyEphemeral shows attachment only to the user who triggered the request
+func replyEphemeral(ctx context.Context, client *slack.Client, req Request, attachment slack.Attachment) error {
+	if req.ResponseURL != "" {
+		return slack.PostWebhookContext(ctx, req.ResponseURL, &slack.WebhookMessage{
+			Attachments:     []slack.Attachment{attachment},
+			ResponseType:    slack.ResponseTypeEphemeral,
+			ReplaceOriginal: false,
+		})
+	}
+	if req.ChannelID == "" || req.UserID == "" {
+		return nil
+	}
+	_, err := client.PostEphemeralContext(ctx, req.ChannelID, req.UserID, slack.MsgOptionAttachments(attachment))
+	return err
+}
+
+// EventRequest describes an Events API event for Execute
+func EventRequest(event slackevents.EventsAPIEvent) Request {
+	req := Request{Type: "event", Name: event.Type}
+	switch ev := event.InnerEvent.Data.(type) {
+	case *slackevents.AppMentionEvent:
+		req.Name = ev.Type
+		req.UserID = ev.User
+		req.ChannelID = ev.Channel
+	}
+	return req
+}
+
+// CommandRequest describes a slash command for Execute
+func CommandRequest(command slack.SlashCommand) Request {
+	return Request{
+		Type:        "command",
+		Name:        command.Command,
+		UserID:      command.UserID,
+		ChannelID:   command.ChannelID,
+		ResponseURL: command.ResponseURL,
+	}
+}
+
+// InteractionRequest describes an interaction callback for Execute
+func InteractionRequest(interaction slack.InteractionCallback) Request {
+	name := string(interaction.Type)
+	if len(interaction.ActionCallback.BlockActions) > 0 {
+		name = interaction.ActionCallback.BlockActions[0].ActionID
+	} else if interaction.ActionID != "" {
+		name = interaction.ActionID
+	}
+	return Request{
+		Type:        "interaction",
+		Name:        name,
+		UserID:      interaction.User.ID,
+		ChannelID:   interaction.Channel.ID,
+		ResponseURL: interaction.ResponseURL,
+	}
+}
@@ -0,0 +1,76 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/georgecpp/mimir/misc"
+	"github.com/slack-go/slack"
+)
+
+func newResponseURL(t *testing.T) (string, chan slack.WebhookMessage) {
+	t.Helper()
+	received := make(chan slack.WebhookMessage, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var msg slack.WebhookMessage
+		json.NewDecoder(r.Body).Decode(&msg)
+		received <- msg
+	}))
+	t.Cleanup(server.Close)
+	return server.URL, received
+}
+
+func TestExecuteRepliesWithClassifiedErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want string
+	}{
+		{"user", misc.UserError("No track is currently playing."), "No track is currently playing."},
+		{"upstream", fmt.Errorf("skip: %w", misc.UpstreamError("Spotify", errors.New("502"))), "Spotify is not responding"},
+		{"internal", errors.New("nil map"), "something went wrong on my side"},
+		{"timeout", fmt.Errorf("meme: %w", context.DeadlineExceeded), "took too long"},
+		{"nothing playing", fmt.Errorf("GetCurrentPlayingTrack: %w", misc.ErrNothingPlaying), "No track is currently playing."},
+		{"rate limited", &misc.RateLimitError{RetryAfter: 3 * time.Second}, "try again in 3s"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			url, received := newResponseURL(t)
+			req := Request{Type: "command", Name: "/test", ResponseURL: url}
+
+			Execute(context.Background(), nil, req, func(ctx context.Context) (interface{}, error) {
+				return nil, tt.err
+			})
+
+			msg := <-received
+			if msg.ResponseType != slack.ResponseTypeEphemeral {
+				t.Errorf("response_type = %q, want ephemeral", msg.ResponseType)
+			}
+			if len(msg.Attachments) != 1 || !strings.Contains(msg.Attachments[0].Text, tt.want) {
+				t.Errorf("reply %+v does not mention %q", msg.Attachments, tt.want)
+			}
+		})
+	}
+}
+
+func TestExecuteRecoversFromPanics(t *testing.T) {
+	url, received := newResponseURL(t)
+	req := Request{Type: "interaction", Name: "skip_next", ResponseURL: url}
+
+	Execute(context.Background(), nil, req, func(ctx context.Context) (interface{}, error) {
+		var actions []slack.BlockAction
+		_ = actions[0]
+		return nil, nil
+	})
+
+	if msg := <-received; len(msg.Attachments) != 1 || !strings.Contains(msg.Attachments[0].Text, "something went wrong") {
+		t.Errorf("unexpected reply after panic: %+v", msg.Attachments)
+	}
+}
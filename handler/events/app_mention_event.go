@@ -1,18 +1,33 @@
 package events
 
 import (
+	"context"
 	"fmt"
 	"strings"
 	"time"
 
+	"github.com/georgecpp/mimir/handler/registry"
 	"github.com/slack-go/slack"
 	"github.com/slack-go/slack/slackevents"
 )
 
+func init() {
+	registry.Default.Event(registry.Route{
+		Name:        "app_mention",
+		Description: "Greet whoever mentions Mimir",
+	}, func(ctx context.Context, event slackevents.EventsAPIEvent, client *slack.Client) (interface{}, error) {
+		ev, ok := event.InnerEvent.Data.(*slackevents.AppMentionEvent)
+		if !ok {
+			return nil, fmt.Errorf("unexpected app_mention payload: %T", event.InnerEvent.Data)
+		}
+		return nil, HandleAppMentionEvent(ctx, ev, client)
+	})
+}
+
 // handleAppMentionEvent is used to take care of the AppMentionEvent when the bot is mentioned
-func HandleAppMentionEvent(event *slackevents.AppMentionEvent, client *slack.Client) error {
+func HandleAppMentionEvent(ctx context.Context, event *slackevents.AppMentionEvent, client *slack.Client) error {
 	// Grab the user name based on the ID
-	user, err := client.GetUserInfo(event.User)
+	user, err := client.GetUserInfoContext(ctx, event.User)
 	if err != nil {
 		return err
 	}
@@ -42,9 +57,9 @@ func HandleAppMentionEvent(event *slackevents.AppMentionEvent, client *slack.Cli
 	}
 	// Send the message to the channel
 	// The Channel is available in the event message
-	_, _, err = client.PostMessage(event.Channel, slack.MsgOptionAttachments(attachment))
+	_, _, err = client.PostMessageContext(ctx, event.Channel, slack.MsgOptionAttachments(attachment))
 	if err != nil {
 		return fmt.Errorf("failed to post message: %w", err)
 	}
 	return nil
-}
\ No newline at end of file
+}
@@ -1,66 +1,102 @@
 package handler
 
 import (
-	"errors"
-	"github.com/georgecpp/mimir/handler/commands"
-	"github.com/georgecpp/mimir/handler/events"
-	"github.com/georgecpp/mimir/handler/interactions"
+	"context"
+	"log"
+	"time"
+
+	// The handler packages register their routes with registry.Default when imported
+	_ "github.com/georgecpp/mimir/handler/commands"
+	_ "github.com/georgecpp/mimir/handler/events"
+	_ "github.com/georgecpp/mimir/handler/interactions"
+	"github.com/georgecpp/mimir/handler/registry"
+	"github.com/georgecpp/mimir/misc"
 	"github.com/slack-go/slack"
 	"github.com/slack-go/slack/slackevents"
 )
 
-// HandleEventMessage will take an event and handle it properly based on the type of event
-func HandleEventMessage(event slackevents.EventsAPIEvent, client *slack.Client) (interface{}, error) {
-	switch event.Type {
-	// first we check if this is an CallbackEvent
-	case slackevents.CallbackEvent:
-		innerEvent := event.InnerEvent
-		// Yet another type switch on the actual Data to see if its an AppMentionEvent
-		switch ev := innerEvent.Data.(type) {
-		case *slackevents.AppMentionEvent:
-			// The application has been mentioned since this Event is a Mention event
-			return nil, events.HandleAppMentionEvent(ev, client)
+// Each user may send this many requests per rateLimitWindow
+const (
+	rateLimitRequests = 10
+	rateLimitWindow   = 10 * time.Second
+)
+
+// suggestionTimeout is how long after Slack asked for options we may take to answer,
+// leaving room to acknowledge within Slack's 3 seconds
+const suggestionTimeout = 2500 * time.Millisecond
+
+func init() {
+	registry.Default.Use(
+		registry.Logging(),
+		registry.Recover(),
+		registry.RateLimit(rateLimitRequests, rateLimitWindow),
+		trackActivity(),
+		registry.SpotifyAuth(resolveSpotifyAccount),
+	)
+}
+
+// trackActivity records who uses the bot in which channel, skip votes are counted against them
+func trackActivity() registry.Middleware {
+	return func(next registry.HandlerFunc) registry.HandlerFunc {
+		return func(ctx context.Context, req *registry.Request) (interface{}, error) {
+			if req.ChannelID != "" && req.UserID != "" && req.Route.Kind != registry.KindBlockSuggestion {
+				misc.ChannelActivity.Seen(req.ChannelID, req.UserID, time.Now())
+			}
+			return next(ctx, req)
 		}
-	default:
-		return nil, errors.New("unsupported event type")
 	}
-	return nil, nil
 }
 
-// HandleSlashCommand will take a slash command and route to the appropriate function
-func HandleSlashCommand(command slack.SlashCommand, client *slack.Client) (interface{}, error) {
-	// We need to switch depending on the command
-	switch command.Command {
-	case "/hello":
-		// This was a hello command, so pass it along to the proper function
-		return nil, commands.HandleHelloCommand(command, client)
-	case "/was-this-article-helpful":
-		return commands.HandleIsArticleGood(command, client)
-	case "/meme":
-		return nil, commands.HandleMemeCommand(command, client)
-	case "/spotify-auth":
-		return nil, commands.HandleSpotifyAuthCommand(command, client)
-	case "/spotify":
-		return commands.HandleSpotifyCommand(command, client)
-	case "/queue":
-		return commands.HandleQueueCommand(command, client)
+// resolveSpotifyAccount returns the account a request acts on: dashboard buttons control the
+// account of whoever posted the dashboard, the search modal and the /queue pages the account
+// they were opened for, everything else the invoking user's
+func resolveSpotifyAccount(req *registry.Request) string {
+	if req.Interaction != nil && req.Interaction.View.CallbackID == misc.SpotifySearchCallbackID {
+		if search, err := misc.SearchContextOf(req.Interaction.View); err == nil && search.AccountKey != "" {
+			return search.AccountKey
+		}
 	}
+	if req.Route.Kind == registry.KindBlockAction {
+		if page, err := misc.QueuePageOf(*req.Interaction); err == nil && page.AccountKey != "" {
+			return page.AccountKey
+		}
+		if dashboard, err := misc.Dashboards.ForInteraction(*req.Interaction); err == nil {
+			return dashboard.GetAccountKey()
+		}
+	}
+	return misc.Shared.AccountKey(req.TeamID, req.UserID)
+}
+
+// HandleEventMessage will take an event and handle it properly based on the type of event
+func HandleEventMessage(ctx context.Context, event slackevents.EventsAPIEvent, client *slack.Client) (interface{}, error) {
+	return registry.Default.DispatchEvent(ctx, event, client)
+}
 
-	return nil, nil
+// HandleSlashCommand will take a slash command and route to the appropriate function
+func HandleSlashCommand(ctx context.Context, command slack.SlashCommand, client *slack.Client) (interface{}, error) {
+	return registry.Default.DispatchCommand(ctx, command, client)
+}
+
+// HandleInteractionEvent will take a block action or view submission and route it to the appropriate function
+func HandleInteractionEvent(ctx context.Context, interaction slack.InteractionCallback, client *slack.Client) (interface{}, error) {
+	return registry.Default.DispatchInteraction(ctx, interaction, client)
 }
 
-func HandleInteractionEvent(interaction slack.InteractionCallback, client *slack.Client) (interface{}, error) {
-	// This is where we would handle the interaction
-	// Switch depending on the Type
-	switch interaction.ActionCallback.BlockActions[0].ActionID {
-	case "skip_next":
-		return interactions.HandleSkipNextInteraction(interaction, client)
-	case "skip_previous":
-		return interactions.HandleSkipPreviousInteraction(interaction, client)
-	case "pause":
-		return interactions.HandlePlayPauseInteraction(interaction, client)
-	case "play":
-		return interactions.HandlePlayPauseInteraction(interaction, client)
+// HandleBlockSuggestion answers an external select asking for options. The options travel in the
+// acknowledgement, so failures are logged and answered with no options rather than reported.
+func HandleBlockSuggestion(ctx context.Context, interaction slack.InteractionCallback, client *slack.Client, received time.Time) interface{} {
+	ctx, cancel := context.WithDeadline(ctx, received.Add(suggestionTimeout))
+	defer cancel()
+
+	payload, err := recoverHandler(ctx, func(ctx context.Context) (interface{}, error) {
+		return registry.Default.DispatchInteraction(ctx, interaction, client)
+	})
+	if err != nil {
+		log.Printf("[%s] %s error: %v", InteractionRequest(interaction), misc.KindOf(err), err)
+		return slack.OptionsResponse{}
+	}
+	if payload == nil {
+		return slack.OptionsResponse{}
 	}
-	return nil, nil
+	return payload
 }
@@ -0,0 +1,20 @@
+package interactions
+
+import (
+	"context"
+
+	"github.com/georgecpp/mimir/handler/registry"
+	"github.com/slack-go/slack"
+)
+
+func init() {
+	registry.Default.BlockAction(registry.Route{
+		Name:        "answer",
+		Description: "Record an answer to /was-this-article-helpful",
+	}, HandleArticleFeedbackInteraction)
+}
+
+// HandleArticleFeedbackInteraction thanks the user for ticking a /was-this-article-helpful checkbox
+func HandleArticleFeedbackInteraction(ctx context.Context, interaction slack.InteractionCallback, client *slack.Client) (interface{}, error) {
+	return "Thanks for the feedback! 🙏", nil
+}
@@ -0,0 +1,30 @@
+package interactions
+
+import (
+	"context"
+
+	"github.com/georgecpp/mimir/handler/registry"
+	"github.com/georgecpp/mimir/misc"
+	"github.com/slack-go/slack"
+)
+
+func init() {
+	registry.Default.BlockAction(registry.Route{
+		Name:        misc.MyQueueRemoveActionID,
+		Description: "Remove one of your requests from the DJ queue",
+	}, HandleMyQueueRemoveInteraction)
+}
+
+// HandleMyQueueRemoveInteraction withdraws the request whose "Remove" button was clicked and refreshes the list
+func HandleMyQueueRemoveInteraction(ctx context.Context, interaction slack.InteractionCallback, client *slack.Client) (interface{}, error) {
+	accountKey := misc.Shared.AccountKey(interaction.Team.ID, interaction.User.ID)
+	if !misc.DJ.Remove(accountKey, interaction.User.ID, interaction.ActionCallback.BlockActions[0].Value) {
+		return nil, misc.UserError("That request is no longer waiting, it may already be in Spotify's queue.")
+	}
+
+	return slack.WebhookMessage{
+		ResponseType:    slack.ResponseTypeEphemeral,
+		ReplaceOriginal: true,
+		Blocks:          &slack.Blocks{BlockSet: misc.BuildMyQueue(misc.DJ.Upcoming(accountKey), interaction.User.ID)},
+	}, nil
+}
@@ -0,0 +1,21 @@
+package interactions
+
+import (
+	"context"
+
+	"github.com/georgecpp/mimir/handler/registry"
+	"github.com/slack-go/slack"
+)
+
+func init() {
+	registry.Default.BlockAction(registry.Route{
+		Name:        "open_in_spotify",
+		Description: "Open the playing track in Spotify",
+	}, HandleOpenInSpotifyInteraction)
+}
+
+// HandleOpenInSpotifyInteraction acknowledges the dashboard's link button,
+// Slack opens the link itself but still sends us the click
+func HandleOpenInSpotifyInteraction(ctx context.Context, interaction slack.InteractionCallback, client *slack.Client) (interface{}, error) {
+	return nil, nil
+}
@@ -1,34 +1,56 @@
 package interactions
 
 import (
+	"context"
 	"fmt"
+	"github.com/georgecpp/mimir/handler/registry"
 	"github.com/georgecpp/mimir/misc"
 	"github.com/slack-go/slack"
 )
 
-func HandlePlayPauseInteraction(interaction slack.InteractionCallback, client *slack.Client) (interface{}, error) {
-	var err error
-	cpt, err := misc.GetCurrentPlayingTrack()
+func init() {
+	for _, actionID := range []string{"play", "pause"} {
+		registry.Default.BlockAction(registry.Route{
+			Name:        actionID,
+			Description: "Toggle playback",
+			Scopes:      []string{"user-modify-playback-state"},
+		}, HandlePlayPauseInteraction)
+	}
+}
+
+func HandlePlayPauseInteraction(ctx context.Context, interaction slack.InteractionCallback, client *slack.Client) (interface{}, error) {
+	// The dashboard controls the account of whoever posted it
+	dashboard, err := misc.Dashboards.ForInteraction(interaction)
+	if err != nil {
+		return nil, err
+	}
+	spotify := dashboard.SpotifyClient()
+	// ▶️ on an idle account starts the default device, if one is configured
+	cpt, err := spotify.GetCurrentPlayingTrackOrWake(ctx)
 	if err != nil {
-		return nil, fmt.Errorf("[HandlePlayPauseInteraction]: GetCurrentPlayingTrack failed with error: %w", err)
+		return nil, fmt.Errorf("[HandlePlayPauseInteraction]: GetCurrentPlayingTrackOrWake failed with error: %w", err)
 	}
 	playing := cpt.IsPlaying
+	entry := dashboard.AuditEntry(misc.AuditPlay, interaction).WithTrack(cpt)
 	if playing {
-		err = misc.PauseTrack()
+		err = spotify.PauseTrack(ctx)
 		if err != nil {
 			return nil, fmt.Errorf("PauseTrack failed with error: %w", err)
 		}
+		entry.Action = misc.AuditPause
 	} else {
-		err = misc.StartResumeTrack()
+		err = spotify.StartResumeTrack(ctx)
 		if err != nil {
 			return nil, fmt.Errorf("StartResumeTrack failed with error: %w", err)
 		}
 	}
+	misc.RecordAudit(entry)
 	lastAction := interaction.ActionCallback.BlockActions[0].ActionID
 	userName := interaction.User.Name
-	spotifyAttachment, err := misc.MySpotifyDashboard.AutoUpdateCurrentSpotifyDashboard(client, lastAction, userName)
+	_, err = dashboard.AutoUpdateCurrentSpotifyDashboard(ctx, client, lastAction, userName)
 	if err != nil {
 		return nil, fmt.Errorf("AutoUpdateCurrentSpotifyDashboard failed with error: %w", err)
 	}
-	return spotifyAttachment, nil
+	// The dashboard message was updated in place, there is nothing to respond with
+	return nil, nil
 }
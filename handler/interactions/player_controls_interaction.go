@@ -0,0 +1,122 @@
+package interactions
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/georgecpp/mimir/handler/registry"
+	"github.com/georgecpp/mimir/misc"
+	"github.com/slack-go/slack"
+)
+
+// playerControl changes the player of a dashboard, cpt is what it is playing right now
+type playerControl func(ctx context.Context, dashboard *misc.SpotifyDashboard, spotify *misc.SpotifyClient, cpt misc.CurrentPlayingTrackResponse, action *slack.BlockAction) error
+
+var playerControls = map[string]struct {
+	description string
+	control     playerControl
+}{
+	"volume_down": {"Turn the volume down", changeVolume(-misc.VolumeStep)},
+	"volume_up":   {"Turn the volume up", changeVolume(misc.VolumeStep)},
+	"mute":        {"Mute the player", mute},
+	"unmute":      {"Unmute the player", unmute},
+	"shuffle":     {"Toggle shuffle", toggleShuffle},
+	"repeat":      {"Cycle the repeat mode", cycleRepeat},
+	"seek":        {"Seek within the playing track", seek},
+}
+
+func init() {
+	for actionID, pc := range playerControls {
+		registry.Default.BlockAction(registry.Route{
+			Name:        actionID,
+			Description: pc.description,
+			Scopes:      []string{"user-read-playback-state", "user-modify-playback-state"},
+		}, HandlePlayerControlInteraction)
+	}
+}
+
+// HandlePlayerControlInteraction runs a dashboard's volume, shuffle, repeat or seek control
+// and re-renders the dashboard
+func HandlePlayerControlInteraction(ctx context.Context, interaction slack.InteractionCallback, client *slack.Client) (interface{}, error) {
+	// The dashboard controls the account of whoever posted it
+	dashboard, err := misc.Dashboards.ForInteraction(interaction)
+	if err != nil {
+		return nil, err
+	}
+	action := interaction.ActionCallback.BlockActions[0]
+	pc, ok := playerControls[action.ActionID]
+	if !ok {
+		return nil, fmt.Errorf("unknown player control: %s", action.ActionID)
+	}
+
+	spotify := dashboard.SpotifyClient()
+	cpt, err := spotify.GetCurrentPlayingTrack(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("[HandlePlayerControlInteraction]: GetCurrentPlayingTrack failed with error: %w", err)
+	}
+	if err := pc.control(ctx, dashboard, spotify, cpt, action); err != nil {
+		return nil, err
+	}
+
+	_, err = dashboard.AutoUpdateCurrentSpotifyDashboard(ctx, client, action.ActionID, interaction.User.Name)
+	if err != nil {
+		return nil, fmt.Errorf("AutoUpdateCurrentSpotifyDashboard failed with error: %w", err)
+	}
+	// The dashboard message was updated in place, there is nothing to respond with
+	return nil, nil
+}
+
+func changeVolume(step int) playerControl {
+	return func(ctx context.Context, dashboard *misc.SpotifyDashboard, spotify *misc.SpotifyClient, cpt misc.CurrentPlayingTrackResponse, action *slack.BlockAction) error {
+		if cpt.VolumePercent == nil {
+			return misc.UserError(fmt.Sprintf("%s doesn't let Spotify change its volume.", cpt.DeviceName))
+		}
+		if err := spotify.SetVolume(ctx, *cpt.VolumePercent+step); err != nil {
+			return fmt.Errorf("SetVolume failed with error: %w", err)
+		}
+		return nil
+	}
+}
+
+func mute(ctx context.Context, dashboard *misc.SpotifyDashboard, spotify *misc.SpotifyClient, cpt misc.CurrentPlayingTrackResponse, action *slack.BlockAction) error {
+	if cpt.VolumePercent == nil {
+		return misc.UserError(fmt.Sprintf("%s doesn't let Spotify change its volume.", cpt.DeviceName))
+	}
+	if err := spotify.SetVolume(ctx, 0); err != nil {
+		return fmt.Errorf("SetVolume failed with error: %w", err)
+	}
+	dashboard.Mute(*cpt.VolumePercent)
+	return nil
+}
+
+func unmute(ctx context.Context, dashboard *misc.SpotifyDashboard, spotify *misc.SpotifyClient, cpt misc.CurrentPlayingTrackResponse, action *slack.BlockAction) error {
+	if err := spotify.SetVolume(ctx, dashboard.Unmute()); err != nil {
+		return fmt.Errorf("SetVolume failed with error: %w", err)
+	}
+	return nil
+}
+
+func toggleShuffle(ctx context.Context, dashboard *misc.SpotifyDashboard, spotify *misc.SpotifyClient, cpt misc.CurrentPlayingTrackResponse, action *slack.BlockAction) error {
+	if err := spotify.SetShuffle(ctx, !cpt.ShuffleState); err != nil {
+		return fmt.Errorf("SetShuffle failed with error: %w", err)
+	}
+	return nil
+}
+
+func cycleRepeat(ctx context.Context, dashboard *misc.SpotifyDashboard, spotify *misc.SpotifyClient, cpt misc.CurrentPlayingTrackResponse, action *slack.BlockAction) error {
+	if err := spotify.SetRepeat(ctx, misc.NextRepeatState(cpt.RepeatState)); err != nil {
+		return fmt.Errorf("SetRepeat failed with error: %w", err)
+	}
+	return nil
+}
+
+func seek(ctx context.Context, dashboard *misc.SpotifyDashboard, spotify *misc.SpotifyClient, cpt misc.CurrentPlayingTrackResponse, action *slack.BlockAction) error {
+	position, err := misc.SeekPosition(action.SelectedOption.Value, cpt.ProgressMs, cpt.DurationMs)
+	if err != nil {
+		return err
+	}
+	if err := spotify.SeekTo(ctx, position); err != nil {
+		return fmt.Errorf("SeekTo failed with error: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,114 @@
+package interactions
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+
+	"github.com/georgecpp/mimir/handler/registry"
+	"github.com/georgecpp/mimir/misc"
+	"github.com/slack-go/slack"
+)
+
+func init() {
+	registry.Default.BlockAction(registry.Route{
+		Name:        misc.SaveToPlaylistActionID,
+		Description: "Save the dashboard's track to the team playlist",
+		Scopes:      []string{"user-read-playback-state", "playlist-modify-public"},
+	}, HandleSaveToPlaylistInteraction)
+	registry.Default.BlockAction(registry.Route{
+		Name:        misc.PlaylistPlayActionID,
+		Description: "Play a playlist listed by /playlist list",
+		Scopes:      []string{"user-read-playback-state", "user-modify-playback-state"},
+	}, HandlePlaylistPlayInteraction)
+}
+
+// playlistSaves serializes the saves to each playlist, so two clicks at once can't both find
+// the track missing and add it twice
+var playlistSaves = struct {
+	locks map[string]*sync.Mutex
+	mutex sync.Mutex
+}{locks: map[string]*sync.Mutex{}}
+
+// lockPlaylist holds the save lock of playlistId until the returned function is called
+func lockPlaylist(playlistId string) func() {
+	playlistSaves.mutex.Lock()
+	lock, ok := playlistSaves.locks[playlistId]
+	if !ok {
+		lock = &sync.Mutex{}
+		playlistSaves.locks[playlistId] = lock
+	}
+	playlistSaves.mutex.Unlock()
+
+	lock.Lock()
+	return lock.Unlock
+}
+
+// HandleSaveToPlaylistInteraction appends the track playing on the dashboard's account to the team playlist,
+// unless it is in there already
+func HandleSaveToPlaylistInteraction(ctx context.Context, interaction slack.InteractionCallback, client *slack.Client) (interface{}, error) {
+	if misc.TeamPlaylist == "" {
+		return nil, misc.UserError("There is no team playlist configured.")
+	}
+	// The dashboard controls the account of whoever posted it
+	dashboard, err := misc.Dashboards.ForInteraction(interaction)
+	if err != nil {
+		return nil, err
+	}
+	spotify := dashboard.SpotifyClient()
+	cpt, err := spotify.GetCurrentPlayingTrack(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("[HandleSaveToPlaylistInteraction]: GetCurrentPlayingTrack failed with error: %w", err)
+	}
+	if cpt.TrackURI == "" {
+		return nil, misc.UserError("Nothing is playing, there is no track to save.")
+	}
+	unlock := lockPlaylist(misc.TeamPlaylist)
+	defer unlock()
+	saved, err := spotify.PlaylistContains(ctx, misc.TeamPlaylist, cpt.TrackURI)
+	if err != nil {
+		return nil, fmt.Errorf("PlaylistContains failed with error: %w", err)
+	}
+	if saved {
+		return slack.WebhookMessage{
+			ResponseType: slack.ResponseTypeEphemeral,
+			Text:         fmt.Sprintf("💾 *%s* by %s is already in the team playlist", cpt.Song, cpt.Artist),
+		}, nil
+	}
+	if err := spotify.AddToPlaylist(ctx, misc.TeamPlaylist, []string{cpt.TrackURI}); err != nil {
+		return nil, fmt.Errorf("AddToPlaylist failed with error: %w", err)
+	}
+	misc.RecordAudit(misc.NewAuditEntry(misc.AuditSave, interaction, dashboard.GetAccountKey()).WithTrack(cpt))
+
+	// Only the clicker is told, the dashboard itself doesn't change
+	return slack.WebhookMessage{
+		ResponseType: slack.ResponseTypeEphemeral,
+		Text:         fmt.Sprintf("💾 Saved *%s* by %s to the team playlist", cpt.Song, cpt.Artist),
+	}, nil
+}
+
+// HandlePlaylistPlayInteraction starts the playlist whose "Play" button was clicked on the user's account
+func HandlePlaylistPlayInteraction(ctx context.Context, interaction slack.InteractionCallback, client *slack.Client) (interface{}, error) {
+	var choice misc.TrackChoice
+	if err := json.Unmarshal([]byte(interaction.ActionCallback.BlockActions[0].Value), &choice); err != nil {
+		return nil, fmt.Errorf("failed to decode playlist choice: %w", err)
+	}
+	accountKey := misc.Shared.AccountKey(interaction.Team.ID, interaction.User.ID)
+	spotify := misc.SpotifyForAccount(accountKey)
+	deviceId, err := spotify.GetActiveDevice(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("GetActiveDevice failed with error: %w", err)
+	}
+	if err := spotify.PlayURI(ctx, choice.URI, deviceId); err != nil {
+		return nil, fmt.Errorf("PlayURI failed with error: %w", err)
+	}
+	entry := misc.NewAuditEntry(misc.AuditPlay, interaction, accountKey)
+	entry.Track, entry.TrackURI = choice.Name, choice.URI
+	misc.RecordAudit(entry)
+
+	return slack.WebhookMessage{
+		ResponseType: slack.ResponseTypeEphemeral,
+		Text:         fmt.Sprintf("▶️ Now playing the playlist *%s*", choice.Name),
+	}, nil
+}
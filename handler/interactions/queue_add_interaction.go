@@ -0,0 +1,52 @@
+package interactions
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/georgecpp/mimir/handler/registry"
+	"github.com/georgecpp/mimir/misc"
+	"github.com/slack-go/slack"
+)
+
+func init() {
+	registry.Default.BlockAction(registry.Route{
+		Name:        "queue_add",
+		Description: "Add a /queue-add search result to the DJ queue",
+		Scopes:      []string{"user-read-playback-state", "user-modify-playback-state"},
+	}, HandleQueueAddInteraction)
+}
+
+// HandleQueueAddInteraction puts the chosen track in the DJ queue, which hands it to Spotify when it is
+// the requester's turn
+func HandleQueueAddInteraction(ctx context.Context, interaction slack.InteractionCallback, client *slack.Client) (interface{}, error) {
+	var choice misc.TrackChoice
+	if err := json.Unmarshal([]byte(interaction.ActionCallback.BlockActions[0].Value), &choice); err != nil {
+		return nil, fmt.Errorf("failed to decode track choice: %w", err)
+	}
+
+	request := misc.QueueRequest{
+		AccountKey:  misc.Shared.AccountKey(interaction.Team.ID, interaction.User.ID),
+		TrackURI:    choice.URI,
+		TrackName:   fmt.Sprintf("%s by %s", choice.Name, choice.Artist),
+		SlackUserID: interaction.User.ID,
+		UserName:    interaction.User.Name,
+		RequestedAt: time.Now(),
+	}
+	position, err := misc.DJ.Request(request)
+	if err != nil {
+		return nil, err
+	}
+	entry := misc.NewAuditEntry(misc.AuditQueueAdd, interaction, request.AccountKey)
+	entry.Track, entry.TrackURI = request.TrackName, request.TrackURI
+	misc.RecordAudit(entry)
+
+	// Replace the search results with the confirmation
+	return slack.WebhookMessage{
+		ResponseType:    slack.ResponseTypeEphemeral,
+		ReplaceOriginal: true,
+		Text:            fmt.Sprintf("✅ Requested *%s* by %s, it is #%d in the DJ queue. See /myqueue.", choice.Name, choice.Artist, position),
+	}, nil
+}
@@ -0,0 +1,47 @@
+package interactions
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/georgecpp/mimir/handler/registry"
+	"github.com/georgecpp/mimir/misc"
+	"github.com/slack-go/slack"
+)
+
+func init() {
+	for _, actionID := range []string{misc.QueuePreviousActionID, misc.QueueNextActionID} {
+		registry.Default.BlockAction(registry.Route{
+			Name:        actionID,
+			Description: "Page through the /queue message",
+			Scopes:      []string{"user-read-playback-state", "user-read-currently-playing"},
+		}, HandleQueuePageInteraction)
+	}
+}
+
+// HandleQueuePageInteraction re-renders the /queue message on the page its button leads to,
+// with the queue as it is now
+func HandleQueuePageInteraction(ctx context.Context, interaction slack.InteractionCallback, client *slack.Client) (interface{}, error) {
+	page, err := misc.QueuePageOf(interaction)
+	if err != nil {
+		return nil, err
+	}
+	queue, err := misc.SpotifyForAccount(page.AccountKey).CurrentQueue(ctx)
+	if err != nil {
+		return nil, err
+	}
+	attachment, err := misc.BuildQueueAttachment(queue, page.Page, page.AccountKey)
+	if err != nil {
+		return nil, err
+	}
+
+	channelId, timestamp := interaction.Container.ChannelID, interaction.Container.MessageTs
+	if channelId == "" || timestamp == "" {
+		channelId, timestamp = interaction.Channel.ID, interaction.Message.Timestamp
+	}
+	if _, _, _, err := client.UpdateMessageContext(ctx, channelId, timestamp, slack.MsgOptionAttachments(attachment)); err != nil {
+		return nil, fmt.Errorf("failed to update queue message: %w", err)
+	}
+	// The queue message was updated in place, there is nothing to respond with
+	return nil, nil
+}
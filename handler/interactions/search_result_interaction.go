@@ -0,0 +1,111 @@
+package interactions
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/georgecpp/mimir/handler/registry"
+	"github.com/georgecpp/mimir/misc"
+	"github.com/slack-go/slack"
+)
+
+func init() {
+	registry.Default.BlockAction(registry.Route{
+		Name:        "search_play",
+		Description: "Play a search result now",
+		Scopes:      []string{"user-read-playback-state", "user-modify-playback-state"},
+	}, HandleSearchPlayInteraction)
+	registry.Default.BlockAction(registry.Route{
+		Name:        "search_queue",
+		Description: "Add a search result to the DJ queue",
+		Scopes:      []string{"user-read-playback-state", "user-modify-playback-state"},
+	}, HandleSearchQueueInteraction)
+	registry.Default.BlockAction(registry.Route{
+		Name:        "search_share",
+		Description: "Share a search result to the channel",
+	}, HandleSearchShareInteraction)
+}
+
+// HandleSearchPlayInteraction plays the picked result on the active device of the modal's account
+func HandleSearchPlayInteraction(ctx context.Context, interaction slack.InteractionCallback, client *slack.Client) (interface{}, error) {
+	return handleSearchResult(ctx, client, interaction, func(search misc.SearchContext, choice misc.TrackChoice) (string, error) {
+		spotify := misc.SpotifyForAccount(search.AccountKey)
+		deviceId, err := spotify.GetActiveDevice(ctx)
+		if err != nil {
+			return "", fmt.Errorf("GetActiveDevice failed with error: %w", err)
+		}
+		if err := spotify.PlayURI(ctx, choice.URI, deviceId); err != nil {
+			return "", fmt.Errorf("PlayURI failed with error: %w", err)
+		}
+		recordSearchAudit(misc.AuditPlay, interaction, search, choice)
+		return fmt.Sprintf("▶️ Now playing *%s*", choice.Name), nil
+	})
+}
+
+// HandleSearchQueueInteraction puts the picked track in the DJ queue
+func HandleSearchQueueInteraction(ctx context.Context, interaction slack.InteractionCallback, client *slack.Client) (interface{}, error) {
+	return handleSearchResult(ctx, client, interaction, func(search misc.SearchContext, choice misc.TrackChoice) (string, error) {
+		position, err := misc.DJ.Request(misc.QueueRequest{
+			AccountKey:  search.AccountKey,
+			TrackURI:    choice.URI,
+			TrackName:   choice.Name,
+			SlackUserID: interaction.User.ID,
+			UserName:    interaction.User.Name,
+			RequestedAt: time.Now(),
+		})
+		if err != nil {
+			return "", err
+		}
+		recordSearchAudit(misc.AuditQueueAdd, interaction, search, choice)
+		return fmt.Sprintf("✅ Requested *%s*, it is #%d in the DJ queue", choice.Name, position), nil
+	})
+}
+
+// HandleSearchShareInteraction posts the picked result's link to the channel the search was opened from,
+// Slack unfurls it into a player card
+func HandleSearchShareInteraction(ctx context.Context, interaction slack.InteractionCallback, client *slack.Client) (interface{}, error) {
+	return handleSearchResult(ctx, client, interaction, func(search misc.SearchContext, choice misc.TrackChoice) (string, error) {
+		text := fmt.Sprintf("<@%s> shared %s", interaction.User.ID, misc.SpotifyURL(choice.URI))
+		_, _, err := client.PostMessageContext(ctx, search.ChannelID, slack.MsgOptionText(text, false), slack.MsgOptionEnableLinkUnfurl())
+		if err != nil {
+			return "", fmt.Errorf("failed to share search result: %w", err)
+		}
+		return fmt.Sprintf("📣 Shared *%s* to <#%s>", choice.Name, search.ChannelID), nil
+	})
+}
+
+// recordSearchAudit records an action taken from the search modal, in the channel it was opened from
+func recordSearchAudit(action string, interaction slack.InteractionCallback, search misc.SearchContext, choice misc.TrackChoice) {
+	entry := misc.NewAuditEntry(action, interaction, search.AccountKey)
+	entry.ChannelID = search.ChannelID
+	entry.Track, entry.TrackURI = choice.Name, choice.URI
+	misc.RecordAudit(entry)
+}
+
+// handleSearchResult runs act on the search result whose button was clicked and shows its outcome
+// in the modal. Modals have no response_url, so the modal is also where failures are told.
+func handleSearchResult(ctx context.Context, client *slack.Client, interaction slack.InteractionCallback, act func(search misc.SearchContext, choice misc.TrackChoice) (string, error)) (interface{}, error) {
+	var choice misc.TrackChoice
+	if err := json.Unmarshal([]byte(interaction.ActionCallback.BlockActions[0].Value), &choice); err != nil {
+		return nil, fmt.Errorf("failed to decode search choice: %w", err)
+	}
+	search, err := misc.SearchContextOf(interaction.View)
+	if err != nil {
+		return nil, err
+	}
+
+	status, actErr := act(search, choice)
+	if actErr != nil {
+		status = ":warning: " + misc.UserMessage(actErr)
+	}
+	selected := slack.NewOptionBlockObject(choice.URI, slack.NewTextBlockObject(slack.PlainTextType, choice.Name, false, false), nil)
+	if err := updateSearchModal(ctx, client, interaction, selected, status); err != nil {
+		if actErr != nil {
+			return nil, actErr
+		}
+		return nil, err
+	}
+	return nil, actErr
+}
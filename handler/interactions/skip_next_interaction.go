@@ -1,22 +1,71 @@
 package interactions
 
 import (
+	"context"
 	"fmt"
 
+	"github.com/georgecpp/mimir/handler/registry"
 	"github.com/georgecpp/mimir/misc"
 	"github.com/slack-go/slack"
 )
 
-func HandleSkipNextInteraction(interaction slack.InteractionCallback, client *slack.Client) (interface{}, error) {
-	err := misc.SkipToNextTrack()
+func init() {
+	registry.Default.BlockAction(registry.Route{
+		Name:        "skip_next",
+		Description: "Skip to the next track",
+		Scopes:      []string{"user-modify-playback-state"},
+	}, HandleSkipNextInteraction)
+}
+
+func HandleSkipNextInteraction(ctx context.Context, interaction slack.InteractionCallback, client *slack.Client) (interface{}, error) {
+	// The dashboard controls the account of whoever posted it
+	dashboard, err := misc.Dashboards.ForInteraction(interaction)
 	if err != nil {
-		return nil, fmt.Errorf("SkipToNextTrack failed with error: %w", err)
+		return nil, err
 	}
+	spotify := dashboard.SpotifyClient()
 	lastAction := interaction.ActionCallback.BlockActions[0].ActionID
 	userName := interaction.User.Name
-	spotifyAttachment, err := misc.MySpotifyDashboard.AutoUpdateCurrentSpotifyDashboard(client, lastAction, userName)
+	entry := dashboard.AuditEntry(misc.AuditSkip, interaction)
+
+	// In democratic mode a click is a vote, the track is skipped once enough people voted
+	votedTrackURI := ""
+	if misc.SkipVotes.Enabled() {
+		cpt, err := spotify.GetCurrentPlayingTrack(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("[HandleSkipNextInteraction]: GetCurrentPlayingTrack failed with error: %w", err)
+		}
+		entry = entry.WithTrack(cpt)
+		required := misc.SkipVotes.Required(misc.ChannelActivity.Recent(dashboard.GetChannelId(), misc.SkipVotes.Window))
+		votes, skip, err := dashboard.VoteSkip(cpt.TrackURI, interaction.User.ID, required)
+		if err != nil {
+			return nil, err
+		}
+		if !skip {
+			entry.Action = misc.AuditSkipVote
+			misc.RecordAudit(entry)
+			_, err = dashboard.AutoUpdateCurrentSpotifyDashboard(ctx, client, fmt.Sprintf("voted to skip (%d/%d)", votes, required), userName)
+			if err != nil {
+				return nil, fmt.Errorf("AutoUpdateCurrentSpotifyDashboard failed with error: %w", err)
+			}
+			return nil, nil
+		}
+		votedTrackURI = cpt.TrackURI
+	}
+
+	err = spotify.SkipToNextTrack(ctx)
+	if err != nil {
+		// The votes stay, the next click tries again
+		return nil, fmt.Errorf("SkipToNextTrack failed with error: %w", err)
+	}
+	if votedTrackURI != "" {
+		dashboard.ResetSkipVotes(votedTrackURI)
+	}
+	misc.RecordAudit(entry)
+	_, err = dashboard.AutoUpdateCurrentSpotifyDashboard(ctx, client, lastAction, userName)
 	if err != nil {
 		return nil, fmt.Errorf("AutoUpdateCurrentSpotifyDashboard failed with error: %w", err)
 	}
-	return spotifyAttachment, nil
+	// The dashboard message was updated in place, there is nothing to respond with
+	return nil, nil
 }
@@ -1,22 +1,41 @@
 package interactions
 
 import (
+	"context"
 	"fmt"
 
+	"github.com/georgecpp/mimir/handler/registry"
 	"github.com/georgecpp/mimir/misc"
 	"github.com/slack-go/slack"
 )
 
-func HandleSkipPreviousInteraction(interaction slack.InteractionCallback, client *slack.Client) (interface{}, error) {
-	err := misc.SkipToPreviousTrack()
+func init() {
+	registry.Default.BlockAction(registry.Route{
+		Name:        "skip_previous",
+		Description: "Skip to the previous track",
+		Scopes:      []string{"user-modify-playback-state"},
+	}, HandleSkipPreviousInteraction)
+}
+
+func HandleSkipPreviousInteraction(ctx context.Context, interaction slack.InteractionCallback, client *slack.Client) (interface{}, error) {
+	// The dashboard controls the account of whoever posted it
+	dashboard, err := misc.Dashboards.ForInteraction(interaction)
+	if err != nil {
+		return nil, err
+	}
+	spotify := dashboard.SpotifyClient()
+	entry := dashboard.AuditEntry(misc.AuditPrevious, interaction)
+	err = spotify.SkipToPreviousTrack(ctx)
 	if err != nil {
 		return nil, fmt.Errorf("SkipToPreviousTrack failed with error: %w", err)
 	}
+	misc.RecordAudit(entry)
 	lastAction := interaction.ActionCallback.BlockActions[0].ActionID
 	userName := interaction.User.Name
-	spotifyAttachment, err := misc.MySpotifyDashboard.AutoUpdateCurrentSpotifyDashboard(client, lastAction, userName)
+	_, err = dashboard.AutoUpdateCurrentSpotifyDashboard(ctx, client, lastAction, userName)
 	if err != nil {
 		return nil, fmt.Errorf("AutoUpdateCurrentSpotifyDashboard failed with error: %w", err)
 	}
-	return spotifyAttachment, nil
+	// The dashboard message was updated in place, there is nothing to respond with
+	return nil, nil
 }
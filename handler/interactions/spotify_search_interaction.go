@@ -0,0 +1,83 @@
+package interactions
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/georgecpp/mimir/handler/registry"
+	"github.com/georgecpp/mimir/misc"
+	"github.com/slack-go/slack"
+)
+
+func init() {
+	registry.Default.BlockAction(registry.Route{
+		Name:        "spotify_search",
+		Description: "Open the search modal from a dashboard",
+	}, HandleDashboardSearchInteraction)
+	registry.Default.BlockSuggestion(registry.Route{
+		Name:        misc.SpotifySearchSelectActionID,
+		Description: "Search Spotify as the user types",
+	}, HandleSpotifySearchSuggestion)
+	registry.Default.BlockAction(registry.Route{
+		Name:        misc.SpotifySearchSelectActionID,
+		Description: "Show a picked search result with what can be done with it",
+	}, HandleSpotifySearchSelectInteraction)
+}
+
+// HandleDashboardSearchInteraction opens the search modal for the dashboard's account,
+// sharing results to the dashboard's channel
+func HandleDashboardSearchInteraction(ctx context.Context, interaction slack.InteractionCallback, client *slack.Client) (interface{}, error) {
+	dashboard, err := misc.Dashboards.ForInteraction(interaction)
+	if err != nil {
+		return nil, err
+	}
+
+	search := misc.SearchContext{ChannelID: dashboard.GetChannelId(), AccountKey: dashboard.GetAccountKey()}
+	if !misc.Shared.HasSpotifyToken(search.AccountKey) {
+		return nil, misc.ErrNotAuthenticated
+	}
+	modal, err := misc.BuildSpotifySearchModal(search, nil, "")
+	if err != nil {
+		return nil, err
+	}
+	if _, err := client.OpenViewContext(ctx, interaction.TriggerID, modal); err != nil {
+		return nil, fmt.Errorf("failed to open search modal: %w", err)
+	}
+	return nil, nil
+}
+
+// HandleSpotifySearchSuggestion searches Spotify for what the user typed so far,
+// the option groups are sent back as the acknowledgement
+func HandleSpotifySearchSuggestion(ctx context.Context, interaction slack.InteractionCallback, client *slack.Client) (interface{}, error) {
+	search, err := misc.SearchContextOf(interaction.View)
+	if err != nil {
+		return nil, err
+	}
+	options, err := misc.SpotifyForAccount(search.AccountKey).SearchOptions(ctx, interaction.Value)
+	if err != nil {
+		return nil, fmt.Errorf("SearchOptions failed with error: %w", err)
+	}
+	return options, nil
+}
+
+// HandleSpotifySearchSelectInteraction shows the picked result with its play, queue and share buttons
+func HandleSpotifySearchSelectInteraction(ctx context.Context, interaction slack.InteractionCallback, client *slack.Client) (interface{}, error) {
+	selected := interaction.ActionCallback.BlockActions[0].SelectedOption
+	return nil, updateSearchModal(ctx, client, interaction, &selected, "")
+}
+
+// updateSearchModal re-renders the search modal the interaction came from
+func updateSearchModal(ctx context.Context, client *slack.Client, interaction slack.InteractionCallback, selected *slack.OptionBlockObject, status string) error {
+	search, err := misc.SearchContextOf(interaction.View)
+	if err != nil {
+		return err
+	}
+	modal, err := misc.BuildSpotifySearchModal(search, selected, status)
+	if err != nil {
+		return err
+	}
+	if _, err := client.UpdateViewContext(ctx, modal, "", interaction.View.Hash, interaction.View.ID); err != nil {
+		return fmt.Errorf("failed to update search modal: %w", err)
+	}
+	return nil
+}
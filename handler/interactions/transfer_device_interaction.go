@@ -0,0 +1,77 @@
+package interactions
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/georgecpp/mimir/handler/registry"
+	"github.com/georgecpp/mimir/misc"
+	"github.com/slack-go/slack"
+)
+
+func init() {
+	registry.Default.BlockAction(registry.Route{
+		Name:        misc.DashboardDeviceActionID,
+		Description: "Move the dashboard's playback to another device",
+		Scopes:      []string{"user-read-playback-state", "user-modify-playback-state"},
+	}, HandleDashboardDeviceInteraction)
+	registry.Default.BlockAction(registry.Route{
+		Name:        misc.TransferDeviceActionID,
+		Description: "Move playback to a device listed by /spotify-devices",
+		Scopes:      []string{"user-read-playback-state", "user-modify-playback-state"},
+	}, HandleTransferDeviceInteraction)
+}
+
+// HandleDashboardDeviceInteraction moves playback to the device picked on a dashboard and starts it there
+func HandleDashboardDeviceInteraction(ctx context.Context, interaction slack.InteractionCallback, client *slack.Client) (interface{}, error) {
+	// The dashboard controls the account of whoever posted it
+	dashboard, err := misc.Dashboards.ForInteraction(interaction)
+	if err != nil {
+		return nil, err
+	}
+	action := interaction.ActionCallback.BlockActions[0]
+	entry := dashboard.AuditEntry(misc.AuditTransfer, interaction)
+	if err := dashboard.SpotifyClient().TransferPlayback(ctx, action.SelectedOption.Value, true); err != nil {
+		return nil, fmt.Errorf("TransferPlayback failed with error: %w", err)
+	}
+	entry.Detail = action.SelectedOption.Text.Text
+	misc.RecordAudit(entry)
+
+	_, err = dashboard.AutoUpdateCurrentSpotifyDashboard(ctx, client, action.ActionID, interaction.User.Name)
+	if err != nil {
+		return nil, fmt.Errorf("AutoUpdateCurrentSpotifyDashboard failed with error: %w", err)
+	}
+	// The dashboard message was updated in place, there is nothing to respond with
+	return nil, nil
+}
+
+// HandleTransferDeviceInteraction moves the user's playback to the device whose "Play here"
+// button was clicked, starts it there and refreshes the device list
+func HandleTransferDeviceInteraction(ctx context.Context, interaction slack.InteractionCallback, client *slack.Client) (interface{}, error) {
+	deviceId := interaction.ActionCallback.BlockActions[0].Value
+	accountKey := misc.Shared.AccountKey(interaction.Team.ID, interaction.User.ID)
+	spotify := misc.SpotifyForAccount(accountKey)
+	if err := spotify.TransferPlayback(ctx, deviceId, true); err != nil {
+		return nil, fmt.Errorf("TransferPlayback failed with error: %w", err)
+	}
+
+	devices, err := spotify.GetDevices(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("GetDevices failed with error: %w", err)
+	}
+	entry := misc.NewAuditEntry(misc.AuditTransfer, interaction, accountKey)
+	if device, ok := misc.FindDevice(devices, deviceId); ok {
+		entry.Detail = device.Name
+	}
+	misc.RecordAudit(entry)
+	// Spotify takes a moment to report the transfer, show the list as it is about to be
+	for i := range devices {
+		devices[i].IsActive = devices[i].ID == deviceId
+	}
+
+	return slack.WebhookMessage{
+		ResponseType:    slack.ResponseTypeEphemeral,
+		ReplaceOriginal: true,
+		Blocks:          &slack.Blocks{BlockSet: misc.BuildDeviceList(devices, spotify.DefaultDevice())},
+	}, nil
+}
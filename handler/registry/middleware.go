@@ -0,0 +1,121 @@
+package registry
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"runtime/debug"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/georgecpp/mimir/misc"
+)
+
+// Logging logs every routed request with its outcome and duration
+func Logging() Middleware {
+	return func(next HandlerFunc) HandlerFunc {
+		return func(ctx context.Context, req *Request) (interface{}, error) {
+			start := time.Now()
+			payload, err := next(ctx, req)
+			outcome := "ok"
+			if err != nil {
+				outcome = misc.KindOf(err).String() + " error"
+			}
+			log.Printf("%s %s user=%s channel=%s %s in %s", req.Route.Kind, req.Route.Name, req.UserID, req.ChannelID, outcome, time.Since(start).Round(time.Millisecond))
+			return payload, err
+		}
+	}
+}
+
+// Recover turns a panicking handler into an internal error
+func Recover() Middleware {
+	return func(next HandlerFunc) HandlerFunc {
+		return func(ctx context.Context, req *Request) (payload interface{}, err error) {
+			defer func() {
+				if r := recover(); r != nil {
+					err = fmt.Errorf("panic in %s %s: %v\n%s", req.Route.Kind, req.Route.Name, r, debug.Stack())
+				}
+			}()
+			return next(ctx, req)
+		}
+	}
+}
+
+// AccountResolver returns the token key of the Spotify account a request acts on
+type AccountResolver func(req *Request) string
+
+// SpotifyAuth rejects requests to routes with Spotify scopes when the account they act on
+// has not run /spotify-auth or was authorized without one of the scopes
+func SpotifyAuth(resolve AccountResolver) Middleware {
+	return func(next HandlerFunc) HandlerFunc {
+		return func(ctx context.Context, req *Request) (interface{}, error) {
+			if len(req.Route.Scopes) == 0 {
+				return next(ctx, req)
+			}
+
+			token := misc.Shared.GetSpotifyTokenFor(resolve(req))
+			if token.AccessToken == "" {
+				return nil, misc.ErrNotAuthenticated
+			}
+			// Tokens from before scopes were recorded are given the benefit of the doubt
+			if token.Scope != "" {
+				granted := strings.Fields(token.Scope)
+				for _, scope := range req.Route.Scopes {
+					if !containsString(granted, scope) {
+						return nil, misc.UserError(fmt.Sprintf("Your Spotify login is missing the %s permission. Run /spotify-auth again to grant it!", scope))
+					}
+				}
+			}
+			return next(ctx, req)
+		}
+	}
+}
+
+// RateLimit allows each user at most limit requests per window, across all routes.
+// Block suggestions are exempt, Slack sends one for every few keystrokes.
+func RateLimit(limit int, window time.Duration) Middleware {
+	var mutex sync.Mutex
+	seen := map[string][]time.Time{}
+
+	return func(next HandlerFunc) HandlerFunc {
+		return func(ctx context.Context, req *Request) (interface{}, error) {
+			if req.UserID == "" || req.Route.Kind == KindBlockSuggestion {
+				return next(ctx, req)
+			}
+
+			now := time.Now()
+			mutex.Lock()
+			recent := seen[req.UserID][:0]
+			for _, at := range seen[req.UserID] {
+				if now.Sub(at) < window {
+					recent = append(recent, at)
+				}
+			}
+			allowed := len(recent) < limit
+			if allowed {
+				recent = append(recent, now)
+			}
+			if len(recent) == 0 {
+				delete(seen, req.UserID)
+			} else {
+				seen[req.UserID] = recent
+			}
+			mutex.Unlock()
+
+			if !allowed {
+				return nil, misc.UserError("Easy there! You're sending requests too fast, give it a few seconds.")
+			}
+			return next(ctx, req)
+		}
+	}
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,258 @@
+// Package registry routes slash commands, block actions, view submissions, block
+// suggestions and Events API events to the handlers that registered for them,
+// through a shared middleware chain.
+package registry
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/georgecpp/mimir/misc"
+	"github.com/slack-go/slack"
+	"github.com/slack-go/slack/slackevents"
+)
+
+// Route kinds
+const (
+	KindCommand         = "command"
+	KindBlockAction     = "block_action"
+	KindViewSubmission  = "view_submission"
+	KindBlockSuggestion = "block_suggestion"
+	KindEvent           = "event"
+)
+
+// Route describes a registered handler
+type Route struct {
+	Kind        string
+	Name        string   // command ("/spotify"), action ID, view callback ID or event type
+	Usage       string   // e.g. "/meme [subreddit]"
+	Description string   // one line shown in help and unknown command replies
+	Scopes      []string // Spotify scopes the handler needs, empty if it doesn't talk to Spotify
+}
+
+// Request is what middleware sees of an incoming Slack request
+type Request struct {
+	Route       Route
+	Client      *slack.Client
+	TeamID      string
+	UserID      string
+	ChannelID   string
+	Command     *slack.SlashCommand
+	Interaction *slack.InteractionCallback
+	Event       *slackevents.EventsAPIEvent
+}
+
+// HandlerFunc is the uniform shape every route is adapted to
+type HandlerFunc func(ctx context.Context, req *Request) (interface{}, error)
+
+// Middleware wraps a HandlerFunc, e.g. to log, authorize or rate limit it
+type Middleware func(next HandlerFunc) HandlerFunc
+
+// CommandHandler handles a slash command
+type CommandHandler func(ctx context.Context, command slack.SlashCommand, client *slack.Client) (interface{}, error)
+
+// InteractionHandler handles a block action, view submission or block suggestion
+type InteractionHandler func(ctx context.Context, interaction slack.InteractionCallback, client *slack.Client) (interface{}, error)
+
+// EventHandler handles an Events API callback event
+type EventHandler func(ctx context.Context, event slackevents.EventsAPIEvent, client *slack.Client) (interface{}, error)
+
+type entry struct {
+	route   Route
+	handler HandlerFunc
+}
+
+// Registry holds the registered routes and the middleware applied to all of them
+type Registry struct {
+	routes     map[string]map[string]entry
+	middleware []Middleware
+	mutex      sync.RWMutex
+}
+
+// Default is the registry the handler packages register themselves with
+var Default = New()
+
+// New creates an empty registry
+func New() *Registry {
+	return &Registry{routes: map[string]map[string]entry{}}
+}
+
+// Use appends middleware, the first one added is the outermost
+func (r *Registry) Use(middleware ...Middleware) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.middleware = append(r.middleware, middleware...)
+}
+
+// Command registers a slash command handler under route.Name
+func (r *Registry) Command(route Route, handle CommandHandler) {
+	route.Kind = KindCommand
+	r.add(route, func(ctx context.Context, req *Request) (interface{}, error) {
+		return handle(ctx, *req.Command, req.Client)
+	})
+}
+
+// BlockAction registers a handler for the block action with action ID route.Name
+func (r *Registry) BlockAction(route Route, handle InteractionHandler) {
+	route.Kind = KindBlockAction
+	r.add(route, func(ctx context.Context, req *Request) (interface{}, error) {
+		return handle(ctx, *req.Interaction, req.Client)
+	})
+}
+
+// ViewSubmission registers a handler for the modal with callback ID route.Name
+func (r *Registry) ViewSubmission(route Route, handle InteractionHandler) {
+	route.Kind = KindViewSubmission
+	r.add(route, func(ctx context.Context, req *Request) (interface{}, error) {
+		return handle(ctx, *req.Interaction, req.Client)
+	})
+}
+
+// BlockSuggestion registers the handler that fills the external select with action ID route.Name.
+// Its payload is the options response, which is sent back in the acknowledgement.
+func (r *Registry) BlockSuggestion(route Route, handle InteractionHandler) {
+	route.Kind = KindBlockSuggestion
+	r.add(route, func(ctx context.Context, req *Request) (interface{}, error) {
+		return handle(ctx, *req.Interaction, req.Client)
+	})
+}
+
+// Event registers a handler for the inner event type route.Name, e.g. "app_mention"
+func (r *Registry) Event(route Route, handle EventHandler) {
+	route.Kind = KindEvent
+	r.add(route, func(ctx context.Context, req *Request) (interface{}, error) {
+		return handle(ctx, *req.Event, req.Client)
+	})
+}
+
+func (r *Registry) add(route Route, handler HandlerFunc) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	if r.routes[route.Kind] == nil {
+		r.routes[route.Kind] = map[string]entry{}
+	}
+	if _, exists := r.routes[route.Kind][route.Name]; exists {
+		panic(fmt.Sprintf("registry: %s %s registered twice", route.Kind, route.Name))
+	}
+	r.routes[route.Kind][route.Name] = entry{route: route, handler: handler}
+}
+
+// Routes lists the registered routes of a kind, sorted by name
+func (r *Registry) Routes(kind string) []Route {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+	var routes []Route
+	for _, e := range r.routes[kind] {
+		routes = append(routes, e.route)
+	}
+	sort.Slice(routes, func(i, j int) bool { return routes[i].Name < routes[j].Name })
+	return routes
+}
+
+// DispatchCommand routes a slash command, answering unknown commands with the list of known ones
+func (r *Registry) DispatchCommand(ctx context.Context, command slack.SlashCommand, client *slack.Client) (interface{}, error) {
+	req := &Request{
+		Client:    client,
+		TeamID:    command.TeamID,
+		UserID:    command.UserID,
+		ChannelID: command.ChannelID,
+		Command:   &command,
+	}
+	e, ok := r.lookup(KindCommand, command.Command)
+	if !ok {
+		return nil, misc.UserError(fmt.Sprintf("I don't know %s. Here is what I can do:\n%s", command.Command, r.Help()))
+	}
+	return r.serve(ctx, e, req)
+}
+
+// DispatchInteraction routes block actions by the first action's ID, view submissions by callback ID
+// and block suggestions by the action ID of the select asking for options
+func (r *Registry) DispatchInteraction(ctx context.Context, interaction slack.InteractionCallback, client *slack.Client) (interface{}, error) {
+	req := &Request{
+		Client:      client,
+		TeamID:      interaction.Team.ID,
+		UserID:      interaction.User.ID,
+		ChannelID:   interaction.Channel.ID,
+		Interaction: &interaction,
+	}
+
+	var kind, name string
+	switch interaction.Type {
+	case slack.InteractionTypeBlockActions:
+		if len(interaction.ActionCallback.BlockActions) == 0 {
+			return nil, fmt.Errorf("block_actions interaction without actions")
+		}
+		kind, name = KindBlockAction, interaction.ActionCallback.BlockActions[0].ActionID
+	case slack.InteractionTypeViewSubmission:
+		kind, name = KindViewSubmission, interaction.View.CallbackID
+	case slack.InteractionTypeBlockSuggestion:
+		kind, name = KindBlockSuggestion, interaction.ActionID
+	default:
+		return nil, fmt.Errorf("unsupported interaction type: %s", interaction.Type)
+	}
+
+	e, ok := r.lookup(kind, name)
+	if !ok {
+		return nil, misc.UserError("Sorry, that control isn't wired up to anything anymore.")
+	}
+	return r.serve(ctx, e, req)
+}
+
+// DispatchEvent routes an Events API callback event by its inner event type.
+// Events nobody registered for are ignored, there is no one to answer.
+func (r *Registry) DispatchEvent(ctx context.Context, event slackevents.EventsAPIEvent, client *slack.Client) (interface{}, error) {
+	if event.Type != slackevents.CallbackEvent {
+		return nil, fmt.Errorf("unsupported event type: %s", event.Type)
+	}
+	req := &Request{
+		Client: client,
+		TeamID: event.TeamID,
+		Event:  &event,
+	}
+	if ev, ok := event.InnerEvent.Data.(*slackevents.AppMentionEvent); ok {
+		req.UserID = ev.User
+		req.ChannelID = ev.Channel
+	}
+
+	e, ok := r.lookup(KindEvent, event.InnerEvent.Type)
+	if !ok {
+		return nil, nil
+	}
+	return r.serve(ctx, e, req)
+}
+
+// Help renders the registered commands with their usage and description
+func (r *Registry) Help() string {
+	var lines []string
+	for _, route := range r.Routes(KindCommand) {
+		usage := route.Usage
+		if usage == "" {
+			usage = route.Name
+		}
+		lines = append(lines, fmt.Sprintf("• `%s` %s", usage, route.Description))
+	}
+	return strings.Join(lines, "\n")
+}
+
+func (r *Registry) lookup(kind string, name string) (entry, bool) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+	e, ok := r.routes[kind][name]
+	return e, ok
+}
+
+// serve runs the entry's handler inside the middleware chain
+func (r *Registry) serve(ctx context.Context, e entry, req *Request) (interface{}, error) {
+	r.mutex.RLock()
+	handler := e.handler
+	for i := len(r.middleware) - 1; i >= 0; i-- {
+		handler = r.middleware[i](handler)
+	}
+	r.mutex.RUnlock()
+
+	req.Route = e.route
+	return handler(ctx, req)
+}
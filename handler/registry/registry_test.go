@@ -0,0 +1,148 @@
+package registry
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/georgecpp/mimir/misc"
+	"github.com/slack-go/slack"
+	"github.com/slack-go/slack/slackevents"
+)
+
+func TestDispatchCommand(t *testing.T) {
+	r := New()
+	var order []string
+	trace := func(name string) Middleware {
+		return func(next HandlerFunc) HandlerFunc {
+			return func(ctx context.Context, req *Request) (interface{}, error) {
+				order = append(order, name)
+				return next(ctx, req)
+			}
+		}
+	}
+	r.Use(trace("outer"), trace("inner"))
+	r.Command(Route{Name: "/hello", Usage: "/hello [text]", Description: "Say hello"}, func(ctx context.Context, command slack.SlashCommand, client *slack.Client) (interface{}, error) {
+		order = append(order, "handler")
+		return "hi " + command.Text, nil
+	})
+
+	payload, err := r.DispatchCommand(context.Background(), slack.SlashCommand{Command: "/hello", Text: "there"}, nil)
+	if err != nil || payload != "hi there" {
+		t.Fatalf("DispatchCommand = %v, %v", payload, err)
+	}
+	if got := strings.Join(order, ","); got != "outer,inner,handler" {
+		t.Errorf("middleware order = %s", got)
+	}
+
+	_, err = r.DispatchCommand(context.Background(), slack.SlashCommand{Command: "/nope"}, nil)
+	if misc.KindOf(err) != misc.ErrorKindUser {
+		t.Fatalf("unknown command error = %v, want a user error", err)
+	}
+	if msg := misc.UserMessage(err); !strings.Contains(msg, "/nope") || !strings.Contains(msg, "/hello [text]") {
+		t.Errorf("unknown command reply %q should name the command and list known ones", msg)
+	}
+}
+
+func TestDispatchInteraction(t *testing.T) {
+	r := New()
+	r.BlockAction(Route{Name: "skip_next"}, func(ctx context.Context, interaction slack.InteractionCallback, client *slack.Client) (interface{}, error) {
+		return "skipped", nil
+	})
+
+	interaction := slack.InteractionCallback{Type: slack.InteractionTypeBlockActions}
+	if _, err := r.DispatchInteraction(context.Background(), interaction, nil); err == nil {
+		t.Error("expected an error for block actions without actions")
+	}
+
+	interaction.ActionCallback.BlockActions = []*slack.BlockAction{{ActionID: "skip_next"}}
+	if payload, err := r.DispatchInteraction(context.Background(), interaction, nil); err != nil || payload != "skipped" {
+		t.Errorf("DispatchInteraction = %v, %v", payload, err)
+	}
+
+	interaction.ActionCallback.BlockActions[0].ActionID = "gone"
+	if _, err := r.DispatchInteraction(context.Background(), interaction, nil); misc.KindOf(err) != misc.ErrorKindUser {
+		t.Errorf("unknown action error = %v, want a user error", err)
+	}
+}
+
+func TestDispatchBlockSuggestion(t *testing.T) {
+	r := New()
+	r.BlockSuggestion(Route{Name: "search"}, func(ctx context.Context, interaction slack.InteractionCallback, client *slack.Client) (interface{}, error) {
+		return "options for " + interaction.Value, nil
+	})
+	// Typing fast must not trip the rate limit
+	r.Use(RateLimit(1, time.Minute))
+
+	interaction := slack.InteractionCallback{Type: slack.InteractionTypeBlockSuggestion, ActionID: "search", Value: "abba", User: slack.User{ID: "U1"}}
+	for i := 0; i < 3; i++ {
+		if payload, err := r.DispatchInteraction(context.Background(), interaction, nil); err != nil || payload != "options for abba" {
+			t.Fatalf("DispatchInteraction = %v, %v", payload, err)
+		}
+	}
+}
+
+func TestDispatchEventIgnoresUnknownTypes(t *testing.T) {
+	r := New()
+	event := slackevents.EventsAPIEvent{
+		Type:       slackevents.CallbackEvent,
+		InnerEvent: slackevents.EventsAPIInnerEvent{Type: "reaction_added"},
+	}
+	if payload, err := r.DispatchEvent(context.Background(), event, nil); payload != nil || err != nil {
+		t.Errorf("DispatchEvent = %v, %v, want it ignored", payload, err)
+	}
+}
+
+func TestRecover(t *testing.T) {
+	handler := Recover()(func(ctx context.Context, req *Request) (interface{}, error) {
+		panic("boom")
+	})
+	if _, err := handler(context.Background(), &Request{}); err == nil || misc.KindOf(err) != misc.ErrorKindInternal {
+		t.Errorf("Recover error = %v, want an internal error", err)
+	}
+}
+
+func TestRateLimit(t *testing.T) {
+	handler := RateLimit(2, time.Minute)(func(ctx context.Context, req *Request) (interface{}, error) {
+		return nil, nil
+	})
+	alice := &Request{UserID: "alice"}
+	for i := 0; i < 2; i++ {
+		if _, err := handler(context.Background(), alice); err != nil {
+			t.Fatalf("request %d rejected: %v", i, err)
+		}
+	}
+	if _, err := handler(context.Background(), alice); misc.KindOf(err) != misc.ErrorKindUser {
+		t.Errorf("third request error = %v, want a user error", err)
+	}
+	if _, err := handler(context.Background(), &Request{UserID: "bob"}); err != nil {
+		t.Errorf("bob limited by alice's requests: %v", err)
+	}
+}
+
+func TestSpotifyAuth(t *testing.T) {
+	called := false
+	handler := SpotifyAuth(func(req *Request) string { return req.UserID })(func(ctx context.Context, req *Request) (interface{}, error) {
+		called = true
+		return nil, nil
+	})
+	route := Route{Name: "skip_next", Scopes: []string{"user-modify-playback-state"}}
+
+	_, err := handler(context.Background(), &Request{Route: route, UserID: "registry-test-nobody"})
+	if called || misc.KindOf(err) != misc.ErrorKindUser {
+		t.Errorf("unconnected account: called=%v err=%v", called, err)
+	}
+
+	misc.Shared.SetSpotifyTokenFor("registry-test-reader", misc.SpotifyToken{AccessToken: "a", Scope: "user-read-playback-state"})
+	_, err = handler(context.Background(), &Request{Route: route, UserID: "registry-test-reader"})
+	if called || !strings.Contains(misc.UserMessage(err), "user-modify-playback-state") {
+		t.Errorf("missing scope: called=%v err=%v", called, err)
+	}
+
+	misc.Shared.SetSpotifyTokenFor("registry-test-dj", misc.SpotifyToken{AccessToken: "a", Scope: "user-read-playback-state user-modify-playback-state"})
+	if _, err = handler(context.Background(), &Request{Route: route, UserID: "registry-test-dj"}); err != nil || !called {
+		t.Errorf("authorized account: called=%v err=%v", called, err)
+	}
+
+}
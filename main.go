@@ -4,6 +4,9 @@ import (
 	"context"
 	"log"
 	"os"
+	"os/signal"
+	"syscall"
+	"time"
 
 	"github.com/georgecpp/mimir/handler"
 	"github.com/georgecpp/mimir/misc"
@@ -12,12 +15,33 @@ import (
 	"github.com/slack-go/slack/socketmode"
 )
 
-func main() {	
+func main() {
 	config, err := misc.LoadConfig(".")
 	if err != nil {
 		log.Fatal(err)
 	}
-	
+
+	// Point the Spotify client at the configured API, the public one by default,
+	// waking the configured default device when nothing is active
+	misc.Spotify = misc.NewSpotifyClient(config.SpotifyApiBaseUrl, nil, &misc.Shared).WithDefaultDevice(config.SpotifyDefaultDevice)
+	// Renew the Spotify access token from its refresh token instead of asking for /spotify-auth every hour
+	misc.Shared.SetSpotifyTokenRefresher(misc.NewSpotifyTokenRefresher(config))
+	// Every Slack user brings their own Spotify account unless the shared party account is configured
+	if err := misc.Shared.SetSpotifyAccountMode(config.SpotifyAccountMode); err != nil {
+		log.Fatal(err)
+	}
+
+	// Restore the Spotify authorizations from the previous run before we start listening
+	tokenStore, err := misc.NewTokenStore(config)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer tokenStore.Close()
+	misc.Shared.SetTokenStore(tokenStore)
+	if err := misc.Shared.LoadSpotifyTokens(); err != nil {
+		log.Fatal(err)
+	}
+
 	// Create a new client to slack by giving token
 	// Set debug to true while developing
 	client := slack.New(config.SlackAuthToken, slack.OptionDebug(true), slack.OptionAppLevelToken(config.SlackAppToken))
@@ -29,20 +53,101 @@ func main() {
 		socketmode.OptionLog(log.New(os.Stdout, "socketmode: ", log.Lshortfile|log.LstdFlags)),
 	)
 
-	// Create a context that can be used to cancel goroutine
-	ctx, cancel := context.WithCancel(context.Background())
-
-	// make this cancel called properly in a real program, graceful shutdown etc
+	// Create a context that is cancelled on Ctrl+C or SIGTERM, stopping every goroutine below
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
 	defer cancel()
 
-	go listen(ctx,client,socketClient)
-	go misc.RunSpotifyAuthServer();
+	// Handlers run on a bounded worker pool so every request is acknowledged within Slack's 3 seconds
+	dispatcher := handler.NewDispatcher(ctx, config.HandlerWorkers(), config.HandlerQueueSize(), config.HandlerTimeout())
+	defer dispatcher.Shutdown()
 
-	socketClient.Run()
-}
+	// Keep the /spotify dashboards in sync with what is playing between clicks
+	misc.Dashboards = misc.NewDashboardManager(config.DashboardChannelLimit())
+	// Bring back the dashboards posted before the restart, their buttons keep working
+	dashboardStore, err := misc.NewDashboardStore(config)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer dashboardStore.Close()
+	misc.Dashboards.SetStore(dashboardStore)
+	retired, err := misc.Dashboards.LoadDashboards()
+	if err != nil {
+		log.Fatal(err)
+	}
+	// Dashboards over the per-channel cap lose their controls, as if the newer ones were just posted
+	for _, dashboard := range retired {
+		retireCtx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+		if err := dashboard.Retire(retireCtx, client, "A newer dashboard was posted below."); err != nil {
+			log.Printf("failed to retire Spotify dashboard: %v", err)
+		}
+		cancel()
+	}
+	// Remember who skipped, paused, queued and moved the music, for /spotify-history
+	auditStore, err := misc.NewAuditStore(config)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer auditStore.Close()
+	misc.AuditTrail = auditStore
+	// Log what plays for the weekly report and /spotify-stats
+	playHistoryStore, err := misc.NewPlayHistoryStore(config)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer playHistoryStore.Close()
+	misc.PlayHistory = misc.NewPlayHistoryLog(playHistoryStore)
+	reportDay, reportHour, err := config.WeeklyReportSchedule()
+	if err != nil {
+		log.Fatal(err)
+	}
+	// The dashboards offer to save tracks to the team playlist, if there is one
+	misc.TeamPlaylist = misc.PlaylistID(config.SpotifyTeamPlaylist)
+	// Let the channel vote on skips instead of the first click deciding, if configured
+	misc.SkipVotes = config.SkipVotePolicy()
+	// Requests take turns in Spotify's queue instead of whoever queues most hogging it
+	misc.DJ = misc.NewDJQueue(config.DJQueuePerUser())
+	feeding := make(chan struct{})
+	go func() {
+		defer close(feeding)
+		misc.DJ.Run(ctx, config.DashboardPollInterval())
+	}()
+	misc.MySpotifyPoller = misc.NewSpotifyPoller(misc.Dashboards, config.DashboardPollInterval(), config.DashboardIdleTimeout(), config.DashboardRetention())
+	polling := make(chan struct{})
+	go func() {
+		defer close(polling)
+		misc.MySpotifyPoller.Run(ctx, client)
+	}()
+
+	reporting := make(chan struct{})
+	go func() {
+		defer close(reporting)
+		if config.SlackChannelId != "" {
+			misc.RunWeeklyReport(ctx, client, config.SlackChannelId, reportDay, reportHour)
+		}
+	}()
 
+	listening := make(chan struct{})
+	go func() {
+		defer close(listening)
+		listen(ctx, client, socketClient, dispatcher)
+	}()
+	go misc.RunSpotifyAuthServer()
 
-func listen(ctx context.Context, client *slack.Client, socketClient *socketmode.Client) {
+	if err := socketClient.RunContext(ctx); err != nil && ctx.Err() == nil {
+		log.Printf("socketmode client stopped: %v", err)
+	}
+
+	// Let the listener, the poller, the DJ queue and the report finish before the deferred shutdowns run,
+	// the dispatcher must not receive jobs once it is shut down
+	cancel()
+	<-listening
+	<-polling
+	<-feeding
+	<-reporting
+	log.Println("Shut down")
+}
+
+func listen(ctx context.Context, client *slack.Client, socketClient *socketmode.Client, dispatcher *handler.Dispatcher) {
 	// Create a for loop that selects either the context cancellation or the events incoming
 	for {
 		select {
@@ -62,15 +167,15 @@ func listen(ctx context.Context, client *slack.Client, socketClient *socketmode.
 					log.Printf("Could not type cast the event to the EventsAPIEvent: %v\n", event)
 					continue
 				}
-				// Now we have an Events API event, but this event type can in turn be many types, so we actually need another type switch
-				payload, err := handler.HandleEventMessage(eventsAPIEvent, client)
-				if err != nil {
-					// TODO: replace with actual error handling
-					log.Fatal(err)
-				}
-				// Don't forget to acknowledge the request
-				// The payload is the response
-				socketClient.Ack(*event.Request, payload)
+				// Acknowledge first, the handler runs on the worker pool
+				socketClient.Ack(*event.Request)
+
+				dispatch(dispatcher, func(ctx context.Context) {
+					// Now we have an Events API event, but this event type can in turn be many types, so we actually need another type switch
+					handler.Execute(ctx, client, handler.EventRequest(eventsAPIEvent), func(ctx context.Context) (interface{}, error) {
+						return handler.HandleEventMessage(ctx, eventsAPIEvent, client)
+					})
+				})
 
 			// handle Slash Commmands Events
 			case socketmode.EventTypeSlashCommand:
@@ -80,30 +185,73 @@ func listen(ctx context.Context, client *slack.Client, socketClient *socketmode.
 					log.Printf("Could not type cast the message to a SlashCommand: %v\n", command)
 					continue
 				}
-		
-				// handleSlashCommand will take care of the command
-				payload, err := handler.HandleSlashCommand(command, client)
-				if err != nil {
-					log.Fatal(err)
+				// Acknowledge first, the response is delivered later through the response_url
+				socketClient.Ack(*event.Request)
+
+				queued := dispatch(dispatcher, func(ctx context.Context) {
+					// handleSlashCommand will take care of the command
+					handler.Execute(ctx, client, handler.CommandRequest(command), func(ctx context.Context) (interface{}, error) {
+						return handler.HandleSlashCommand(ctx, command, client)
+					})
+				})
+				if !queued {
+					respondBusy(client, command.ResponseURL)
 				}
-				// Don't forget to acknowledge the request
-				// The payload is the response
-				socketClient.Ack(*event.Request, payload)
 
-			// handle Inreraction Events				
+			// handle Inreraction Events
 			case socketmode.EventTypeInteractive:
 				interaction, ok := event.Data.(slack.InteractionCallback)
 				if !ok {
 					log.Printf("Could not type cast the message to a Interaction callback: %v\n", interaction)
-					continue				
+					continue
+				}
+				// Options for an external select are the acknowledgement itself, so it waits for the handler
+				if interaction.Type == slack.InteractionTypeBlockSuggestion {
+					request, received := *event.Request, time.Now()
+					queued := dispatch(dispatcher, func(ctx context.Context) {
+						socketClient.Ack(request, handler.HandleBlockSuggestion(ctx, interaction, client, received))
+					})
+					if !queued {
+						socketClient.Ack(request, slack.OptionsResponse{})
+					}
+					continue
 				}
+				socketClient.Ack(*event.Request)
 
-				payload, err := handler.HandleInteractionEvent(interaction, client)
-				if err != nil {
-					log.Fatal(err)
+				queued := dispatch(dispatcher, func(ctx context.Context) {
+					handler.Execute(ctx, client, handler.InteractionRequest(interaction), func(ctx context.Context) (interface{}, error) {
+						return handler.HandleInteractionEvent(ctx, interaction, client)
+					})
+				})
+				if !queued {
+					respondBusy(client, interaction.ResponseURL)
 				}
-				socketClient.Ack(*event.Request, payload)
 			}
 		}
 	}
-}
\ No newline at end of file
+}
+
+// dispatch submits a job to the worker pool and logs when the pool is saturated
+func dispatch(dispatcher *handler.Dispatcher, job func(ctx context.Context)) bool {
+	if dispatcher.Submit(job) {
+		return true
+	}
+	log.Println("All workers are busy, dropping request")
+	return false
+}
+
+// respondBusy tells the user we could not take their request right now. It answers in the
+// background, the listener must keep acknowledging events while the pool is saturated.
+func respondBusy(client *slack.Client, responseURL string) {
+	if responseURL == "" {
+		return
+	}
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+		defer cancel()
+		err := handler.Respond(ctx, client, responseURL, "", "I'm a bit busy right now, please try again in a moment.")
+		if err != nil {
+			log.Printf("failed to send busy response: %v", err)
+		}
+	}()
+}
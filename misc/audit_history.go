@@ -0,0 +1,198 @@
+package misc
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/slack-go/slack"
+)
+
+// HistoryPageSize is how many actions /spotify-history shows, the export has them all
+const HistoryPageSize = 15
+
+// historyUsage explains the arguments of /spotify-history
+const historyUsage = "Usage: /spotify-history [@user] [since, e.g. 2h, 3d, 1w or 2024-05-01] [csv|json]"
+
+var (
+	mentionPattern = regexp.MustCompile(`^<@([A-Z0-9]+)(\|[^>]*)?>$`)
+	agoPattern     = regexp.MustCompile(`^(\d+)([mhdw])$`)
+)
+
+// HistoryRequest is what /spotify-history was asked for
+type HistoryRequest struct {
+	Query  AuditQuery
+	Format string // "csv" or "json" to export, empty to list
+}
+
+// ParseHistoryRequest reads the arguments of /spotify-history: a user, how far back to look and an
+// export format, each optional and in any order
+func ParseHistoryRequest(text string, now time.Time) (HistoryRequest, error) {
+	var request HistoryRequest
+	for _, arg := range strings.Fields(text) {
+		lower := strings.ToLower(arg)
+		switch {
+		case lower == "csv" || lower == "json":
+			request.Format = lower
+		case mentionPattern.MatchString(arg):
+			request.Query.SlackUserID = mentionPattern.FindStringSubmatch(arg)[1]
+		case strings.HasPrefix(arg, "@") && len(arg) > 1:
+			request.Query.UserName = arg[1:]
+		default:
+			since, ok := ParseSince(arg, now)
+			if !ok {
+				return HistoryRequest{}, UserError(fmt.Sprintf("I don't understand %q. %s", arg, historyUsage))
+			}
+			request.Query.Since = since
+		}
+	}
+	return request, nil
+}
+
+// ParseSince reads how far back to look: a time ago such as 30m, 2h, 3d or 1w, or a date such as 2024-05-01
+func ParseSince(arg string, now time.Time) (time.Time, bool) {
+	if match := agoPattern.FindStringSubmatch(strings.ToLower(arg)); match != nil {
+		n, _ := strconv.Atoi(match[1])
+		unit := map[string]time.Duration{"m": time.Minute, "h": time.Hour, "d": 24 * time.Hour, "w": 7 * 24 * time.Hour}[match[2]]
+		return now.Add(-time.Duration(n) * unit), true
+	}
+	since, err := time.ParseInLocation("2006-01-02", arg, now.Location())
+	return since, err == nil
+}
+
+// auditActionText is how the history tells each action
+var auditActionText = map[string]string{
+	AuditSkip:     "⏩ skipped",
+	AuditSkipVote: "🗳️ voted to skip",
+	AuditPrevious: "⏪ went back from",
+	AuditPause:    "⏸️ paused",
+	AuditPlay:     "▶️ played",
+	AuditQueueAdd: "➕ requested",
+	AuditTransfer: "🔈 moved playback of",
+	AuditSave:     "💾 saved to the team playlist",
+}
+
+// slackDate renders t in the reader's time zone
+func slackDate(t time.Time) string {
+	return fmt.Sprintf("<!date^%d^{date_short_pretty} {time}|%s>", t.Unix(), t.UTC().Format("2006-01-02 15:04 UTC"))
+}
+
+// BuildAuditHistory lists the newest entries of the trail, one line each, and who skipped the most
+func BuildAuditHistory(entries []AuditEntry, query AuditQuery) []slack.Block {
+	title := "*📜 Playback history*"
+	switch {
+	case query.SlackUserID != "":
+		title += fmt.Sprintf(" of <@%s>", query.SlackUserID)
+	case query.UserName != "":
+		title += " of @" + query.UserName
+	}
+	if query.ChannelID != "" {
+		title += fmt.Sprintf(" in <#%s>", query.ChannelID)
+	}
+	if !query.Since.IsZero() {
+		title += " since " + slackDate(query.Since)
+	}
+	blocks := []slack.Block{slack.NewSectionBlock(slack.NewTextBlockObject(slack.MarkdownType, title, false, false), nil, nil)}
+	if len(entries) == 0 {
+		emptyText := slack.NewTextBlockObject(slack.MarkdownType, "Nothing happened to the music in that time.", false, false)
+		return append(blocks, slack.NewSectionBlock(emptyText, nil, nil, slack.SectionBlockOptionBlockID("no_history")))
+	}
+
+	for i, entry := range entries[:min(len(entries), HistoryPageSize)] {
+		action, ok := auditActionText[entry.Action]
+		if !ok {
+			action = entry.Action
+		}
+		line := fmt.Sprintf("%s · <@%s> %s", slackDate(entry.At), entry.SlackUserID, action)
+		if entry.Track != "" {
+			line += " *" + entry.Track + "*"
+		}
+		if entry.Detail != "" {
+			line += " → " + entry.Detail
+		}
+		if entry.ChannelID != "" && entry.ChannelID != query.ChannelID {
+			line += fmt.Sprintf(" in <#%s>", entry.ChannelID)
+		}
+		blocks = append(blocks, slack.NewContextBlock(fmt.Sprintf("history_%d", i), slack.NewTextBlockObject(slack.MarkdownType, line, false, false)))
+	}
+
+	summary := fmt.Sprintf("Showing %d of %d actions, add csv or json to export them all.", min(len(entries), HistoryPageSize), len(entries))
+	if skippers := topSkippers(entries); skippers != "" {
+		summary += " Most skips: " + skippers
+	}
+	return append(blocks, slack.NewContextBlock("history_summary", slack.NewTextBlockObject(slack.MarkdownType, summary, false, false)))
+}
+
+// topSkippers names the three people who skipped the most tracks among entries
+func topSkippers(entries []AuditEntry) string {
+	skips := map[string]int{}
+	for _, entry := range entries {
+		if entry.Action == AuditSkip {
+			skips[entry.SlackUserID]++
+		}
+	}
+	users := make([]string, 0, len(skips))
+	for user := range skips {
+		users = append(users, user)
+	}
+	sort.Slice(users, func(i, j int) bool {
+		if skips[users[i]] != skips[users[j]] {
+			return skips[users[i]] > skips[users[j]]
+		}
+		return users[i] < users[j]
+	})
+
+	var top []string
+	for _, user := range users[:min(len(users), 3)] {
+		top = append(top, fmt.Sprintf("<@%s> %d", user, skips[user]))
+	}
+	return strings.Join(top, ", ")
+}
+
+// csvText keeps a spreadsheet from reading text, such as a song called =HYPERLINK(...), as a formula
+func csvText(text string) string {
+	if text != "" && strings.ContainsRune("=+-@", rune(text[0])) {
+		return "'" + text
+	}
+	return text
+}
+
+// ExportAudit renders entries as a CSV or JSON file, oldest first as a log reads
+func ExportAudit(entries []AuditEntry, format string) ([]byte, error) {
+	chronological := make([]AuditEntry, len(entries))
+	for i, entry := range entries {
+		chronological[len(entries)-1-i] = entry
+	}
+
+	switch format {
+	case "json":
+		data, err := json.MarshalIndent(chronological, "", "  ")
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode history: %w", err)
+		}
+		return data, nil
+	case "csv":
+		var buf bytes.Buffer
+		w := csv.NewWriter(&buf)
+		w.Write([]string{"at", "action", "slack_user_id", "user_name", "team_id", "channel_id", "account_key", "track", "track_uri", "detail"})
+		for _, entry := range chronological {
+			row := []string{entry.Action, entry.SlackUserID, entry.UserName, entry.TeamID, entry.ChannelID, entry.AccountKey, entry.Track, entry.TrackURI, entry.Detail}
+			for i, cell := range row {
+				row[i] = csvText(cell)
+			}
+			w.Write(append([]string{entry.At.UTC().Format(time.RFC3339)}, row...))
+		}
+		w.Flush()
+		if err := w.Error(); err != nil {
+			return nil, fmt.Errorf("failed to encode history: %w", err)
+		}
+		return buf.Bytes(), nil
+	}
+	return nil, fmt.Errorf("unknown export format: %s", format)
+}
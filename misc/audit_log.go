@@ -0,0 +1,164 @@
+package misc
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/slack-go/slack"
+)
+
+// Playback actions recorded in the audit trail
+const (
+	AuditSkip     = "skip"
+	AuditSkipVote = "skip_vote"
+	AuditPrevious = "previous"
+	AuditPause    = "pause"
+	AuditPlay     = "play"
+	AuditQueueAdd = "queue_add"
+	AuditTransfer = "transfer"
+	AuditSave     = "save"
+)
+
+// AuditEntry records who did what to which account's playback, where and when
+type AuditEntry struct {
+	At          time.Time `json:"at"`
+	Action      string    `json:"action"`
+	SlackUserID string    `json:"slack_user_id"`
+	UserName    string    `json:"user_name"`
+	TeamID      string    `json:"team_id"`
+	ChannelID   string    `json:"channel_id"`
+	AccountKey  string    `json:"account_key"`
+	Track       string    `json:"track"`     // e.g. "Dancing Queen by ABBA", empty if nothing was playing
+	TrackURI    string    `json:"track_uri"` // empty when the track is unknown
+	Detail      string    `json:"detail"`    // e.g. the device playback moved to
+}
+
+// NewAuditEntry starts the entry of an action the user of the interaction took on the account's playback
+func NewAuditEntry(action string, interaction slack.InteractionCallback, accountKey string) AuditEntry {
+	return AuditEntry{
+		At:          time.Now(),
+		Action:      action,
+		SlackUserID: interaction.User.ID,
+		UserName:    interaction.User.Name,
+		TeamID:      interaction.Team.ID,
+		ChannelID:   interaction.Channel.ID,
+		AccountKey:  accountKey,
+	}
+}
+
+// WithTrack names the track the action was taken on
+func (e AuditEntry) WithTrack(track CurrentPlayingTrackResponse) AuditEntry {
+	e.TrackURI = track.TrackURI
+	e.Track = track.Song
+	if track.Song != "" && track.Artist != "" {
+		e.Track = fmt.Sprintf("%s by %s", track.Song, track.Artist)
+	}
+	return e
+}
+
+// AuditQuery selects entries of the audit trail
+type AuditQuery struct {
+	TeamID      string    // only actions taken in this workspace, if set
+	ChannelID   string    // only actions taken in this channel, if set
+	SlackUserID string    // only this user's actions, if set
+	UserName    string    // only the actions of the user with this name, if set
+	Since       time.Time // only actions taken since, if set
+	Limit       int       // at most this many of the newest entries, all if 0
+}
+
+// matches reports whether entry is selected by the query, Since and Limit aside
+func (q AuditQuery) matches(entry AuditEntry) bool {
+	if q.TeamID != "" && entry.TeamID != q.TeamID {
+		return false
+	}
+	if q.ChannelID != "" && entry.ChannelID != q.ChannelID {
+		return false
+	}
+	if q.SlackUserID != "" && entry.SlackUserID != q.SlackUserID {
+		return false
+	}
+	return q.UserName == "" || entry.UserName == q.UserName
+}
+
+// AuditStore keeps the audit trail of playback actions
+type AuditStore interface {
+	// Append records an entry and forgets the entries older than the store's retention
+	Append(entry AuditEntry) error
+	// Query returns the selected entries, newest first
+	Query(query AuditQuery) ([]AuditEntry, error)
+	Close() error
+}
+
+// AuditTrail is where the handlers record playback actions, main replaces it with the configured store
+var AuditTrail AuditStore = NewMemoryAuditStore(0, 0)
+
+// RecordAudit appends entry to the audit trail. A failure is only logged, the action itself went through.
+func RecordAudit(entry AuditEntry) {
+	if err := AuditTrail.Append(entry); err != nil {
+		log.Printf("failed to record %s by %s in the audit trail: %v", entry.Action, entry.UserName, err)
+	}
+}
+
+// NewAuditStore builds the audit store selected by config.AuditStoreType:
+// "bolt" for an embedded BoltDB database, anything else keeps the trail in memory only
+func NewAuditStore(config Config) (AuditStore, error) {
+	switch config.AuditStoreType {
+	case "bolt":
+		return NewBoltAuditStore(config.AuditStorePath, config.AuditRetention())
+	case "", "memory":
+		return NewMemoryAuditStore(config.AuditRetention(), memoryAuditLimit), nil
+	}
+	return nil, fmt.Errorf("unknown audit store type: %s", config.AuditStoreType)
+}
+
+// memoryAuditLimit is how many entries the in-memory trail keeps at most
+const memoryAuditLimit = 10000
+
+var auditTrailBucket = []byte("audit_trail")
+
+// timeLogAuditStore keeps the audit trail in a time log
+type timeLogAuditStore struct {
+	log timeLog[AuditEntry]
+}
+
+// NewMemoryAuditStore creates an empty trail in memory, gone after a restart, that keeps entries
+// for retention and at most limit of them, 0 lifts either bound
+func NewMemoryAuditStore(retention time.Duration, limit int) AuditStore {
+	return timeLogAuditStore{newMemoryTimeLog[AuditEntry](retention, limit)}
+}
+
+// NewBoltAuditStore opens (or creates) the trail in the BoltDB database at path, keeping entries
+// for retention, forever if 0
+func NewBoltAuditStore(path string, retention time.Duration) (AuditStore, error) {
+	if path == "" {
+		return nil, fmt.Errorf("audit store path is not configured")
+	}
+	trail, err := openBoltTimeLog[AuditEntry](path, auditTrailBucket, retention)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open audit store: %w", err)
+	}
+	return timeLogAuditStore{trail}, nil
+}
+
+func (s timeLogAuditStore) Append(entry AuditEntry) error {
+	return s.log.append(entry.At, entry)
+}
+
+func (s timeLogAuditStore) Query(query AuditQuery) ([]AuditEntry, error) {
+	var entries []AuditEntry
+	err := s.log.newest(query.Since, func(entry AuditEntry) bool {
+		if query.matches(entry) {
+			entries = append(entries, entry)
+		}
+		return query.Limit <= 0 || len(entries) < query.Limit
+	})
+	if err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
+
+func (s timeLogAuditStore) Close() error {
+	return s.log.close()
+}
@@ -0,0 +1,111 @@
+package misc
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestAuditStores(t *testing.T) {
+	bolt, err := NewBoltAuditStore(filepath.Join(t.TempDir(), "audit.db"), 30*24*time.Hour)
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	defer bolt.Close()
+
+	for name, store := range map[string]AuditStore{"memory": NewMemoryAuditStore(30*24*time.Hour, 100), "bolt": bolt} {
+		t.Run(name, func(t *testing.T) {
+			now := time.Now()
+			for _, entry := range []AuditEntry{
+				{At: now.Add(-60 * 24 * time.Hour), Action: AuditSkip, SlackUserID: "U1"}, // past retention
+				{At: now.Add(-3 * time.Hour), Action: AuditSkip, SlackUserID: "U1", UserName: "alice", TeamID: "T1", ChannelID: "C1"},
+				{At: now.Add(-2 * time.Hour), Action: AuditPause, SlackUserID: "U2", UserName: "bob", TeamID: "T1", ChannelID: "C2"},
+				{At: now.Add(-time.Hour), Action: AuditSkip, SlackUserID: "U1", UserName: "alice", TeamID: "T2", ChannelID: "C1"},
+			} {
+				if err := store.Append(entry); err != nil {
+					t.Fatalf("Append: %v", err)
+				}
+			}
+
+			tests := []struct {
+				query AuditQuery
+				want  string
+			}{
+				{AuditQuery{}, "skip,pause,skip"},
+				{AuditQuery{SlackUserID: "U1"}, "skip,skip"},
+				{AuditQuery{UserName: "bob"}, "pause"},
+				{AuditQuery{TeamID: "T1"}, "pause,skip"},
+				{AuditQuery{TeamID: "T1", ChannelID: "C1"}, "skip"},
+				{AuditQuery{Since: now.Add(-150 * time.Minute)}, "skip,pause"},
+				{AuditQuery{Limit: 1}, "skip"},
+			}
+			for _, tt := range tests {
+				entries, err := store.Query(tt.query)
+				if err != nil {
+					t.Fatalf("Query(%+v): %v", tt.query, err)
+				}
+				var actions []string
+				for _, entry := range entries {
+					actions = append(actions, entry.Action)
+				}
+				if got := strings.Join(actions, ","); got != tt.want {
+					t.Errorf("Query(%+v) = %s, want %s", tt.query, got, tt.want)
+				}
+			}
+			if entries, _ := store.Query(AuditQuery{Limit: 1}); len(entries) != 1 || !entries[0].At.Equal(now.Add(-time.Hour)) {
+				t.Errorf("the newest entry should come first, got %+v", entries)
+			}
+		})
+	}
+}
+
+func TestParseHistoryRequest(t *testing.T) {
+	now := time.Date(2024, 5, 10, 12, 0, 0, 0, time.UTC)
+
+	request, err := ParseHistoryRequest("<@U123|alice> 3d CSV", now)
+	if err != nil {
+		t.Fatalf("ParseHistoryRequest: %v", err)
+	}
+	if request.Query.SlackUserID != "U123" || !request.Query.Since.Equal(now.Add(-72*time.Hour)) || request.Format != "csv" {
+		t.Errorf("parsed %+v", request)
+	}
+
+	request, err = ParseHistoryRequest("2024-05-01 @bob", now)
+	if err != nil {
+		t.Fatalf("ParseHistoryRequest: %v", err)
+	}
+	if request.Query.UserName != "bob" || !request.Query.Since.Equal(time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)) || request.Format != "" {
+		t.Errorf("parsed %+v", request)
+	}
+
+	if _, err := ParseHistoryRequest("yesterday", now); KindOf(err) != ErrorKindUser {
+		t.Errorf("unknown argument: err=%v, want a user error", err)
+	}
+}
+
+func TestExportAudit(t *testing.T) {
+	at := time.Date(2024, 5, 10, 12, 0, 0, 0, time.UTC)
+	entries := []AuditEntry{
+		{At: at.Add(2 * time.Minute), Action: AuditQueueAdd, SlackUserID: "U3", UserName: "@carol", Track: "=HYPERLINK(\"http://evil\") by -DJ"},
+		{At: at.Add(time.Minute), Action: AuditTransfer, SlackUserID: "U2", UserName: "bob", Detail: "Office speaker"},
+		{At: at, Action: AuditSkip, SlackUserID: "U1", UserName: "alice", TeamID: "T1", ChannelID: "C1", Track: "Dancing Queen by ABBA, live", TrackURI: "spotify:track:1"},
+	}
+
+	data, err := ExportAudit(entries, "csv")
+	if err != nil {
+		t.Fatalf("ExportAudit: %v", err)
+	}
+	want := "at,action,slack_user_id,user_name,team_id,channel_id,account_key,track,track_uri,detail\n" +
+		"2024-05-10T12:00:00Z,skip,U1,alice,T1,C1,,\"Dancing Queen by ABBA, live\",spotify:track:1,\n" +
+		"2024-05-10T12:01:00Z,transfer,U2,bob,,,,,,Office speaker\n" +
+		"2024-05-10T12:02:00Z,queue_add,U3,'@carol,,,,\"'=HYPERLINK(\"\"http://evil\"\") by -DJ\",,\n"
+	if string(data) != want {
+		t.Errorf("csv =\n%s\nwant\n%s", data, want)
+	}
+
+	data, err = ExportAudit(entries, "json")
+	if err != nil || !strings.HasPrefix(string(data), "[\n  {\n    \"at\": \"2024-05-10T12:00:00Z\",\n    \"action\": \"skip\"") {
+		t.Errorf("json = %s, err=%v", data, err)
+	}
+}
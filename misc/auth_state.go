@@ -0,0 +1,109 @@
+package misc
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"sync"
+	"time"
+)
+
+// authStateTTL is how long a user has to finish the Spotify login after asking for it
+const authStateTTL = 10 * time.Minute
+
+var (
+	ErrUnknownAuthState = errors.New("unknown oauth state")
+	ErrAuthStateReused  = errors.New("oauth state was already used")
+	ErrAuthStateExpired = errors.New("oauth state expired, run /spotify-auth again")
+)
+
+// AuthState is an issued OAuth state and what it was issued for
+type AuthState struct {
+	State        string
+	AccountKey   string // token key the resulting token is stored under
+	SlackUserID  string // empty for logins started from the /login page
+	CodeVerifier string // PKCE verifier, empty when PKCE is disabled
+	ExpiresAt    time.Time
+	used         bool
+}
+
+// CodeChallenge returns the S256 PKCE challenge for the state's verifier
+func (a AuthState) CodeChallenge() string {
+	sum := sha256.Sum256([]byte(a.CodeVerifier))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+// AuthStateStore records issued OAuth states so the callback only accepts states we handed out, once
+type AuthStateStore struct {
+	states map[string]*AuthState
+	mutex  sync.Mutex
+}
+
+// AuthStates holds the states issued by /spotify-auth and the /login page
+var AuthStates = NewAuthStateStore()
+
+// NewAuthStateStore creates an empty store
+func NewAuthStateStore() *AuthStateStore {
+	return &AuthStateStore{states: map[string]*AuthState{}}
+}
+
+// Issue creates a new state bound to the given account and Slack user,
+// with a PKCE code verifier when withPKCE is set
+func (s *AuthStateStore) Issue(accountKey string, slackUserID string, withPKCE bool) (AuthState, error) {
+	state, err := generateRandomString(16)
+	if err != nil {
+		return AuthState{}, err
+	}
+
+	authState := &AuthState{
+		State:       state,
+		AccountKey:  accountKey,
+		SlackUserID: slackUserID,
+		ExpiresAt:   time.Now().Add(authStateTTL),
+	}
+	if withPKCE {
+		// 64 random bytes encode to an 86 character verifier, within the 43-128 RFC 7636 allows
+		verifier := make([]byte, 64)
+		if _, err := rand.Read(verifier); err != nil {
+			return AuthState{}, err
+		}
+		authState.CodeVerifier = base64.RawURLEncoding.EncodeToString(verifier)
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.purgeExpired()
+	s.states[state] = authState
+	return *authState, nil
+}
+
+// Consume validates a state returned to the callback and marks it as used
+func (s *AuthStateStore) Consume(state string) (AuthState, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	authState, ok := s.states[state]
+	if !ok {
+		return AuthState{}, ErrUnknownAuthState
+	}
+	if authState.used {
+		return AuthState{}, ErrAuthStateReused
+	}
+	if time.Now().After(authState.ExpiresAt) {
+		delete(s.states, state)
+		return AuthState{}, ErrAuthStateExpired
+	}
+	// Used states are kept until they expire so a replay is reported as such
+	authState.used = true
+	return *authState, nil
+}
+
+func (s *AuthStateStore) purgeExpired() {
+	now := time.Now()
+	for state, authState := range s.states {
+		if now.After(authState.ExpiresAt) {
+			delete(s.states, state)
+		}
+	}
+}
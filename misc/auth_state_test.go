@@ -0,0 +1,56 @@
+package misc
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestAuthStateStoreConsume(t *testing.T) {
+	store := NewAuthStateStore()
+	issued, err := store.Issue("T1:alice", "alice", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if issued.CodeVerifier != "" {
+		t.Error("issued a code verifier without PKCE")
+	}
+
+	got, err := store.Consume(issued.State)
+	if err != nil {
+		t.Fatalf("Consume: %v", err)
+	}
+	if got.AccountKey != "T1:alice" || got.SlackUserID != "alice" {
+		t.Errorf("consumed %+v", got)
+	}
+
+	if _, err := store.Consume(issued.State); !errors.Is(err, ErrAuthStateReused) {
+		t.Errorf("second Consume error = %v, want ErrAuthStateReused", err)
+	}
+	if _, err := store.Consume("forged"); !errors.Is(err, ErrUnknownAuthState) {
+		t.Errorf("forged Consume error = %v, want ErrUnknownAuthState", err)
+	}
+}
+
+func TestAuthStateStoreRejectsExpiredState(t *testing.T) {
+	store := NewAuthStateStore()
+	issued, _ := store.Issue("T1:alice", "alice", false)
+	store.states[issued.State].ExpiresAt = time.Now().Add(-time.Second)
+
+	if _, err := store.Consume(issued.State); !errors.Is(err, ErrAuthStateExpired) {
+		t.Errorf("Consume error = %v, want ErrAuthStateExpired", err)
+	}
+}
+
+func TestAuthStateCodeChallenge(t *testing.T) {
+	// Example from RFC 7636 appendix B
+	state := AuthState{CodeVerifier: "dBjftJeZ4CVP-mB92K27uhbUJU1p1r_wW1gFWFOEjXk"}
+	if got, want := state.CodeChallenge(), "E9Melhoa2OwvFrEMTJguCHaoeK1t8URWbuGJSstw-cM"; got != want {
+		t.Errorf("CodeChallenge = %q, want %q", got, want)
+	}
+
+	issued, _ := NewAuthStateStore().Issue("shared", "", true)
+	if n := len(issued.CodeVerifier); n < 43 || n > 128 {
+		t.Errorf("code verifier length %d outside 43-128", n)
+	}
+}
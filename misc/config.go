@@ -1,6 +1,11 @@
 package misc
 
 import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
 	"github.com/spf13/viper"
 )
 
@@ -17,9 +22,158 @@ type Config struct {
 	SpotifyAuthorizeScopesString        string `mapstructure:"SPOTIFY_AUTHORIZE_SCOPES_STRING"`
 	SpotifyAccessTokenUrl               string `mapstructure:"SPOTIFY_ACCESS_TOKEN_URL"`
 	SpotifyAuthSuccessUrl               string `mapstructure:"SPOTIFY_AUTH_SUCCESS_URL"`
+	SpotifyApiBaseUrl                   string `mapstructure:"SPOTIFY_API_BASE_URL"`
+	SpotifyAccountMode                  string `mapstructure:"SPOTIFY_ACCOUNT_MODE"`
+	SpotifyUsePkce                      bool   `mapstructure:"SPOTIFY_USE_PKCE"`
+	SpotifyDefaultDevice                string `mapstructure:"SPOTIFY_DEFAULT_DEVICE"`
+	SpotifyTeamPlaylist                 string `mapstructure:"SPOTIFY_TEAM_PLAYLIST"`
 	SpotifyBuiltAuthUrlShortenedDefault string `mapstructure:"SPOTIFY_BUILT_AUTH_URL_SHORTENED_DEFAULT"`
 	TinyUrlAccessToken                  string `mapstructure:"TINYURL_ACCESS_TOKEN"`
 	TinyUrlApiCreateUrl                 string `mapstructure:"TINYURL_API_CREATE_URL"`
+	TokenStoreType                      string `mapstructure:"TOKEN_STORE_TYPE"`
+	TokenStorePath                      string `mapstructure:"TOKEN_STORE_PATH"`
+	TokenStoreEncryptionKey             string `mapstructure:"TOKEN_STORE_ENCRYPTION_KEY"`
+	HandlerWorkerCount                  int    `mapstructure:"HANDLER_WORKER_COUNT"`
+	HandlerQueueLength                  int    `mapstructure:"HANDLER_QUEUE_LENGTH"`
+	HandlerTimeoutSeconds               int    `mapstructure:"HANDLER_TIMEOUT_SECONDS"`
+	DashboardPollIntervalSeconds        int    `mapstructure:"DASHBOARD_POLL_INTERVAL_SECONDS"`
+	DashboardIdleTimeoutMinutes         int    `mapstructure:"DASHBOARD_IDLE_TIMEOUT_MINUTES"`
+	DashboardRetentionHours             int    `mapstructure:"DASHBOARD_RETENTION_HOURS"`
+	DashboardsPerChannel                int    `mapstructure:"DASHBOARDS_PER_CHANNEL"`
+	DashboardStoreType                  string `mapstructure:"DASHBOARD_STORE_TYPE"`
+	DashboardStorePath                  string `mapstructure:"DASHBOARD_STORE_PATH"`
+	SkipVotesRequired                   int    `mapstructure:"SKIP_VOTES_REQUIRED"`
+	SkipVotePercent                     int    `mapstructure:"SKIP_VOTE_PERCENT"`
+	SkipVoteWindowMinutes               int    `mapstructure:"SKIP_VOTE_WINDOW_MINUTES"`
+	DJQueueUserLimit                    int    `mapstructure:"DJ_QUEUE_USER_LIMIT"`
+	AuditStoreType                      string `mapstructure:"AUDIT_STORE_TYPE"`
+	AuditStorePath                      string `mapstructure:"AUDIT_STORE_PATH"`
+	AuditRetentionDays                  int    `mapstructure:"AUDIT_RETENTION_DAYS"`
+	PlayHistoryStoreType                string `mapstructure:"PLAY_HISTORY_STORE_TYPE"`
+	PlayHistoryStorePath                string `mapstructure:"PLAY_HISTORY_STORE_PATH"`
+	PlayHistoryRetentionDays            int    `mapstructure:"PLAY_HISTORY_RETENTION_DAYS"`
+	WeeklyReportDay                     string `mapstructure:"WEEKLY_REPORT_DAY"`
+	WeeklyReportHour                    string `mapstructure:"WEEKLY_REPORT_HOUR"`
+}
+
+// HandlerWorkers returns how many handlers may run at once, 8 by default
+func (c Config) HandlerWorkers() int {
+	if c.HandlerWorkerCount > 0 {
+		return c.HandlerWorkerCount
+	}
+	return 8
+}
+
+// HandlerQueueSize returns how many requests may wait for a worker, 64 by default
+func (c Config) HandlerQueueSize() int {
+	if c.HandlerQueueLength > 0 {
+		return c.HandlerQueueLength
+	}
+	return 64
+}
+
+// HandlerTimeout returns how long a single handler may run, 30 seconds by default
+func (c Config) HandlerTimeout() time.Duration {
+	if c.HandlerTimeoutSeconds > 0 {
+		return time.Duration(c.HandlerTimeoutSeconds) * time.Second
+	}
+	return 30 * time.Second
+}
+
+// DashboardPollInterval returns the longest pause between two dashboard polls, 10 seconds by default
+func (c Config) DashboardPollInterval() time.Duration {
+	if c.DashboardPollIntervalSeconds > 0 {
+		return time.Duration(c.DashboardPollIntervalSeconds) * time.Second
+	}
+	return 10 * time.Second
+}
+
+// DashboardIdleTimeout returns how long a dashboard is polled without any activity, 30 minutes by default
+func (c Config) DashboardIdleTimeout() time.Duration {
+	if c.DashboardIdleTimeoutMinutes > 0 {
+		return time.Duration(c.DashboardIdleTimeoutMinutes) * time.Minute
+	}
+	return 30 * time.Minute
+}
+
+// DashboardRetention returns how long an unused dashboard keeps its controls, 24 hours by default
+func (c Config) DashboardRetention() time.Duration {
+	if c.DashboardRetentionHours > 0 {
+		return time.Duration(c.DashboardRetentionHours) * time.Hour
+	}
+	return 24 * time.Hour
+}
+
+// DashboardChannelLimit returns how many dashboards of a channel stay interactive, 1 by default
+func (c Config) DashboardChannelLimit() int {
+	if c.DashboardsPerChannel > 0 {
+		return c.DashboardsPerChannel
+	}
+	return 1
+}
+
+// SkipVotePolicy returns how skipping a track on a dashboard is put to a vote. It is off unless
+// SKIP_VOTES_REQUIRED or SKIP_VOTE_PERCENT is set, participants count for 15 minutes by default.
+func (c Config) SkipVotePolicy() SkipVotePolicy {
+	window := 15 * time.Minute
+	if c.SkipVoteWindowMinutes > 0 {
+		window = time.Duration(c.SkipVoteWindowMinutes) * time.Minute
+	}
+	return SkipVotePolicy{
+		Threshold: c.SkipVotesRequired,
+		Percent:   min(c.SkipVotePercent, 100),
+		Window:    window,
+	}
+}
+
+// DJQueuePerUser returns how many requests one person may have waiting in the DJ queue, 3 by default
+func (c Config) DJQueuePerUser() int {
+	if c.DJQueueUserLimit > 0 {
+		return c.DJQueueUserLimit
+	}
+	return 3
+}
+
+// AuditRetention returns how long playback actions stay in the audit trail, 90 days by default
+func (c Config) AuditRetention() time.Duration {
+	if c.AuditRetentionDays > 0 {
+		return time.Duration(c.AuditRetentionDays) * 24 * time.Hour
+	}
+	return 90 * 24 * time.Hour
+}
+
+// PlayHistoryRetention returns how long played tracks are remembered for the listening reports, a year by default
+func (c Config) PlayHistoryRetention() time.Duration {
+	if c.PlayHistoryRetentionDays > 0 {
+		return time.Duration(c.PlayHistoryRetentionDays) * 24 * time.Hour
+	}
+	return 365 * 24 * time.Hour
+}
+
+// WeeklyReportSchedule returns when the weekly listening report is posted to SlackChannelId,
+// Mondays at 9 by default. The hour is a string so that an unset one can be told from 0, midnight.
+func (c Config) WeeklyReportSchedule() (time.Weekday, int, error) {
+	weekday := time.Monday
+	if c.WeeklyReportDay != "" {
+		found := false
+		for day := time.Sunday; day <= time.Saturday; day++ {
+			if strings.EqualFold(c.WeeklyReportDay, day.String()) {
+				weekday, found = day, true
+			}
+		}
+		if !found {
+			return 0, 0, fmt.Errorf("unknown weekly report day: %s", c.WeeklyReportDay)
+		}
+	}
+	hour := 9
+	if c.WeeklyReportHour != "" {
+		var err error
+		hour, err = strconv.Atoi(strings.TrimSpace(c.WeeklyReportHour))
+		if err != nil || hour < 0 || hour > 23 {
+			return 0, 0, fmt.Errorf("weekly report hour must be between 0 and 23: %s", c.WeeklyReportHour)
+		}
+	}
+	return weekday, hour, nil
 }
 
 // LoadConfig reads config from file or env variables
@@ -0,0 +1,226 @@
+package misc
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/slack-go/slack"
+)
+
+// dashboardKey identifies a dashboard by the message it lives in
+type dashboardKey struct {
+	channelID string
+	timestamp string
+}
+
+// DashboardManager keeps every posted dashboard, so /spotify in one channel
+// leaves the dashboards of other channels alone
+type DashboardManager struct {
+	dashboards map[dashboardKey]*SpotifyDashboard
+	perChannel int
+	wake       chan struct{}
+	store      DashboardStore
+	mutex      sync.Mutex
+}
+
+// Dashboards holds the dashboards posted by /spotify, main replaces it with the configured cap
+var Dashboards = NewDashboardManager(1)
+
+// NewDashboardManager creates a manager that keeps at most perChannel interactive dashboards in a channel
+func NewDashboardManager(perChannel int) *DashboardManager {
+	if perChannel < 1 {
+		perChannel = 1
+	}
+	return &DashboardManager{
+		dashboards: map[dashboardKey]*SpotifyDashboard{},
+		perChannel: perChannel,
+		wake:       make(chan struct{}, 1),
+	}
+}
+
+// Create registers the dashboard posted as message timestamp in channelId. It returns the
+// older dashboards of the channel that no longer fit under the cap, the caller retires them.
+func (m *DashboardManager) Create(cpt CurrentPlayingTrackResponse, timestamp string, channelId string, accountKey string, lastAction string, userName string) (*SpotifyDashboard, []*SpotifyDashboard) {
+	m.mutex.Lock()
+	store := m.store
+	m.mutex.Unlock()
+
+	dashboard := &SpotifyDashboard{wake: m.wake, store: store}
+	dashboard.CreateSpotifyDashboard(cpt, timestamp, channelId, accountKey, lastAction, userName)
+
+	m.mutex.Lock()
+	m.dashboards[dashboardKey{channelId, timestamp}] = dashboard
+	keys, retired := m.trim(channelId)
+	m.mutex.Unlock()
+
+	forget(store, keys)
+	return dashboard, retired
+}
+
+// trim removes the oldest dashboards of channelId that no longer fit under the cap and returns them
+// with their keys. It only reads the keys, a dashboard's lock may be held through slow Spotify and
+// Slack calls. The caller holds m.mutex.
+func (m *DashboardManager) trim(channelId string) ([]dashboardKey, []*SpotifyDashboard) {
+	var keys []dashboardKey
+	for key := range m.dashboards {
+		if key.channelID == channelId {
+			keys = append(keys, key)
+		}
+	}
+	if len(keys) <= m.perChannel {
+		return nil, nil
+	}
+
+	// Slack timestamps of one channel sort in posting order, newest last
+	sort.Slice(keys, func(i, j int) bool {
+		return keys[i].timestamp < keys[j].timestamp
+	})
+	keys = keys[:len(keys)-m.perChannel]
+	retired := make([]*SpotifyDashboard, len(keys))
+	for i, key := range keys {
+		retired[i] = m.dashboards[key]
+		delete(m.dashboards, key)
+	}
+	return keys, retired
+}
+
+// Get returns the dashboard posted as message timestamp in channelId
+func (m *DashboardManager) Get(channelId string, timestamp string) (*SpotifyDashboard, bool) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	d, ok := m.dashboards[dashboardKey{channelId, timestamp}]
+	return d, ok
+}
+
+// ForInteraction returns the dashboard whose button was clicked
+func (m *DashboardManager) ForInteraction(interaction slack.InteractionCallback) (*SpotifyDashboard, error) {
+	channelId, timestamp := interaction.Container.ChannelID, interaction.Container.MessageTs
+	if channelId == "" || timestamp == "" {
+		channelId, timestamp = interaction.Channel.ID, interaction.Message.Timestamp
+	}
+	d, ok := m.Get(channelId, timestamp)
+	if !ok {
+		return nil, UserError("This dashboard is no longer live. Run /spotify for a fresh one!")
+	}
+	return d, nil
+}
+
+// Remove forgets dashboard, e.g. after its message was deleted
+func (m *DashboardManager) Remove(dashboard *SpotifyDashboard) {
+	key := dashboardKey{dashboard.GetChannelId(), dashboard.GetMessageTimestamp()}
+	m.mutex.Lock()
+	store := m.store
+	removed := m.dashboards[key] == dashboard
+	if removed {
+		delete(m.dashboards, key)
+	}
+	m.mutex.Unlock()
+
+	if removed {
+		forget(store, []dashboardKey{key})
+	}
+}
+
+// forget deletes dashboards from the store. Call it without holding m.mutex, it does I/O.
+func forget(store DashboardStore, keys []dashboardKey) {
+	if store == nil {
+		return
+	}
+	for _, key := range keys {
+		if err := store.Delete(key.channelID, key.timestamp); err != nil {
+			log.Printf("failed to delete spotify dashboard %s/%s: %v", key.channelID, key.timestamp, err)
+		}
+	}
+}
+
+// SetStore sets where dashboards are persisted, call it before LoadDashboards
+func (m *DashboardManager) SetStore(store DashboardStore) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.store = store
+}
+
+// LoadDashboards restores the dashboards saved by a previous run, so their buttons keep working
+// and the poller picks them up where it left off. A channel keeps only its newest dashboards that
+// fit under the cap, the older ones are deleted from the store and returned, the caller retires them.
+func (m *DashboardManager) LoadDashboards() ([]*SpotifyDashboard, error) {
+	m.mutex.Lock()
+	store := m.store
+	m.mutex.Unlock()
+
+	if store == nil {
+		return nil, nil
+	}
+	records, err := store.Load()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load spotify dashboards: %w", err)
+	}
+
+	m.mutex.Lock()
+	channels := map[string]bool{}
+	for _, record := range records {
+		channels[record.ChannelId] = true
+		m.dashboards[dashboardKey{record.ChannelId, record.MessageTimestamp}] = &SpotifyDashboard{
+			Artist:                record.Artist,
+			Song:                  record.Song,
+			TrackURI:              record.TrackURI,
+			ImageURL:              record.ImageURL,
+			SlackMessageTimestamp: record.MessageTimestamp,
+			SlackChannelId:        record.ChannelId,
+			IsPlaying:             record.IsPlaying,
+			DeviceId:              record.DeviceId,
+			AccountKey:            record.AccountKey,
+			LastAction:            record.LastAction,
+			LastUserName:          record.LastUserName,
+			lastActivity:          record.LastActivity,
+			wake:                  m.wake,
+			store:                 store,
+		}
+	}
+	var extra []dashboardKey
+	var retired []*SpotifyDashboard
+	for channelId := range channels {
+		keys, dashboards := m.trim(channelId)
+		extra = append(extra, keys...)
+		retired = append(retired, dashboards...)
+	}
+	m.mutex.Unlock()
+
+	forget(store, extra)
+	return retired, nil
+}
+
+// All lists the dashboards, oldest first
+func (m *DashboardManager) All() []*SpotifyDashboard {
+	m.mutex.Lock()
+	dashboards := make([]*SpotifyDashboard, 0, len(m.dashboards))
+	for _, d := range m.dashboards {
+		dashboards = append(dashboards, d)
+	}
+	m.mutex.Unlock()
+
+	sort.Slice(dashboards, func(i, j int) bool {
+		return dashboards[i].GetMessageTimestamp() < dashboards[j].GetMessageTimestamp()
+	})
+	return dashboards
+}
+
+// Stale removes and returns the dashboards nobody used for maxIdle, the caller retires them
+func (m *DashboardManager) Stale(maxIdle time.Duration) []*SpotifyDashboard {
+	var stale []*SpotifyDashboard
+	for _, d := range m.All() {
+		if !d.Active(maxIdle) {
+			m.Remove(d)
+			stale = append(stale, d)
+		}
+	}
+	return stale
+}
+
+// Woken receives after any dashboard was posted or clicked
+func (m *DashboardManager) Woken() <-chan struct{} {
+	return m.wake
+}
@@ -0,0 +1,60 @@
+package misc
+
+import (
+	"testing"
+	"time"
+
+	"github.com/slack-go/slack"
+)
+
+func TestDashboardManagerKeepsChannelsApart(t *testing.T) {
+	m := NewDashboardManager(1)
+	track := CurrentPlayingTrackResponse{Song: "Dancing Queen"}
+
+	general, retired := m.Create(track, "1700000000.000100", "C-general", "T1:alice", "/spotify", "alice")
+	if len(retired) != 0 {
+		t.Fatalf("first dashboard retired %d others", len(retired))
+	}
+	random, retired := m.Create(track, "1700000000.000200", "C-random", "T1:bob", "/spotify", "bob")
+	if len(retired) != 0 {
+		t.Fatalf("a dashboard in another channel retired %d others", len(retired))
+	}
+
+	var click slack.InteractionCallback
+	click.Container.ChannelID = "C-general"
+	click.Container.MessageTs = "1700000000.000100"
+	if d, err := m.ForInteraction(click); err != nil || d != general {
+		t.Errorf("click in #general resolved to %v, %v", d, err)
+	}
+
+	newer, retired := m.Create(track, "1700000000.000300", "C-general", "T1:carol", "/spotify", "carol")
+	if len(retired) != 1 || retired[0] != general {
+		t.Fatalf("retired = %v, want the older #general dashboard", retired)
+	}
+	if _, err := m.ForInteraction(click); KindOf(err) != ErrorKindUser {
+		t.Errorf("click on a retired dashboard: err = %v, want a user error", err)
+	}
+	if all := m.All(); len(all) != 2 || all[0] != random || all[1] != newer {
+		t.Errorf("All() = %v, want the #random and the newer #general dashboard", all)
+	}
+}
+
+func TestDashboardManagerStale(t *testing.T) {
+	m := NewDashboardManager(2)
+	old, _ := m.Create(CurrentPlayingTrackResponse{}, "1.1", "C1", "k", "/spotify", "alice")
+	fresh, _ := m.Create(CurrentPlayingTrackResponse{}, "1.2", "C1", "k", "/spotify", "alice")
+	old.lastActivity = time.Now().Add(-2 * time.Hour)
+
+	if stale := m.Stale(time.Hour); len(stale) != 1 || stale[0] != old {
+		t.Fatalf("Stale = %v, want only the old dashboard", stale)
+	}
+	if all := m.All(); len(all) != 1 || all[0] != fresh {
+		t.Errorf("All() after Stale = %v", all)
+	}
+
+	select {
+	case <-m.Woken():
+	default:
+		t.Error("creating a dashboard should wake the poller")
+	}
+}
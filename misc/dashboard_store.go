@@ -0,0 +1,161 @@
+package misc
+
+import (
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// DashboardRecord is what is persisted of a dashboard to bring it back after a restart
+type DashboardRecord struct {
+	ChannelId        string    `json:"channel_id"`
+	MessageTimestamp string    `json:"message_timestamp"`
+	AccountKey       string    `json:"account_key"`
+	DeviceId         string    `json:"device_id"`
+	Artist           string    `json:"artist"`
+	Song             string    `json:"song"`
+	TrackURI         string    `json:"track_uri"`
+	ImageURL         string    `json:"image_url"`
+	IsPlaying        bool      `json:"is_playing"`
+	LastAction       string    `json:"last_action"`
+	LastUserName     string    `json:"last_user_name"`
+	LastActivity     time.Time `json:"last_activity"`
+}
+
+// key identifies the record by the message it belongs to
+func (r DashboardRecord) key() string {
+	return r.ChannelId + "/" + r.MessageTimestamp
+}
+
+// DashboardStore persists dashboards so their messages stay interactive across restarts
+type DashboardStore interface {
+	// Load returns every stored dashboard
+	Load() ([]DashboardRecord, error)
+	// Save stores or replaces the record of a dashboard message
+	Save(record DashboardRecord) error
+	// Delete removes the record of the dashboard posted as timestamp in channelId, if any
+	Delete(channelId string, timestamp string) error
+	Close() error
+}
+
+// NewDashboardStore builds the dashboard store selected by config.DashboardStoreType:
+// "bolt" for an embedded BoltDB database, anything else keeps dashboards in memory only
+func NewDashboardStore(config Config) (DashboardStore, error) {
+	switch config.DashboardStoreType {
+	case "bolt":
+		return NewBoltDashboardStore(config.DashboardStorePath)
+	case "", "memory":
+		return NewMemoryDashboardStore(), nil
+	}
+	return nil, fmt.Errorf("unknown dashboard store type: %s", config.DashboardStoreType)
+}
+
+// MemoryDashboardStore keeps dashboards in memory, they are gone after a restart
+type MemoryDashboardStore struct {
+	records map[string]DashboardRecord
+	mutex   sync.Mutex
+}
+
+// NewMemoryDashboardStore creates an empty in-memory store
+func NewMemoryDashboardStore() *MemoryDashboardStore {
+	return &MemoryDashboardStore{records: map[string]DashboardRecord{}}
+}
+
+func (s *MemoryDashboardStore) Load() ([]DashboardRecord, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	records := make([]DashboardRecord, 0, len(s.records))
+	for _, record := range s.records {
+		records = append(records, record)
+	}
+	return records, nil
+}
+
+func (s *MemoryDashboardStore) Save(record DashboardRecord) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.records[record.key()] = record
+	return nil
+}
+
+func (s *MemoryDashboardStore) Delete(channelId string, timestamp string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	delete(s.records, DashboardRecord{ChannelId: channelId, MessageTimestamp: timestamp}.key())
+	return nil
+}
+
+func (s *MemoryDashboardStore) Close() error {
+	return nil
+}
+
+var spotifyDashboardsBucket = []byte("spotify_dashboards")
+
+// BoltDashboardStore keeps dashboards in an embedded BoltDB database, one JSON record per message.
+// It needs its own file, BoltDB allows a single open handle per database.
+type BoltDashboardStore struct {
+	db *bolt.DB
+}
+
+// NewBoltDashboardStore opens (or creates) the database at path
+func NewBoltDashboardStore(path string) (*BoltDashboardStore, error) {
+	if path == "" {
+		return nil, fmt.Errorf("dashboard store path is not configured")
+	}
+
+	db, err := bolt.Open(path, 0600, &bolt.Options{Timeout: time.Second})
+	if err != nil {
+		return nil, fmt.Errorf("failed to open dashboard store: %w", err)
+	}
+	err = db.Update(func(tx *bolt.Tx) error {
+		_, err := tx.CreateBucketIfNotExists(spotifyDashboardsBucket)
+		return err
+	})
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to create dashboard bucket: %w", err)
+	}
+	return &BoltDashboardStore{db: db}, nil
+}
+
+func (s *BoltDashboardStore) Load() ([]DashboardRecord, error) {
+	var records []DashboardRecord
+	err := s.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(spotifyDashboardsBucket).ForEach(func(k, v []byte) error {
+			var record DashboardRecord
+			if err := json.Unmarshal(v, &record); err != nil {
+				return fmt.Errorf("failed to decode dashboard %s: %w", k, err)
+			}
+			records = append(records, record)
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return records, nil
+}
+
+func (s *BoltDashboardStore) Save(record DashboardRecord) error {
+	value, err := json.Marshal(record)
+	if err != nil {
+		return fmt.Errorf("failed to encode dashboard: %w", err)
+	}
+	return s.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(spotifyDashboardsBucket).Put([]byte(record.key()), value)
+	})
+}
+
+func (s *BoltDashboardStore) Delete(channelId string, timestamp string) error {
+	key := DashboardRecord{ChannelId: channelId, MessageTimestamp: timestamp}.key()
+	return s.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(spotifyDashboardsBucket).Delete([]byte(key))
+	})
+}
+
+func (s *BoltDashboardStore) Close() error {
+	return s.db.Close()
+}
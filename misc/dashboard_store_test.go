@@ -0,0 +1,92 @@
+package misc
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/slack-go/slack"
+)
+
+func TestDashboardsSurviveRestart(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "dashboards.db")
+
+	store, err := NewBoltDashboardStore(path)
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	m := NewDashboardManager(1)
+	m.SetStore(store)
+	track := CurrentPlayingTrackResponse{Artist: "ABBA", Song: "Dancing Queen", IsPlaying: true, DeviceId: "speaker"}
+	m.Create(track, "1700000000.000100", "C1", "T1:alice", "/spotify", "alice")
+	m.Create(track, "1700000000.000200", "C1", "T1:bob", "/spotify", "bob")
+	store.Close()
+
+	// A fresh process only knows what the store remembers
+	store, err = NewBoltDashboardStore(path)
+	if err != nil {
+		t.Fatalf("reopen: %v", err)
+	}
+	defer store.Close()
+	restarted := NewDashboardManager(1)
+	restarted.SetStore(store)
+	if _, err := restarted.LoadDashboards(); err != nil {
+		t.Fatalf("LoadDashboards: %v", err)
+	}
+
+	all := restarted.All()
+	if len(all) != 1 {
+		t.Fatalf("restored %d dashboards, want only the one that was still live", len(all))
+	}
+	var click slack.InteractionCallback
+	click.Container.ChannelID = "C1"
+	click.Container.MessageTs = "1700000000.000200"
+	d, err := restarted.ForInteraction(click)
+	if err != nil {
+		t.Fatalf("click after restart: %v", err)
+	}
+	if d.GetAccountKey() != "T1:bob" || d.Song != "Dancing Queen" || d.LastUserName != "bob" || d.DeviceId != "speaker" {
+		t.Errorf("restored dashboard = %+v", d.record())
+	}
+	if !d.Active(time.Hour) {
+		t.Error("a restored dashboard should resume polling")
+	}
+}
+
+func TestLoadDashboardsKeepsTheCap(t *testing.T) {
+	store, err := NewBoltDashboardStore(filepath.Join(t.TempDir(), "dashboards.db"))
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	defer store.Close()
+	m := NewDashboardManager(3)
+	m.SetStore(store)
+	track := CurrentPlayingTrackResponse{Song: "Dancing Queen"}
+	m.Create(track, "1700000000.000100", "C1", "T1:alice", "/spotify", "alice")
+	m.Create(track, "1700000000.000200", "C1", "T1:bob", "/spotify", "bob")
+	m.Create(track, "1700000000.000300", "C1", "T1:carol", "/spotify", "carol")
+	m.Create(track, "1700000000.000400", "C2", "T1:dave", "/spotify", "dave")
+
+	// The cap was lowered before the restart
+	restarted := NewDashboardManager(1)
+	restarted.SetStore(store)
+	retired, err := restarted.LoadDashboards()
+	if err != nil {
+		t.Fatalf("LoadDashboards: %v", err)
+	}
+	if len(retired) != 2 || retired[0].GetChannelId() != "C1" || retired[1].GetChannelId() != "C1" {
+		t.Errorf("retired %d dashboards, want the two older ones of C1", len(retired))
+	}
+
+	all := restarted.All()
+	if len(all) != 2 || all[0].GetMessageTimestamp() != "1700000000.000300" || all[1].GetChannelId() != "C2" {
+		t.Fatalf("restored %d dashboards, want the newest of each channel", len(all))
+	}
+	records, err := store.Load()
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if len(records) != 2 {
+		t.Errorf("the store kept %d dashboards, want the extras deleted", len(records))
+	}
+}
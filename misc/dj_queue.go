@@ -0,0 +1,325 @@
+package misc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/slack-go/slack"
+)
+
+// MyQueueRemoveActionID is the action of the buttons /myqueue withdraws a request with
+const MyQueueRemoveActionID = "myqueue_remove"
+
+// djQueueAccount is the request queue of one Spotify account
+type djQueueAccount struct {
+	requests map[string][]QueueRequest // Slack user ID -> their requests, oldest first
+	order    []string                  // requesters with requests, whose turn is next first
+	fed      string                    // URI of the last track handed to Spotify, until it has played
+}
+
+// DJQueue is the bot's own request queue. It hands Spotify's queue one track at a time,
+// taking turns between requesters so nobody can stack the queue with twenty songs.
+type DJQueue struct {
+	accounts map[string]*djQueueAccount
+	perUser  int
+	wake     chan struct{}
+	mutex    sync.Mutex
+}
+
+// DJ is the queue /queue-add and the search modal add to, main replaces it with the configured cap
+var DJ = NewDJQueue(3)
+
+// NewDJQueue creates a queue that holds at most perUser waiting requests of each requester
+func NewDJQueue(perUser int) *DJQueue {
+	return &DJQueue{
+		accounts: map[string]*djQueueAccount{},
+		perUser:  max(perUser, 1),
+		wake:     make(chan struct{}, 1),
+	}
+}
+
+// Request adds a track to the requester's turns and returns its place in line, counting from 1
+func (q *DJQueue) Request(request QueueRequest) (int, error) {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	account := q.accounts[request.AccountKey]
+	if account == nil {
+		account = &djQueueAccount{requests: map[string][]QueueRequest{}}
+		q.accounts[request.AccountKey] = account
+	}
+	mine := account.requests[request.SlackUserID]
+	for _, r := range mine {
+		if r.TrackURI == request.TrackURI {
+			return 0, UserError("That one is already in your requests, see /myqueue.")
+		}
+	}
+	if len(mine) >= q.perUser {
+		return 0, UserError(fmt.Sprintf("You already have %d songs waiting, let the others have a turn! See /myqueue.", q.perUser))
+	}
+	if len(mine) == 0 {
+		account.order = append(account.order, request.SlackUserID)
+	}
+	account.requests[request.SlackUserID] = append(mine, request)
+
+	// Hand the track to Spotify right away if it is first in line
+	select {
+	case q.wake <- struct{}{}:
+	default:
+	}
+
+	upcoming := account.upcoming()
+	for i, r := range upcoming {
+		if r.SlackUserID == request.SlackUserID && r.TrackURI == request.TrackURI {
+			return i + 1, nil
+		}
+	}
+	return len(upcoming), nil
+}
+
+// Upcoming lists the waiting requests of the account in the order they will be played
+func (q *DJQueue) Upcoming(accountKey string) []QueueRequest {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	account := q.accounts[accountKey]
+	if account == nil {
+		return nil
+	}
+	return account.upcoming()
+}
+
+// upcoming takes turns between the requesters, one request each per round. The caller holds q.mutex.
+func (a *djQueueAccount) upcoming() []QueueRequest {
+	var upcoming []QueueRequest
+	for round := 0; ; round++ {
+		added := false
+		for _, userId := range a.order {
+			if round < len(a.requests[userId]) {
+				upcoming = append(upcoming, a.requests[userId][round])
+				added = true
+			}
+		}
+		if !added {
+			return upcoming
+		}
+	}
+}
+
+// Remove withdraws a waiting request of userId, reporting whether there was one
+func (q *DJQueue) Remove(accountKey string, userId string, trackURI string) bool {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	account := q.accounts[accountKey]
+	if account == nil {
+		return false
+	}
+	return account.remove(userId, trackURI, false)
+}
+
+// remove drops a request and, when it was the requester's turn being taken, moves them to the
+// back of the line. The caller holds q.mutex.
+func (a *djQueueAccount) remove(userId string, trackURI string, tookTurn bool) bool {
+	mine := a.requests[userId]
+	for i, r := range mine {
+		if r.TrackURI != trackURI {
+			continue
+		}
+		mine = append(mine[:i:i], mine[i+1:]...)
+		if len(mine) == 0 {
+			delete(a.requests, userId)
+		} else {
+			a.requests[userId] = mine
+		}
+
+		for j, id := range a.order {
+			if id != userId {
+				continue
+			}
+			if len(mine) == 0 || tookTurn {
+				a.order = append(a.order[:j:j], a.order[j+1:]...)
+				if len(mine) > 0 {
+					a.order = append(a.order, userId)
+				}
+			}
+			break
+		}
+		return true
+	}
+	return false
+}
+
+// next returns the request whose turn it is, without taking it off the queue
+func (q *DJQueue) next(accountKey string) (QueueRequest, bool) {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	account := q.accounts[accountKey]
+	if account == nil || len(account.order) == 0 {
+		return QueueRequest{}, false
+	}
+	return account.requests[account.order[0]][0], true
+}
+
+// fed records that request was handed to Spotify, taking it off the queue
+func (q *DJQueue) fed(request QueueRequest) {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	account := q.accounts[request.AccountKey]
+	if account == nil {
+		return
+	}
+	account.remove(request.SlackUserID, request.TrackURI, true)
+	account.fed = request.TrackURI
+}
+
+// played forgets the track handed to Spotify once it played, and accounts with nothing left to do
+func (q *DJQueue) played(accountKey string) {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	account := q.accounts[accountKey]
+	if account == nil {
+		return
+	}
+	account.fed = ""
+	if len(account.order) == 0 {
+		delete(q.accounts, accountKey)
+	}
+}
+
+// lastFed returns the track handed to Spotify that hasn't played yet, if any
+func (q *DJQueue) lastFed(accountKey string) string {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	if account := q.accounts[accountKey]; account != nil {
+		return account.fed
+	}
+	return ""
+}
+
+// accountKeys lists the accounts with requests waiting or a track on its way
+func (q *DJQueue) accountKeys() []string {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	keys := make([]string, 0, len(q.accounts))
+	for key := range q.accounts {
+		keys = append(keys, key)
+	}
+	return keys
+}
+
+// Run feeds Spotify's queues until ctx is done, checking every interval whether the last
+// track handed over has started playing
+func (q *DJQueue) Run(ctx context.Context, interval time.Duration) {
+	for {
+		for _, accountKey := range q.accountKeys() {
+			feedCtx, cancel := context.WithTimeout(ctx, pollTimeout)
+			err := q.feed(feedCtx, accountKey)
+			cancel()
+
+			switch {
+			case err == nil, ctx.Err() != nil:
+			case errors.Is(err, ErrNothingPlaying), errors.Is(err, ErrNoActiveDevice), errors.Is(err, ErrNotAuthenticated):
+				// Nobody is listening, the requests wait until somebody is
+			case isRateLimitError(err):
+				// The client holds back every request until Spotify lets us again
+			default:
+				log.Printf("failed to feed the DJ queue of %s: %v", accountKey, err)
+			}
+		}
+
+		timer := time.NewTimer(interval)
+		select {
+		case <-ctx.Done():
+		case <-q.wake:
+		case <-timer.C:
+		}
+		timer.Stop()
+		if ctx.Err() != nil {
+			return
+		}
+	}
+}
+
+// feed hands the next request to Spotify once the track handed over before started playing,
+// or disappeared from Spotify's queue
+func (q *DJQueue) feed(ctx context.Context, accountKey string) error {
+	spotify := SpotifyForAccount(accountKey)
+
+	if fed := q.lastFed(accountKey); fed != "" {
+		cpt, err := spotify.GetCurrentPlayingTrack(ctx)
+		if err != nil {
+			return fmt.Errorf("GetCurrentPlayingTrack failed with error: %w", err)
+		}
+		if cpt.TrackURI != fed {
+			queue, err := spotify.GetUserQueue(ctx)
+			if err != nil {
+				return fmt.Errorf("GetUserQueue failed with error: %w", err)
+			}
+			for _, item := range queue.Items {
+				if item.URI == fed {
+					// Still waiting for its turn in Spotify's queue
+					return nil
+				}
+			}
+		}
+		q.played(accountKey)
+	}
+
+	request, ok := q.next(accountKey)
+	if !ok {
+		// Nothing left to hand over, forget the account
+		q.played(accountKey)
+		return nil
+	}
+	deviceId, err := spotify.GetActiveDevice(ctx)
+	if err != nil {
+		return fmt.Errorf("GetActiveDevice failed with error: %w", err)
+	}
+	if err := spotify.AddToQueue(ctx, request.TrackURI, deviceId); err != nil {
+		return fmt.Errorf("AddToQueue failed with error: %w", err)
+	}
+	q.fed(request)
+	// Credit the requester on the dashboards once the track plays
+	QueueRequests.Record(request)
+	return nil
+}
+
+// BuildMyQueue lists the waiting requests of userId with their place in line, each with a button
+// that withdraws it
+func BuildMyQueue(upcoming []QueueRequest, userId string) []slack.Block {
+	headerText := slack.NewTextBlockObject(slack.MarkdownType, "*🎧 Your requests in the DJ queue*", false, false)
+	blocks := []slack.Block{slack.NewSectionBlock(headerText, nil, nil)}
+
+	mine := 0
+	for i, request := range upcoming {
+		if request.SlackUserID != userId {
+			continue
+		}
+		name := request.TrackName
+		if name == "" {
+			name = request.TrackURI
+		}
+		requestText := slack.NewTextBlockObject(slack.MarkdownType, fmt.Sprintf("*#%d* in line · %s", i+1, name), false, false)
+		button := slack.NewButtonBlockElement(MyQueueRemoveActionID, request.TrackURI, slack.NewTextBlockObject(slack.PlainTextType, "Remove", false, false))
+		button.Style = slack.StyleDanger
+		blocks = append(blocks, slack.NewSectionBlock(requestText, nil, slack.NewAccessory(button), slack.SectionBlockOptionBlockID(fmt.Sprintf("request_%d", mine))))
+		mine++
+	}
+	if mine == 0 {
+		emptyText := slack.NewTextBlockObject(slack.MarkdownType, "You have no requests waiting. Add some with /queue-add or /spotify-search!", false, false)
+		return append(blocks, slack.NewSectionBlock(emptyText, nil, nil, slack.SectionBlockOptionBlockID("no_requests")))
+	}
+
+	summaryText := slack.NewTextBlockObject(slack.MarkdownType, fmt.Sprintf("%d requests waiting in total, everyone takes turns", len(upcoming)), false, false)
+	return append(blocks, slack.NewContextBlock("queue_summary", summaryText))
+}
@@ -0,0 +1,118 @@
+package misc
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDJQueueTakesTurns(t *testing.T) {
+	q := NewDJQueue(2)
+	request := func(user string, uri string) (int, error) {
+		return q.Request(QueueRequest{AccountKey: "dj-test", SlackUserID: user, TrackURI: uri})
+	}
+
+	for _, r := range []struct{ user, uri string }{{"U1", "a1"}, {"U1", "a2"}, {"U2", "b1"}} {
+		if _, err := request(r.user, r.uri); err != nil {
+			t.Fatalf("Request(%s, %s) returned error: %v", r.user, r.uri, err)
+		}
+	}
+	if position, err := request("U3", "c1"); err != nil || position != 3 {
+		t.Errorf("third requester's first track: position=%d err=%v, want 3", position, err)
+	}
+	if _, err := request("U1", "a3"); KindOf(err) != ErrorKindUser {
+		t.Errorf("request over the cap: err=%v, want a user error", err)
+	}
+	if _, err := request("U2", "b1"); KindOf(err) != ErrorKindUser {
+		t.Errorf("duplicate request: err=%v, want a user error", err)
+	}
+	assertUpcoming(t, q, "a1", "b1", "c1", "a2")
+
+	// Once U1's track is handed over it's the others' turn before U1's next one
+	next, _ := q.next("dj-test")
+	q.fed(next)
+	assertUpcoming(t, q, "b1", "c1", "a2")
+
+	if !q.Remove("dj-test", "U3", "c1") || q.Remove("dj-test", "U3", "c1") {
+		t.Error("Remove should withdraw a waiting request once")
+	}
+	if q.Remove("dj-test", "U2", "a2") {
+		t.Error("Remove should not withdraw somebody else's request")
+	}
+	assertUpcoming(t, q, "b1", "a2")
+}
+
+func assertUpcoming(t *testing.T, q *DJQueue, want ...string) {
+	t.Helper()
+	var got []string
+	for _, r := range q.Upcoming("dj-test") {
+		got = append(got, r.TrackURI)
+	}
+	if strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Errorf("Upcoming = %v, want %v", got, want)
+	}
+}
+
+func TestDJQueueFeed(t *testing.T) {
+	playing, queued := "spotify:track:playing", ""
+	var added []string
+	mux := http.NewServeMux()
+	mux.HandleFunc("/v1/me/player", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"device":{"id":"speaker","is_active":true},"is_playing":true,"item":{"uri":"` + playing + `","name":"Song","artists":[{"name":"Band"}]}}`))
+	})
+	mux.HandleFunc("/v1/me/player/queue", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPost {
+			added = append(added, r.URL.Query().Get("uri"))
+			queued = r.URL.Query().Get("uri")
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		w.Write([]byte(`{"queue":[{"uri":"` + queued + `","name":"Queued","artists":[{"name":"Band"}]}]}`))
+	})
+	mux.HandleFunc("/v1/me/player/devices", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"devices":[{"id":"speaker","is_active":true}]}`))
+	})
+	previous := Spotify
+	Spotify = newTestSpotifyClient(t, mux)
+	t.Cleanup(func() { Spotify = previous })
+	Shared.SetSpotifyTokenFor("dj-feed-test", SpotifyToken{AccessToken: "a"})
+
+	q := NewDJQueue(3)
+	for _, r := range []struct{ user, uri string }{{"U1", "spotify:track:a"}, {"U2", "spotify:track:b"}} {
+		if _, err := q.Request(QueueRequest{AccountKey: "dj-feed-test", SlackUserID: r.user, UserName: r.user, TrackURI: r.uri, RequestedAt: time.Now()}); err != nil {
+			t.Fatalf("Request returned error: %v", err)
+		}
+	}
+
+	feed := func() {
+		t.Helper()
+		if err := q.feed(context.Background(), "dj-feed-test"); err != nil {
+			t.Fatalf("feed returned error: %v", err)
+		}
+	}
+	feed()
+	// The first track still waits in Spotify's queue, the second one has to wait too
+	feed()
+	if strings.Join(added, ",") != "spotify:track:a" {
+		t.Fatalf("added = %v, want only the first request", added)
+	}
+	if got := QueueRequests.RequestedBy("dj-feed-test", "spotify:track:a"); got != "U1" {
+		t.Errorf("RequestedBy = %q, want U1", got)
+	}
+
+	playing = "spotify:track:a"
+	queued = ""
+	feed()
+	if strings.Join(added, ",") != "spotify:track:a,spotify:track:b" {
+		t.Fatalf("added = %v, want the second request once the first one plays", added)
+	}
+
+	playing = "spotify:track:b"
+	feed()
+	feed()
+	if keys := q.accountKeys(); len(keys) != 0 {
+		t.Errorf("accountKeys = %v, want the account forgotten once everything played", keys)
+	}
+}
@@ -0,0 +1,114 @@
+package misc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/slack-go/slack"
+)
+
+// ErrorKind tells who is to blame for a failed request, which decides what the user is told
+type ErrorKind int
+
+const (
+	// ErrorKindInternal is a bug on our side
+	ErrorKindInternal ErrorKind = iota
+	// ErrorKindUser means the user asked for something we cannot do right now
+	ErrorKindUser
+	// ErrorKindUpstream means Slack, Spotify or Reddit failed us
+	ErrorKindUpstream
+)
+
+func (k ErrorKind) String() string {
+	switch k {
+	case ErrorKindUser:
+		return "user"
+	case ErrorKindUpstream:
+		return "upstream"
+	}
+	return "internal"
+}
+
+// ClassifiedError attaches an ErrorKind and a user facing message to an error
+type ClassifiedError struct {
+	Kind    ErrorKind
+	Message string
+	Err     error
+}
+
+func (e *ClassifiedError) Error() string {
+	if e.Err == nil {
+		return e.Message
+	}
+	if e.Message == "" {
+		return e.Err.Error()
+	}
+	return fmt.Sprintf("%s: %v", e.Message, e.Err)
+}
+
+func (e *ClassifiedError) Unwrap() error {
+	return e.Err
+}
+
+// UserError is an error whose message is meant for the user who triggered the request
+func UserError(message string) error {
+	return &ClassifiedError{Kind: ErrorKindUser, Message: message}
+}
+
+// UpstreamError marks err as a failure of the named external service
+func UpstreamError(service string, err error) error {
+	return &ClassifiedError{
+		Kind:    ErrorKindUpstream,
+		Message: fmt.Sprintf("%s is not responding as expected, please try again in a moment", service),
+		Err:     err,
+	}
+}
+
+// KindOf returns the kind of err, internal unless something on the chain says otherwise
+func KindOf(err error) ErrorKind {
+	var classified *ClassifiedError
+	if errors.As(err, &classified) {
+		return classified.Kind
+	}
+	if kind, _, ok := spotifyErrorKind(err); ok {
+		return kind
+	}
+	if errors.Is(err, context.DeadlineExceeded) {
+		return ErrorKindUpstream
+	}
+	return ErrorKindInternal
+}
+
+// UserMessage returns what the user should be told about err
+func UserMessage(err error) string {
+	var classified *ClassifiedError
+	if errors.As(err, &classified) && classified.Message != "" {
+		return classified.Message
+	}
+	if _, message, ok := spotifyErrorKind(err); ok {
+		return message
+	}
+	if errors.Is(err, context.DeadlineExceeded) {
+		return "That took too long, please try again in a moment"
+	}
+	return "Oops, something went wrong on my side. It has been logged so someone can take a look"
+}
+
+// BuildErrorAttachment renders err the same way for every command and interaction:
+// states the user can fix by starting playback in green, everything else in red with a warning
+func BuildErrorAttachment(err error) slack.Attachment {
+	message := UserMessage(err)
+	if errors.Is(err, ErrNothingPlaying) || errors.Is(err, ErrNoActiveDevice) {
+		return slack.Attachment{
+			Color:    "#36a64f", // Green color
+			Text:     message,
+			Fallback: message,
+		}
+	}
+	return slack.Attachment{
+		Color:    "#FF0000", // Red color
+		Text:     ":warning: " + message,
+		Fallback: message,
+	}
+}
@@ -0,0 +1,201 @@
+package misc
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/slack-go/slack"
+)
+
+// reportTopSize is how many artists, tracks, requesters and skipped songs a report ranks
+const reportTopSize = 5
+
+// StatCount is a ranked name in a listening report
+type StatCount struct {
+	Name  string
+	Count int
+}
+
+// ListeningStats sums up what was played through the bot in [From, To)
+type ListeningStats struct {
+	ChannelID     string // the channel the music was played in, set by GatherListeningStats
+	From          time.Time
+	To            time.Time
+	Plays         int
+	ListeningTime time.Duration
+	TopArtists    []StatCount
+	TopTracks     []StatCount
+	TopRequesters []StatCount
+	MostSkipped   []StatCount
+}
+
+// ComputeListeningStats ranks the plays, oldest first, and the audit entries of the period. A track
+// counts as listened to until the next one started on its account, at most for its duration.
+func ComputeListeningStats(plays []PlayRecord, audit []AuditEntry, from time.Time, to time.Time) ListeningStats {
+	stats := ListeningStats{From: from, To: to, Plays: len(plays)}
+	artists, tracks, requesters, skipped := map[string]int{}, map[string]int{}, map[string]int{}, map[string]int{}
+
+	for i, play := range plays {
+		end := to
+		for _, next := range plays[i+1:] {
+			if next.AccountKey == play.AccountKey {
+				end = next.At
+				break
+			}
+		}
+		stats.ListeningTime += min(end.Sub(play.At), time.Duration(play.DurationMs)*time.Millisecond)
+
+		artists[play.Artist]++
+		tracks[fmt.Sprintf("%s by %s", play.Song, play.Artist)]++
+		if play.RequestedBy != "" {
+			requesters[play.RequestedBy]++
+		}
+	}
+	for _, entry := range audit {
+		if entry.Action == AuditSkip && entry.Track != "" {
+			skipped[entry.Track]++
+		}
+	}
+
+	stats.TopArtists = topCounts(artists)
+	stats.TopTracks = topCounts(tracks)
+	stats.TopRequesters = topCounts(requesters)
+	stats.MostSkipped = topCounts(skipped)
+	return stats
+}
+
+// topCounts ranks the names by count, ties alphabetically, keeping the first reportTopSize
+func topCounts(counts map[string]int) []StatCount {
+	ranked := make([]StatCount, 0, len(counts))
+	for name, count := range counts {
+		ranked = append(ranked, StatCount{name, count})
+	}
+	sort.Slice(ranked, func(i, j int) bool {
+		if ranked[i].Count != ranked[j].Count {
+			return ranked[i].Count > ranked[j].Count
+		}
+		return ranked[i].Name < ranked[j].Name
+	})
+	return ranked[:min(len(ranked), reportTopSize)]
+}
+
+// GatherListeningStats reads the plays in channelId and the audit trail of the channel, within the
+// workspace teamId if set, of [from, to) and sums them up. Like /spotify-history, a report never
+// tells what was played or skipped in another channel.
+func GatherListeningStats(teamId string, channelId string, from time.Time, to time.Time) (ListeningStats, error) {
+	plays, err := PlayHistory.Between(from, to)
+	if err != nil {
+		return ListeningStats{}, fmt.Errorf("failed to read the play history: %w", err)
+	}
+	inChannel := plays[:0]
+	for _, play := range plays {
+		if play.ChannelID == channelId {
+			inChannel = append(inChannel, play)
+		}
+	}
+	audit, err := AuditTrail.Query(AuditQuery{TeamID: teamId, ChannelID: channelId, Since: from})
+	if err != nil {
+		return ListeningStats{}, fmt.Errorf("failed to read the audit trail: %w", err)
+	}
+	inPeriod := audit[:0]
+	for _, entry := range audit {
+		if entry.At.Before(to) {
+			inPeriod = append(inPeriod, entry)
+		}
+	}
+	stats := ComputeListeningStats(inChannel, inPeriod, from, to)
+	stats.ChannelID = channelId
+	return stats, nil
+}
+
+// formatListeningTime renders a total such as "12h 5m"
+func formatListeningTime(d time.Duration) string {
+	d = d.Round(time.Minute)
+	if d < time.Hour {
+		return fmt.Sprintf("%dm", int(d.Minutes()))
+	}
+	return fmt.Sprintf("%dh %dm", int(d.Hours()), int(d.Minutes())%60)
+}
+
+// rankingField renders a ranking as a numbered list under its title
+func rankingField(title string, ranking []StatCount, unit string) *slack.TextBlockObject {
+	lines := []string{title}
+	for i, stat := range ranking {
+		lines = append(lines, fmt.Sprintf("%d. %s (%d %s)", i+1, stat.Name, stat.Count, unit))
+	}
+	if len(ranking) == 0 {
+		lines = append(lines, "_none yet_")
+	}
+	return slack.NewTextBlockObject(slack.MarkdownType, strings.Join(lines, "\n"), false, false)
+}
+
+// BuildListeningReport renders the stats as the weekly report and /spotify-stats show them
+func BuildListeningReport(stats ListeningStats) []slack.Block {
+	header := slack.NewHeaderBlock(slack.NewTextBlockObject(slack.PlainTextType, "📊 Office listening report", false, false))
+	periodText := fmt.Sprintf("%s – %s", slackDate(stats.From), slackDate(stats.To))
+	if stats.ChannelID != "" {
+		periodText = fmt.Sprintf("<#%s> · %s", stats.ChannelID, periodText)
+	}
+	period := slack.NewContextBlock("report_period", slack.NewTextBlockObject(slack.MarkdownType, periodText, false, false))
+	blocks := []slack.Block{header, period}
+	if stats.Plays == 0 {
+		emptyText := slack.NewTextBlockObject(slack.MarkdownType, "No music was played through the bot in that time. Run /spotify and get the party started!", false, false)
+		return append(blocks, slack.NewSectionBlock(emptyText, nil, nil, slack.SectionBlockOptionBlockID("no_plays")))
+	}
+
+	totalText := slack.NewTextBlockObject(slack.MarkdownType,
+		fmt.Sprintf("*%d tracks* played, *%s* of music", stats.Plays, formatListeningTime(stats.ListeningTime)), false, false)
+	blocks = append(blocks, slack.NewSectionBlock(totalText, nil, nil, slack.SectionBlockOptionBlockID("report_total")))
+	blocks = append(blocks, slack.NewSectionBlock(nil, []*slack.TextBlockObject{
+		rankingField("*🎤 Top artists*", stats.TopArtists, "plays"),
+		rankingField("*🎵 Top tracks*", stats.TopTracks, "plays"),
+	}, nil, slack.SectionBlockOptionBlockID("report_music")))
+	blocks = append(blocks, slack.NewSectionBlock(nil, []*slack.TextBlockObject{
+		rankingField("*🙋 Top requesters*", stats.TopRequesters, "tracks"),
+		rankingField("*⏩ Most skipped*", stats.MostSkipped, "skips"),
+	}, nil, slack.SectionBlockOptionBlockID("report_people")))
+	return blocks
+}
+
+// nextReportTime returns the first weekday at hour o'clock after now
+func nextReportTime(now time.Time, weekday time.Weekday, hour int) time.Time {
+	next := time.Date(now.Year(), now.Month(), now.Day(), hour, 0, 0, 0, now.Location())
+	next = next.AddDate(0, 0, (int(weekday)-int(now.Weekday())+7)%7)
+	if !next.After(now) {
+		next = next.AddDate(0, 0, 7)
+	}
+	return next
+}
+
+// RunWeeklyReport posts the listening report of the past week in channelId to it every weekday
+// at hour o'clock, until ctx is done
+func RunWeeklyReport(ctx context.Context, client *slack.Client, channelId string, weekday time.Weekday, hour int) {
+	for {
+		at := nextReportTime(time.Now(), weekday, hour)
+		timer := time.NewTimer(time.Until(at))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+
+		stats, err := GatherListeningStats("", channelId, at.AddDate(0, 0, -7), at)
+		if err != nil {
+			log.Printf("failed to gather the weekly listening report: %v", err)
+			continue
+		}
+		postCtx, cancel := context.WithTimeout(ctx, pollTimeout)
+		_, _, err = client.PostMessageContext(postCtx, channelId,
+			slack.MsgOptionText("📊 Office listening report", false),
+			slack.MsgOptionBlocks(BuildListeningReport(stats)...))
+		cancel()
+		if err != nil {
+			log.Printf("failed to post the weekly listening report: %v", err)
+		}
+	}
+}
@@ -0,0 +1,137 @@
+package misc
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestComputeListeningStats(t *testing.T) {
+	from := time.Date(2024, 5, 6, 9, 0, 0, 0, time.UTC)
+	to := from.AddDate(0, 0, 7)
+	plays := []PlayRecord{
+		{At: from, AccountKey: "office", Artist: "ABBA", Song: "Dancing Queen", DurationMs: 230000, RequestedBy: "alice"},
+		// Skipped after a minute
+		{At: from.Add(4 * time.Minute), AccountKey: "office", Artist: "a-ha", Song: "Take On Me", DurationMs: 225000},
+		{At: from.Add(5 * time.Minute), AccountKey: "office", Artist: "ABBA", Song: "Dancing Queen", DurationMs: 230000, RequestedBy: "bob"},
+		// Another account playing at the same time
+		{At: from.Add(6 * time.Minute), AccountKey: "T1:carol", Artist: "ABBA", Song: "Waterloo", DurationMs: 165000, RequestedBy: "alice"},
+	}
+	audit := []AuditEntry{
+		{Action: AuditSkip, Track: "Take On Me by a-ha"},
+		{Action: AuditSkipVote, Track: "Dancing Queen by ABBA"},
+		{Action: AuditPause, Track: "Dancing Queen by ABBA"},
+	}
+
+	stats := ComputeListeningStats(plays, audit, from, to)
+	if stats.Plays != 4 {
+		t.Errorf("Plays = %d, want 4", stats.Plays)
+	}
+	if want := (230 + 60 + 230 + 165) * time.Second; stats.ListeningTime != want {
+		t.Errorf("ListeningTime = %s, want %s", stats.ListeningTime, want)
+	}
+	if want := []StatCount{{"ABBA", 3}, {"a-ha", 1}}; !reflect.DeepEqual(stats.TopArtists, want) {
+		t.Errorf("TopArtists = %v, want %v", stats.TopArtists, want)
+	}
+	if want := []StatCount{{"Dancing Queen by ABBA", 2}, {"Take On Me by a-ha", 1}, {"Waterloo by ABBA", 1}}; !reflect.DeepEqual(stats.TopTracks, want) {
+		t.Errorf("TopTracks = %v, want %v", stats.TopTracks, want)
+	}
+	if want := []StatCount{{"alice", 2}, {"bob", 1}}; !reflect.DeepEqual(stats.TopRequesters, want) {
+		t.Errorf("TopRequesters = %v, want %v", stats.TopRequesters, want)
+	}
+	if want := []StatCount{{"Take On Me by a-ha", 1}}; !reflect.DeepEqual(stats.MostSkipped, want) {
+		t.Errorf("MostSkipped = %v, want %v", stats.MostSkipped, want)
+	}
+}
+
+func TestNextReportTime(t *testing.T) {
+	monday9 := time.Date(2024, 5, 6, 9, 0, 0, 0, time.UTC)
+	tests := []struct {
+		now  time.Time
+		want time.Time
+	}{
+		{monday9.Add(-time.Hour), monday9},
+		{monday9, monday9.AddDate(0, 0, 7)},
+		{monday9.AddDate(0, 0, 3), monday9.AddDate(0, 0, 7)},
+	}
+	for _, tt := range tests {
+		if got := nextReportTime(tt.now, time.Monday, 9); !got.Equal(tt.want) {
+			t.Errorf("nextReportTime(%s) = %s, want %s", tt.now, got, tt.want)
+		}
+	}
+}
+
+func TestWeeklyReportSchedule(t *testing.T) {
+	tests := []struct {
+		config  Config
+		weekday time.Weekday
+		hour    int
+	}{
+		{Config{}, time.Monday, 9},
+		{Config{WeeklyReportDay: "friday", WeeklyReportHour: "17"}, time.Friday, 17},
+		{Config{WeeklyReportHour: "0"}, time.Monday, 0},
+	}
+	for _, tt := range tests {
+		weekday, hour, err := tt.config.WeeklyReportSchedule()
+		if err != nil || weekday != tt.weekday || hour != tt.hour {
+			t.Errorf("WeeklyReportSchedule(%q, %q) = %s, %d, %v, want %s, %d", tt.config.WeeklyReportDay, tt.config.WeeklyReportHour, weekday, hour, err, tt.weekday, tt.hour)
+		}
+	}
+	if _, _, err := (Config{WeeklyReportHour: "24"}).WeeklyReportSchedule(); err == nil {
+		t.Error("hour 24 should be rejected")
+	}
+}
+
+func TestPlayHistoryLog(t *testing.T) {
+	store, err := NewBoltPlayHistoryStore(filepath.Join(t.TempDir(), "plays.db"), 0)
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	defer store.Close()
+	history := NewPlayHistoryLog(store)
+
+	start := time.Now()
+	track := CurrentPlayingTrackResponse{TrackURI: "spotify:track:1", Song: "Dancing Queen", Artist: "ABBA", IsPlaying: true}
+	// Two dashboards of the same account in one channel see the same track
+	history.Observe("C1", "office", track)
+	history.Observe("C1", "office", track)
+	history.Observe("C1", "office", CurrentPlayingTrackResponse{TrackURI: "spotify:track:2", Song: "Waterloo"})
+	history.Observe("C1", "T1:carol", track)
+	history.Observe("C2", "office", track)
+
+	plays, err := history.Between(start, time.Now().Add(time.Second))
+	if err != nil {
+		t.Fatalf("Between: %v", err)
+	}
+	if len(plays) != 3 || plays[0].AccountKey != "office" || plays[1].AccountKey != "T1:carol" || plays[2].ChannelID != "C2" {
+		t.Errorf("plays = %+v, want one play on each account and channel", plays)
+	}
+	if plays, _ := history.Between(start.Add(-time.Hour), start); len(plays) != 0 {
+		t.Errorf("plays before start = %+v, want none", plays)
+	}
+}
+
+func TestGatherListeningStatsStaysInTheChannel(t *testing.T) {
+	previousHistory, previousTrail := PlayHistory, AuditTrail
+	PlayHistory, AuditTrail = NewPlayHistoryLog(NewMemoryPlayHistoryStore(0, 0)), NewMemoryAuditStore(0, 0)
+	t.Cleanup(func() { PlayHistory, AuditTrail = previousHistory, previousTrail })
+
+	start := time.Now()
+	PlayHistory.Observe("C1", "office", CurrentPlayingTrackResponse{TrackURI: "spotify:track:1", Song: "Dancing Queen", Artist: "ABBA", IsPlaying: true})
+	PlayHistory.Observe("C-private", "office", CurrentPlayingTrackResponse{TrackURI: "spotify:track:2", Song: "Waterloo", IsPlaying: true})
+	RecordAudit(AuditEntry{At: time.Now(), Action: AuditSkip, TeamID: "T1", ChannelID: "C1", Track: "Dancing Queen by ABBA"})
+	RecordAudit(AuditEntry{At: time.Now(), Action: AuditSkip, TeamID: "T1", ChannelID: "C-private", Track: "Waterloo by ABBA"})
+	RecordAudit(AuditEntry{At: time.Now(), Action: AuditSkip, TeamID: "T2", ChannelID: "C1", Track: "Take On Me by a-ha"})
+
+	stats, err := GatherListeningStats("T1", "C1", start.Add(-time.Minute), time.Now().Add(time.Minute))
+	if err != nil {
+		t.Fatalf("GatherListeningStats: %v", err)
+	}
+	if stats.Plays != 1 || stats.TopTracks[0].Name != "Dancing Queen by ABBA" {
+		t.Errorf("plays = %d %v, want only the one in the channel", stats.Plays, stats.TopTracks)
+	}
+	if want := []StatCount{{"Dancing Queen by ABBA", 1}}; !reflect.DeepEqual(stats.MostSkipped, want) {
+		t.Errorf("MostSkipped = %v, want only the skips in the channel and workspace", stats.MostSkipped)
+	}
+}
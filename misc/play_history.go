@@ -0,0 +1,157 @@
+package misc
+
+import (
+	"fmt"
+	"log"
+	"sync"
+	"time"
+)
+
+// PlayRecord is a track that started playing on an account the bot watches, seen by a dashboard in a channel
+type PlayRecord struct {
+	At          time.Time `json:"at"`
+	ChannelID   string    `json:"channel_id"`
+	AccountKey  string    `json:"account_key"`
+	TrackURI    string    `json:"track_uri"`
+	Artist      string    `json:"artist"`
+	Song        string    `json:"song"`
+	DurationMs  int       `json:"duration_ms"`
+	RequestedBy string    `json:"requested_by"` // who requested it through the DJ queue, empty if nobody did
+}
+
+// PlayHistoryStore keeps the tracks played through the bot
+type PlayHistoryStore interface {
+	// Append records a play and forgets the plays older than the store's retention
+	Append(record PlayRecord) error
+	// Between returns the plays that started in [from, to), oldest first
+	Between(from time.Time, to time.Time) ([]PlayRecord, error)
+	// Latest returns the newest n plays keep selects, newest first
+	Latest(n int, keep func(record PlayRecord) bool) ([]PlayRecord, error)
+	Close() error
+}
+
+// PlayHistoryLog records each distinct track the dashboards see playing, once per channel and account
+// however many dashboards of the channel show it
+type PlayHistoryLog struct {
+	store PlayHistoryStore
+	last  map[string]string // channel ID and account key -> URI of the track recorded last
+	mutex sync.Mutex
+}
+
+// PlayHistory is what the dashboards log to and the listening reports read from,
+// main replaces it with one on the configured store
+var PlayHistory = NewPlayHistoryLog(NewMemoryPlayHistoryStore(0, 0))
+
+// NewPlayHistoryLog creates a log that writes to store
+func NewPlayHistoryLog(store PlayHistoryStore) *PlayHistoryLog {
+	return &PlayHistoryLog{store: store, last: map[string]string{}}
+}
+
+// Observe records track, seen by a dashboard in channelId, if it is playing and isn't the one recorded
+// last for the channel and account. A failure is only logged, it must not keep the dashboard from updating.
+func (l *PlayHistoryLog) Observe(channelId string, accountKey string, track CurrentPlayingTrackResponse) {
+	if !track.IsPlaying || track.TrackURI == "" {
+		return
+	}
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	key := channelId + "/" + accountKey
+	if l.last[key] == track.TrackURI {
+		return
+	}
+	l.last[key] = track.TrackURI
+	err := l.store.Append(PlayRecord{
+		At:          time.Now(),
+		ChannelID:   channelId,
+		AccountKey:  accountKey,
+		TrackURI:    track.TrackURI,
+		Artist:      track.Artist,
+		Song:        track.Song,
+		DurationMs:  track.DurationMs,
+		RequestedBy: track.RequestedBy,
+	})
+	if err != nil {
+		log.Printf("failed to record %s in the play history: %v", track.Song, err)
+	}
+}
+
+// Between returns the plays that started in [from, to), oldest first
+func (l *PlayHistoryLog) Between(from time.Time, to time.Time) ([]PlayRecord, error) {
+	return l.store.Between(from, to)
+}
+
+// Latest returns the newest n plays keep selects, newest first
+func (l *PlayHistoryLog) Latest(n int, keep func(record PlayRecord) bool) ([]PlayRecord, error) {
+	return l.store.Latest(n, keep)
+}
+
+// NewPlayHistoryStore builds the play history store selected by config.PlayHistoryStoreType:
+// "bolt" for an embedded BoltDB database, anything else keeps the history in memory only
+func NewPlayHistoryStore(config Config) (PlayHistoryStore, error) {
+	switch config.PlayHistoryStoreType {
+	case "bolt":
+		return NewBoltPlayHistoryStore(config.PlayHistoryStorePath, config.PlayHistoryRetention())
+	case "", "memory":
+		return NewMemoryPlayHistoryStore(config.PlayHistoryRetention(), memoryPlayHistoryLimit), nil
+	}
+	return nil, fmt.Errorf("unknown play history store type: %s", config.PlayHistoryStoreType)
+}
+
+// memoryPlayHistoryLimit is how many plays the in-memory history keeps at most
+const memoryPlayHistoryLimit = 10000
+
+var playHistoryBucket = []byte("play_history")
+
+// timeLogPlayHistoryStore keeps the play history in a time log
+type timeLogPlayHistoryStore struct {
+	log timeLog[PlayRecord]
+}
+
+// NewMemoryPlayHistoryStore creates an empty history in memory, gone after a restart, that keeps plays
+// for retention and at most limit of them, 0 lifts either bound
+func NewMemoryPlayHistoryStore(retention time.Duration, limit int) PlayHistoryStore {
+	return timeLogPlayHistoryStore{newMemoryTimeLog[PlayRecord](retention, limit)}
+}
+
+// NewBoltPlayHistoryStore opens (or creates) the history in the BoltDB database at path, keeping plays
+// for retention, forever if 0
+func NewBoltPlayHistoryStore(path string, retention time.Duration) (PlayHistoryStore, error) {
+	if path == "" {
+		return nil, fmt.Errorf("play history store path is not configured")
+	}
+	history, err := openBoltTimeLog[PlayRecord](path, playHistoryBucket, retention)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open play history store: %w", err)
+	}
+	return timeLogPlayHistoryStore{history}, nil
+}
+
+func (s timeLogPlayHistoryStore) Append(record PlayRecord) error {
+	return s.log.append(record.At, record)
+}
+
+func (s timeLogPlayHistoryStore) Between(from time.Time, to time.Time) ([]PlayRecord, error) {
+	return s.log.between(from, to)
+}
+
+func (s timeLogPlayHistoryStore) Latest(n int, keep func(record PlayRecord) bool) ([]PlayRecord, error) {
+	if n <= 0 {
+		return nil, nil
+	}
+	var records []PlayRecord
+	err := s.log.newest(time.Time{}, func(record PlayRecord) bool {
+		if keep(record) {
+			records = append(records, record)
+		}
+		return len(records) < n
+	})
+	if err != nil {
+		return nil, err
+	}
+	return records, nil
+}
+
+func (s timeLogPlayHistoryStore) Close() error {
+	return s.log.close()
+}
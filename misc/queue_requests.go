@@ -0,0 +1,76 @@
+package misc
+
+import (
+	"sync"
+	"time"
+)
+
+// queueRequestTTL is how long we remember who queued a track, long enough for a busy queue to get to it
+const queueRequestTTL = 12 * time.Hour
+
+// QueueRequest records that a Slack user requested a track through the DJ queue
+type QueueRequest struct {
+	AccountKey  string
+	TrackURI    string
+	TrackName   string // e.g. "Dancing Queen by ABBA", for /myqueue
+	SlackUserID string
+	UserName    string
+	RequestedAt time.Time
+}
+
+// TrackChoice is the value of a search result's "Add to queue" button
+type TrackChoice struct {
+	URI    string `json:"uri"`
+	Name   string `json:"name"`
+	Artist string `json:"artist"`
+}
+
+// QueueRequestLog remembers who queued which track on which account, so the dashboard can credit them
+type QueueRequestLog struct {
+	requests map[string]map[string]QueueRequest // account key -> track URI -> latest request
+	mutex    sync.Mutex
+}
+
+// QueueRequests is the log the DJ queue writes to and the dashboards read from
+var QueueRequests = &QueueRequestLog{}
+
+// Record stores a request, replacing an earlier request of the same track on the same account
+func (l *QueueRequestLog) Record(request QueueRequest) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	if l.requests == nil {
+		l.requests = map[string]map[string]QueueRequest{}
+	}
+	l.purge(time.Now())
+	if l.requests[request.AccountKey] == nil {
+		l.requests[request.AccountKey] = map[string]QueueRequest{}
+	}
+	l.requests[request.AccountKey][request.TrackURI] = request
+}
+
+// RequestedBy returns the name of whoever requested trackURI on the account, empty if nobody did
+func (l *QueueRequestLog) RequestedBy(accountKey string, trackURI string) string {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	request, ok := l.requests[accountKey][trackURI]
+	if !ok || time.Since(request.RequestedAt) > queueRequestTTL {
+		return ""
+	}
+	return request.UserName
+}
+
+// purge drops requests older than queueRequestTTL. The caller holds l.mutex.
+func (l *QueueRequestLog) purge(now time.Time) {
+	for accountKey, tracks := range l.requests {
+		for uri, request := range tracks {
+			if now.Sub(request.RequestedAt) > queueRequestTTL {
+				delete(tracks, uri)
+			}
+		}
+		if len(tracks) == 0 {
+			delete(l.requests, accountKey)
+		}
+	}
+}
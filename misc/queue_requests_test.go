@@ -0,0 +1,22 @@
+package misc
+
+import (
+	"testing"
+	"time"
+)
+
+func TestQueueRequestLog(t *testing.T) {
+	var log QueueRequestLog
+	log.Record(QueueRequest{AccountKey: "shared", TrackURI: "spotify:track:1", UserName: "alice", RequestedAt: time.Now()})
+	log.Record(QueueRequest{AccountKey: "shared", TrackURI: "spotify:track:2", UserName: "bob", RequestedAt: time.Now().Add(-2 * queueRequestTTL)})
+
+	if got := log.RequestedBy("shared", "spotify:track:1"); got != "alice" {
+		t.Errorf("RequestedBy(track 1) = %q, want alice", got)
+	}
+	if got := log.RequestedBy("T1:carol", "spotify:track:1"); got != "" {
+		t.Errorf("another account's queue credited %q", got)
+	}
+	if got := log.RequestedBy("shared", "spotify:track:2"); got != "" {
+		t.Errorf("an expired request still credited %q", got)
+	}
+}
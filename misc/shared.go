@@ -1,27 +1,213 @@
 package misc
 
 import (
+	"context"
+	"fmt"
+	"log"
 	"sync"
+	"time"
+)
+
+// Spotify account modes, selected with SPOTIFY_ACCOUNT_MODE
+const (
+	// SpotifyAccountModeUser gives every Slack user their own Spotify account
+	SpotifyAccountModeUser = "user"
+	// SpotifyAccountModeShared makes everyone drive the one account that last ran /spotify-auth
+	SpotifyAccountModeShared = "shared"
 )
 
 // SharedData holds data shared across command functions
 type SharedData struct {
-	spotifyAccessToken string
-	mutex             sync.Mutex
+	spotifyTokens map[string]SpotifyToken
+	accountMode   string
+	refreshToken  func(ctx context.Context, refreshToken string) (SpotifyToken, error)
+	store         TokenStore
+	mutex         sync.Mutex
+	refreshMutex  sync.Mutex // serializes refreshes so concurrent callers don't each hit the token endpoint
 }
 
 var Shared SharedData
 
-// SetSpotifyAccessToken sets the Spotify access token with concurrency safety
-func (s *SharedData) SetSpotifyAccessToken(token string) {
+// SpotifyAccountKey identifies the Spotify account of a Slack user within a team
+func SpotifyAccountKey(teamID string, userID string) string {
+	return teamID + ":" + userID
+}
+
+// SetSpotifyAccountMode switches between per-user accounts and the shared party account
+func (s *SharedData) SetSpotifyAccountMode(mode string) error {
+	switch mode {
+	case "", SpotifyAccountModeUser, SpotifyAccountModeShared:
+	default:
+		return fmt.Errorf("unknown spotify account mode: %s", mode)
+	}
 	s.mutex.Lock()
 	defer s.mutex.Unlock()
-	s.spotifyAccessToken = token
+	s.accountMode = mode
+	return nil
 }
 
-// GetSpotifyAccessToken retrieves the Spotify access token with concurrency safety
+// AccountKey returns the token key Spotify calls made by the given Slack user should use
+func (s *SharedData) AccountKey(teamID string, userID string) string {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.accountMode == SpotifyAccountModeShared {
+		return SharedSpotifyTokenKey
+	}
+	return SpotifyAccountKey(teamID, userID)
+}
+
+// SetSpotifyAccessToken sets the shared Spotify access token with concurrency safety
+func (s *SharedData) SetSpotifyAccessToken(token string) {
+	s.SetSpotifyToken(SpotifyToken{AccessToken: token})
+}
+
+// GetSpotifyAccessToken retrieves the shared Spotify access token with concurrency safety
 func (s *SharedData) GetSpotifyAccessToken() string {
+	return s.GetSpotifyToken().AccessToken
+}
+
+// SetSpotifyToken stores the shared party account's token set
+func (s *SharedData) SetSpotifyToken(token SpotifyToken) {
+	s.SetSpotifyTokenFor(SharedSpotifyTokenKey, token)
+}
+
+// GetSpotifyToken retrieves the shared party account's token set
+func (s *SharedData) GetSpotifyToken() SpotifyToken {
+	return s.GetSpotifyTokenFor(SharedSpotifyTokenKey)
+}
+
+// SetSpotifyTokenFor stores the token set of an account with concurrency safety
+// and persists it to the token store, if one is set
+func (s *SharedData) SetSpotifyTokenFor(key string, token SpotifyToken) {
+	s.mutex.Lock()
+	if s.spotifyTokens == nil {
+		s.spotifyTokens = map[string]SpotifyToken{}
+	}
+	s.spotifyTokens[key] = token
+	store := s.store
+	s.mutex.Unlock()
+
+	if store != nil {
+		if err := store.Save(key, token); err != nil {
+			log.Printf("failed to persist spotify token for %s: %v", key, err)
+		}
+	}
+}
+
+// GetSpotifyTokenFor retrieves the token set of an account with concurrency safety
+func (s *SharedData) GetSpotifyTokenFor(key string) SpotifyToken {
 	s.mutex.Lock()
 	defer s.mutex.Unlock()
-	return s.spotifyAccessToken
+	return s.spotifyTokens[key]
+}
+
+// HasSpotifyToken reports whether the account has been authorized with /spotify-auth
+func (s *SharedData) HasSpotifyToken(key string) bool {
+	return s.GetSpotifyTokenFor(key).AccessToken != ""
+}
+
+// SpotifyTokenExpiry returns when the shared access token expires, zero if unknown
+func (s *SharedData) SpotifyTokenExpiry() time.Time {
+	return s.GetSpotifyToken().ExpiresAt
+}
+
+// SpotifyTokenExpiryFor returns when the account's access token expires, zero if unknown
+func (s *SharedData) SpotifyTokenExpiryFor(key string) time.Time {
+	return s.GetSpotifyTokenFor(key).ExpiresAt
+}
+
+// SetTokenStore sets the store Spotify tokens are persisted to
+func (s *SharedData) SetTokenStore(store TokenStore) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.store = store
+}
+
+// LoadSpotifyTokens restores the tokens saved by a previous run, if any
+func (s *SharedData) LoadSpotifyTokens() error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.store == nil {
+		return nil
+	}
+	tokens, err := s.store.Load()
+	if err != nil {
+		return fmt.Errorf("failed to load spotify tokens: %w", err)
+	}
+	s.spotifyTokens = tokens
+	return nil
+}
+
+// SetSpotifyTokenRefresher sets the function used to renew expired access tokens
+func (s *SharedData) SetSpotifyTokenRefresher(refresher func(ctx context.Context, refreshToken string) (SpotifyToken, error)) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.refreshToken = refresher
+}
+
+// Token implements TokenSource for the shared party account
+func (s *SharedData) Token(ctx context.Context) (string, error) {
+	return s.TokenSourceFor(SharedSpotifyTokenKey).Token(ctx)
+}
+
+// Refresh forces a refresh of the shared party account's token
+func (s *SharedData) Refresh(ctx context.Context) (string, error) {
+	return s.TokenSourceFor(SharedSpotifyTokenKey).Refresh(ctx)
+}
+
+// TokenSourceFor returns a TokenSource for the account stored under key
+func (s *SharedData) TokenSourceFor(key string) RefreshableTokenSource {
+	return &accountTokenSource{shared: s, key: key}
+}
+
+// accountTokenSource hands out one account's access token, refreshing it shortly before it expires
+type accountTokenSource struct {
+	shared *SharedData
+	key    string
+}
+
+func (a *accountTokenSource) Token(ctx context.Context) (string, error) {
+	token := a.shared.GetSpotifyTokenFor(a.key)
+	if token.AccessToken == "" {
+		return "", ErrNotAuthenticated
+	}
+	if !token.Expired() {
+		return token.AccessToken, nil
+	}
+	return a.shared.refresh(ctx, a.key, token.AccessToken)
+}
+
+// Refresh forces a token refresh, used when Spotify rejects the access token with a 401
+func (a *accountTokenSource) Refresh(ctx context.Context) (string, error) {
+	return a.shared.refresh(ctx, a.key, a.shared.GetSpotifyTokenFor(a.key).AccessToken)
+}
+
+// refresh renews the account's token unless another caller already replaced the stale one
+func (s *SharedData) refresh(ctx context.Context, key string, stale string) (string, error) {
+	s.refreshMutex.Lock()
+	defer s.refreshMutex.Unlock()
+
+	s.mutex.Lock()
+	token := s.spotifyTokens[key]
+	refresher := s.refreshToken
+	s.mutex.Unlock()
+
+	if token.AccessToken != stale && !token.Expired() {
+		return token.AccessToken, nil
+	}
+	if token.RefreshToken == "" || refresher == nil {
+		return "", &ClassifiedError{
+			Kind:    ErrorKindUser,
+			Message: "Your Spotify login expired. Run /spotify-auth again to reconnect!",
+			Err:     ErrNotAuthenticated,
+		}
+	}
+
+	refreshed, err := refresher(ctx, token.RefreshToken)
+	if err != nil {
+		return "", UpstreamError("Spotify", fmt.Errorf("failed to refresh spotify access token: %w", err))
+	}
+	s.SetSpotifyTokenFor(key, refreshed)
+	return refreshed.AccessToken, nil
 }
@@ -0,0 +1,31 @@
+package misc
+
+import (
+	"context"
+	"testing"
+)
+
+func TestSharedDataKeepsTokensPerAccount(t *testing.T) {
+	var shared SharedData
+	alice := shared.AccountKey("T1", "alice")
+	bob := shared.AccountKey("T1", "bob")
+	if alice == bob {
+		t.Fatalf("alice and bob share account key %q", alice)
+	}
+
+	shared.SetSpotifyTokenFor(alice, SpotifyToken{AccessToken: "alice-token"})
+	if shared.HasSpotifyToken(bob) {
+		t.Error("bob should not be connected by alice's /spotify-auth")
+	}
+	token, err := shared.TokenSourceFor(alice).Token(context.Background())
+	if err != nil || token != "alice-token" {
+		t.Errorf("alice token = %q, %v", token, err)
+	}
+
+	if err := shared.SetSpotifyAccountMode(SpotifyAccountModeShared); err != nil {
+		t.Fatal(err)
+	}
+	if got := shared.AccountKey("T1", "bob"); got != SharedSpotifyTokenKey {
+		t.Errorf("shared mode account key = %q, want %q", got, SharedSpotifyTokenKey)
+	}
+}
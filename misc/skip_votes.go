@@ -0,0 +1,73 @@
+package misc
+
+import (
+	"sync"
+	"time"
+)
+
+// SkipVotePolicy decides how many votes skipping a track on a dashboard takes.
+// With neither a threshold nor a percentage, ⏩ skips right away as it always did.
+type SkipVotePolicy struct {
+	Threshold int           // fixed number of votes
+	Percent   int           // share of the channel's recent participants, takes precedence over Threshold
+	Window    time.Duration // how recently someone must have used the bot in the channel to count as participant
+}
+
+// SkipVotes is the policy of every dashboard, main replaces it with the configured one
+var SkipVotes SkipVotePolicy
+
+// Enabled reports whether skipping goes by vote
+func (p SkipVotePolicy) Enabled() bool {
+	return p.Threshold > 0 || p.Percent > 0
+}
+
+// Required returns how many votes skip the track when participants people used the bot
+// in the channel recently. It is never less than one.
+func (p SkipVotePolicy) Required(participants int) int {
+	if p.Percent > 0 {
+		// Round up, half of three people is two votes
+		return max((participants*p.Percent+99)/100, 1)
+	}
+	return max(p.Threshold, 1)
+}
+
+// ChannelActivityLog remembers who used the bot in which channel, and when
+type ChannelActivityLog struct {
+	seen  map[string]map[string]time.Time // channel ID -> user ID -> last request
+	mutex sync.Mutex
+}
+
+// ChannelActivity is fed by every request the bot handles
+var ChannelActivity = &ChannelActivityLog{}
+
+// Seen records that userId used the bot in channelId at the given time
+func (l *ChannelActivityLog) Seen(channelId string, userId string, at time.Time) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	if l.seen == nil {
+		l.seen = map[string]map[string]time.Time{}
+	}
+	if l.seen[channelId] == nil {
+		l.seen[channelId] = map[string]time.Time{}
+	}
+	l.seen[channelId][userId] = at
+}
+
+// Recent returns how many people used the bot in channelId within window, forgetting older visits
+func (l *ChannelActivityLog) Recent(channelId string, window time.Duration) int {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	now := time.Now()
+	for userId, at := range l.seen[channelId] {
+		if now.Sub(at) > window {
+			delete(l.seen[channelId], userId)
+		}
+	}
+	if len(l.seen[channelId]) == 0 {
+		delete(l.seen, channelId)
+		return 0
+	}
+	return len(l.seen[channelId])
+}
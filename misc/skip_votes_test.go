@@ -0,0 +1,70 @@
+package misc
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSkipVotePolicyRequired(t *testing.T) {
+	tests := []struct {
+		policy       SkipVotePolicy
+		participants int
+		want         int
+		enabled      bool
+	}{
+		{SkipVotePolicy{}, 5, 1, false},
+		{SkipVotePolicy{Threshold: 3}, 10, 3, true},
+		{SkipVotePolicy{Percent: 50}, 3, 2, true},
+		{SkipVotePolicy{Percent: 50}, 4, 2, true},
+		{SkipVotePolicy{Percent: 50}, 0, 1, true},
+		{SkipVotePolicy{Threshold: 3, Percent: 100}, 5, 5, true},
+	}
+	for _, tt := range tests {
+		if got := tt.policy.Required(tt.participants); got != tt.want || tt.policy.Enabled() != tt.enabled {
+			t.Errorf("%+v.Required(%d) = %d, enabled %v, want %d, %v", tt.policy, tt.participants, got, tt.policy.Enabled(), tt.want, tt.enabled)
+		}
+	}
+}
+
+func TestSpotifyDashboardVoteSkip(t *testing.T) {
+	dashboard := &SpotifyDashboard{}
+	dashboard.CreateSpotifyDashboard(CurrentPlayingTrackResponse{TrackURI: "spotify:track:1"}, "1.1", "C1", "T1:U1", "/spotify", "alice")
+
+	if votes, skip, err := dashboard.VoteSkip("spotify:track:1", "U1", 2); votes != 1 || skip || err != nil {
+		t.Fatalf("first vote = %d, %v, %v", votes, skip, err)
+	}
+	if _, _, err := dashboard.VoteSkip("spotify:track:1", "U1", 2); KindOf(err) != ErrorKindUser {
+		t.Errorf("second vote of the same user error = %v, want a user error", err)
+	}
+	// The track changed before anyone else voted, the old vote doesn't count
+	if votes, skip, err := dashboard.VoteSkip("spotify:track:2", "U2", 2); votes != 1 || skip || err != nil {
+		t.Fatalf("vote on the next track = %d, %v, %v", votes, skip, err)
+	}
+	if votes, skip, err := dashboard.VoteSkip("spotify:track:2", "U1", 2); votes != 2 || !skip || err != nil {
+		t.Fatalf("deciding vote = %d, %v, %v", votes, skip, err)
+	}
+	// The skip failed, the votes stand and a voter may try again
+	if votes, skip, err := dashboard.VoteSkip("spotify:track:2", "U2", 2); votes != 2 || !skip || err != nil {
+		t.Fatalf("retried vote = %d, %v, %v, want the skip to go ahead", votes, skip, err)
+	}
+	dashboard.ResetSkipVotes("spotify:track:2")
+	if votes, skip, _ := dashboard.VoteSkip("spotify:track:2", "U3", 2); votes != 1 || skip {
+		t.Errorf("vote after the skip = %d, %v, want the count to start over", votes, skip)
+	}
+}
+
+func TestChannelActivityRecent(t *testing.T) {
+	activity := &ChannelActivityLog{}
+	now := time.Now()
+	activity.Seen("C1", "U1", now)
+	activity.Seen("C1", "U2", now.Add(-time.Hour))
+	activity.Seen("C1", "U1", now)
+	activity.Seen("C2", "U3", now)
+
+	if got := activity.Recent("C1", 15*time.Minute); got != 1 {
+		t.Errorf("Recent(C1) = %d, want 1", got)
+	}
+	if got := activity.Recent("C3", 15*time.Minute); got != 0 {
+		t.Errorf("Recent(C3) = %d, want 0", got)
+	}
+}
@@ -3,14 +3,11 @@ package misc
 import (
 	"crypto/rand"
 	"encoding/base64"
-	"fmt"
 	"log"
 	"net/http"
-	"strings"
 
+	"github.com/georgecpp/mimir/oauth"
 	"github.com/gin-gonic/gin"
-	"github.com/go-resty/resty/v2" // Import the resty package
-	"github.com/tidwall/gjson"     // Import the gjson package
 )
 
 func RunSpotifyAuthServer() {
@@ -21,15 +18,18 @@ func RunSpotifyAuthServer() {
 	r := gin.Default()
 
 	r.GET("/login", func(c *gin.Context) {
-		state, err := generateRandomString(16)
+		// Logins started from the browser rather than Slack authorize the shared party account
+		authState, err := AuthStates.Issue(SharedSpotifyTokenKey, "", config.SpotifyUsePkce)
 		if err != nil {
 			c.String(http.StatusInternalServerError, err.Error())
 			return
 		}
 
-		scope := config.SpotifyAuthorizeScopesString
-
-		url := fmt.Sprintf("%s?%s", config.SpotifyAuthorizeBaseUrl, buildQueryParams(config, state, scope))
+		url, err := BuildSpotifyAuthorizeURL(config, authState)
+		if err != nil {
+			c.String(http.StatusInternalServerError, err.Error())
+			return
+		}
 		c.Redirect(http.StatusTemporaryRedirect, url)
 	})
 
@@ -42,35 +42,32 @@ func RunSpotifyAuthServer() {
 			return
 		}
 
-		if code == "" {
-			c.String(http.StatusBadRequest, "Code parameter is missing")
+		// Only states we issued, once and before they expire, may complete a login
+		authState, err := AuthStates.Consume(state)
+		if err != nil {
+			c.String(http.StatusBadRequest, err.Error())
 			return
 		}
 
-		// Exchange authorization code for access token
-		resp, err := resty.New().R().
-			SetHeader("Authorization", "Basic "+base64.StdEncoding.EncodeToString([]byte(config.SpotifyClientId+":"+config.SpotifyClientSecret))).
-			SetHeader("Content-Type", "application/x-www-form-urlencoded").
-			SetBody(fmt.Sprintf("grant_type=authorization_code&code=%s&redirect_uri=%s", code, config.SpotifyRedirectUri)).
-			SetResult(map[string]interface{}{}).
-			Post(config.SpotifyAccessTokenUrl)
-
-		if err != nil {
-			c.String(http.StatusInternalServerError, err.Error())
+		if authError := c.Query("error"); authError != "" {
+			c.String(http.StatusBadRequest, "Spotify authorization failed: "+authError)
 			return
 		}
 
-		if resp.StatusCode() != http.StatusOK {
-			c.String(resp.StatusCode(), string(resp.Body()))
+		if code == "" {
+			c.String(http.StatusBadRequest, "Code parameter is missing")
 			return
 		}
 
-		// Successful token exchange
-		json := resp.Body()
-		accessToken := gjson.Get(string(json), "access_token").String()
+		// Exchange authorization code for the full token set, keeping the refresh token
+		token, err := ExchangeSpotifyCode(c.Request.Context(), config, code, authState.CodeVerifier)
+		if err != nil {
+			c.String(http.StatusBadGateway, err.Error())
+			return
+		}
 
-		// Use the access token in your application
-		Shared.SetSpotifyAccessToken(accessToken)
+		// Store the token under the account the state was issued for
+		Shared.SetSpotifyTokenFor(authState.AccountKey, token)
 
 		c.Redirect(http.StatusTemporaryRedirect, config.SpotifyAuthSuccessUrl)
 	})
@@ -87,19 +84,16 @@ func generateRandomString(length int) (string, error) {
 	return base64.URLEncoding.EncodeToString(b), nil
 }
 
-func buildQueryParams(config Config, state string, scope string) string {
-	params := map[string]string{
-		"response_type": "code",
-		"client_id":     config.SpotifyClientId,
-		"scope":         scope,
-		"redirect_uri":  config.SpotifyRedirectUri,
-		"state":         state,
+// BuildSpotifyAuthorizeURL builds the Spotify login URL for an issued state
+func BuildSpotifyAuthorizeURL(config Config, authState AuthState) (string, error) {
+	params := oauth.AuthorizeParams{
+		ClientID:    config.SpotifyClientId,
+		RedirectURI: config.SpotifyRedirectUri,
+		Scopes:      oauth.ParseScopes(config.SpotifyAuthorizeScopesString),
+		State:       authState.State,
 	}
-
-	var parts []string
-	for key, value := range params {
-		parts = append(parts, fmt.Sprintf("%s=%s", key, value))
+	if authState.CodeVerifier != "" {
+		params.CodeChallenge = authState.CodeChallenge()
 	}
-
-	return strings.Join(parts, "&")
+	return oauth.AuthorizeURL(config.SpotifyAuthorizeBaseUrl, params)
 }
@@ -0,0 +1,570 @@
+package misc
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DefaultSpotifyApiBaseUrl is used when no base URL is configured
+const DefaultSpotifyApiBaseUrl = "https://api.spotify.com"
+
+// TokenSource supplies the access token used to authorize Spotify API calls
+type TokenSource interface {
+	Token(ctx context.Context) (string, error)
+}
+
+// RefreshableTokenSource is a TokenSource that can be forced to renew its token,
+// which the client does once when Spotify answers 401 Unauthorized
+type RefreshableTokenSource interface {
+	TokenSource
+	Refresh(ctx context.Context) (string, error)
+}
+
+// TokenSourceFunc lets an ordinary function act as a TokenSource
+type TokenSourceFunc func(ctx context.Context) (string, error)
+
+// Token calls f(ctx)
+func (f TokenSourceFunc) Token(ctx context.Context) (string, error) {
+	return f(ctx)
+}
+
+// SpotifyClient talks to the Spotify Web API on behalf of a single token source
+type SpotifyClient struct {
+	baseURL    string
+	httpClient *http.Client
+	tokens     TokenSource
+	limiter    *spotifyLimiter
+	retry      spotifyRetry
+	// defaultDevice is the ID or name of the device to wake when no device is active
+	defaultDevice string
+}
+
+// Spotify is the client used by the command and interaction handlers
+var Spotify = NewSpotifyClient(DefaultSpotifyApiBaseUrl, nil, &Shared)
+
+// NewSpotifyClient creates a client for the given base URL,
+// falling back to the public API and a default http.Client when left empty
+func NewSpotifyClient(baseURL string, httpClient *http.Client, tokens TokenSource) *SpotifyClient {
+	if baseURL == "" {
+		baseURL = DefaultSpotifyApiBaseUrl
+	}
+	if httpClient == nil {
+		httpClient = &http.Client{Timeout: 10 * time.Second}
+	}
+	return &SpotifyClient{
+		baseURL:    strings.TrimRight(baseURL, "/"),
+		httpClient: httpClient,
+		tokens:     tokens,
+		limiter:    &spotifyLimiter{},
+		retry:      defaultSpotifyRetry,
+	}
+}
+
+// SpotifyForAccount returns a client that acts on behalf of the account stored under key
+func SpotifyForAccount(key string) *SpotifyClient {
+	return Spotify.WithTokenSource(Shared.TokenSourceFor(key))
+}
+
+// WithTokenSource returns a copy of the client that authorizes its calls with tokens,
+// it shares the original's rate limiter
+func (c *SpotifyClient) WithTokenSource(tokens TokenSource) *SpotifyClient {
+	clone := *c
+	clone.tokens = tokens
+	return &clone
+}
+
+// WithDefaultDevice returns a copy of the client that falls back to the device with the given
+// ID or name when no device is active, e.g. the office speaker
+func (c *SpotifyClient) WithDefaultDevice(device string) *SpotifyClient {
+	clone := *c
+	clone.defaultDevice = device
+	return &clone
+}
+
+// DefaultDevice returns the ID or name of the fallback device, empty if there is none
+func (c *SpotifyClient) DefaultDevice() string {
+	return c.defaultDevice
+}
+
+// BaseURL returns the API base URL the client sends requests to
+func (c *SpotifyClient) BaseURL() string {
+	return c.baseURL
+}
+
+// do sends an authorized request to path and decodes a JSON response into out, if given.
+// It returns the response status code so callers can tell 200 from 204.
+// GET requests are retried with jittered backoff on 429, 5xx and network errors.
+func (c *SpotifyClient) do(ctx context.Context, method string, path string, body []byte, out interface{}) (int, error) {
+	attempts := 1
+	if method == http.MethodGet {
+		attempts = c.retry.attempts
+	}
+
+	for attempt := 0; ; attempt++ {
+		status, err := c.doOnce(ctx, method, path, body, out)
+		if err == nil || attempt+1 >= attempts || !retryable(err) || ctx.Err() != nil {
+			return status, err
+		}
+		if sleepErr := sleepContext(ctx, c.retry.delay(attempt)); sleepErr != nil {
+			return status, err
+		}
+	}
+}
+
+// retryable reports whether a failed request is worth sending again
+func retryable(err error) bool {
+	var apiErr *spotifyAPIError
+	if errors.As(err, &apiErr) {
+		return apiErr.temporary()
+	}
+	var urlErr *url.Error
+	return errors.As(err, &urlErr)
+}
+
+// doOnce sends the request once, or twice when the access token has to be refreshed
+func (c *SpotifyClient) doOnce(ctx context.Context, method string, path string, body []byte, out interface{}) (int, error) {
+	if err := c.limiter.Wait(ctx); err != nil {
+		return 0, err
+	}
+
+	accessToken, err := c.tokens.Token(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("failed to get access token: %w", err)
+	}
+
+	resp, err := c.send(ctx, method, path, body, accessToken)
+	if err != nil {
+		return 0, err
+	}
+
+	// The token may have been revoked or expired early, refresh it once and retry
+	if refreshable, ok := c.tokens.(RefreshableTokenSource); ok && resp.StatusCode == http.StatusUnauthorized {
+		resp.Body.Close()
+		accessToken, err = refreshable.Refresh(ctx)
+		if err != nil {
+			return http.StatusUnauthorized, fmt.Errorf("failed to refresh access token: %w", err)
+		}
+		resp, err = c.send(ctx, method, path, body, accessToken)
+		if err != nil {
+			return 0, err
+		}
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		apiErr := newSpotifyAPIError(resp)
+		if apiErr.StatusCode == http.StatusTooManyRequests {
+			c.limiter.Backoff(apiErr.RetryAfter)
+		}
+		if apiErr.Unwrap() == nil {
+			// Not one of the states the handlers explain to the user
+			return resp.StatusCode, UpstreamError("Spotify", apiErr)
+		}
+		return resp.StatusCode, apiErr
+	}
+
+	if out != nil && resp.StatusCode != http.StatusNoContent {
+		if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+			return resp.StatusCode, fmt.Errorf("failed to decode response: %w", err)
+		}
+	}
+	return resp.StatusCode, nil
+}
+
+// send performs a single HTTP round trip with the given access token
+func (c *SpotifyClient) send(ctx context.Context, method string, path string, body []byte, accessToken string) (*http.Response, error) {
+	var reader io.Reader
+	if body != nil {
+		reader = bytes.NewReader(body)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, c.baseURL+path, reader)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Authorization", "Bearer "+accessToken)
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, UpstreamError("Spotify", fmt.Errorf("failed to make request: %w", err))
+	}
+	return resp, nil
+}
+
+// GetDevices lists the devices available to the user
+func (c *SpotifyClient) GetDevices(ctx context.Context) ([]SpotifyDevice, error) {
+	var data SpotifyDevicesResponse
+	if _, err := c.do(ctx, http.MethodGet, "/v1/me/player/devices", nil, &data); err != nil {
+		return nil, fmt.Errorf("devices request failed: %w", err)
+	}
+	return data.Devices, nil
+}
+
+// GetActiveDevice retrieves the active device ID. When no device is active it wakes the
+// default device, if one is configured and available, by transferring playback to it.
+func (c *SpotifyClient) GetActiveDevice(ctx context.Context) (string, error) {
+	devices, err := c.GetDevices(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	for _, device := range devices {
+		if device.IsActive {
+			return device.ID, nil
+		}
+	}
+
+	if device, ok := FindDevice(devices, c.defaultDevice); ok {
+		if err := c.TransferPlayback(ctx, device.ID, false); err != nil {
+			return "", fmt.Errorf("failed to wake default device %s: %w", device.Name, err)
+		}
+		return device.ID, nil
+	}
+
+	return "", ErrNoActiveDevice
+}
+
+// TransferPlayback moves playback to deviceId, starting it there when play is set
+// and otherwise keeping it playing or paused as it was
+func (c *SpotifyClient) TransferPlayback(ctx context.Context, deviceId string, play bool) error {
+	body, err := json.Marshal(struct {
+		DeviceIds []string `json:"device_ids"`
+		Play      bool     `json:"play"`
+	}{[]string{deviceId}, play})
+	if err != nil {
+		return fmt.Errorf("failed to encode transfer request: %w", err)
+	}
+	_, err = c.do(ctx, http.MethodPut, "/v1/me/player", body, nil)
+	return err
+}
+
+// GetCurrentlyPlaying returns the raw currently playing object, or nil when nothing is playing
+func (c *SpotifyClient) GetCurrentlyPlaying(ctx context.Context) (*SpotifyCurrentlyPlaying, error) {
+	var data SpotifyCurrentlyPlaying
+	status, err := c.do(ctx, http.MethodGet, "/v1/me/player/currently-playing", nil, &data)
+	if err != nil {
+		return nil, err
+	}
+	if status == http.StatusNoContent || data.Item == nil {
+		return nil, nil
+	}
+	return &data, nil
+}
+
+// GetPlaybackState returns the playback state of the active device, or nil when no device is active
+func (c *SpotifyClient) GetPlaybackState(ctx context.Context) (*SpotifyPlaybackState, error) {
+	var data SpotifyPlaybackState
+	status, err := c.do(ctx, http.MethodGet, "/v1/me/player", nil, &data)
+	if err != nil {
+		return nil, err
+	}
+	if status == http.StatusNoContent {
+		return nil, nil
+	}
+	return &data, nil
+}
+
+// GetCurrentPlayingTrack returns the track playing on the active device
+func (c *SpotifyClient) GetCurrentPlayingTrack(ctx context.Context) (CurrentPlayingTrackResponse, error) {
+	state, err := c.GetPlaybackState(ctx)
+	if err != nil {
+		return CurrentPlayingTrackResponse{}, fmt.Errorf("failed to retrieve playback state: %w", err)
+	}
+	if state == nil {
+		return CurrentPlayingTrackResponse{}, ErrNoActiveDevice
+	}
+	if state.Item == nil {
+		return CurrentPlayingTrackResponse{}, ErrNothingPlaying
+	}
+
+	return CurrentPlayingTrackResponse{
+		TrackURI:      state.Item.URI,
+		Artist:        state.Item.FirstArtist(),
+		Artists:       state.Item.ArtistNames(),
+		Song:          state.Item.Name,
+		Album:         state.Item.Album.Name,
+		ImageURL:      state.Item.Album.ImageURL(),
+		TrackURL:      state.Item.URL(),
+		Explicit:      state.Item.Explicit,
+		IsPlaying:     state.IsPlaying,
+		DeviceId:      state.Device.ID,
+		DeviceName:    state.Device.Name,
+		VolumePercent: state.Device.VolumePercent,
+		ShuffleState:  state.ShuffleState,
+		RepeatState:   state.RepeatState,
+		ProgressMs:    state.ProgressMs,
+		DurationMs:    state.Item.DurationMs,
+	}, nil
+}
+
+// GetCurrentPlayingTrackOrWake is GetCurrentPlayingTrack for a user asking for the player: when no device
+// is active it wakes the default device, if one is configured, and reads the player again. The poller must
+// not use it, it would start a speaker nobody asked for.
+func (c *SpotifyClient) GetCurrentPlayingTrackOrWake(ctx context.Context) (CurrentPlayingTrackResponse, error) {
+	cpt, err := c.GetCurrentPlayingTrack(ctx)
+	if !errors.Is(err, ErrNoActiveDevice) || c.defaultDevice == "" {
+		return cpt, err
+	}
+	if _, err := c.GetActiveDevice(ctx); err != nil {
+		return CurrentPlayingTrackResponse{}, err
+	}
+	return c.GetCurrentPlayingTrack(ctx)
+}
+
+// GetUserQueue returns the track playing now and the tracks queued after it
+func (c *SpotifyClient) GetUserQueue(ctx context.Context) (UserQueue, error) {
+	var data SpotifyQueue
+	if _, err := c.do(ctx, http.MethodGet, "/v1/me/player/queue", nil, &data); err != nil {
+		return UserQueue{}, fmt.Errorf("queue request failed: %w", err)
+	}
+
+	var userQueue UserQueue
+	if data.CurrentlyPlaying != nil {
+		current := newUserQueueItem(*data.CurrentlyPlaying)
+		userQueue.CurrentlyPlaying = &current
+	}
+	for _, item := range data.Queue {
+		userQueue.Items = append(userQueue.Items, newUserQueueItem(item))
+	}
+
+	return userQueue, nil
+}
+
+// PauseTrack pauses playback on the active device
+func (c *SpotifyClient) PauseTrack(ctx context.Context) error {
+	_, err := c.do(ctx, http.MethodPut, "/v1/me/player/pause", nil, nil)
+	return err
+}
+
+// StartResumeTrack starts or resumes playback on the active device
+func (c *SpotifyClient) StartResumeTrack(ctx context.Context) error {
+	_, err := c.do(ctx, http.MethodPut, "/v1/me/player/play", nil, nil)
+	return err
+}
+
+// SkipToNextTrack skips to the next track in the user's queue
+func (c *SpotifyClient) SkipToNextTrack(ctx context.Context) error {
+	_, err := c.do(ctx, http.MethodPost, "/v1/me/player/next", nil, nil)
+	return err
+}
+
+// SkipToPreviousTrack skips to the previous track in the user's queue
+func (c *SpotifyClient) SkipToPreviousTrack(ctx context.Context) error {
+	_, err := c.do(ctx, http.MethodPost, "/v1/me/player/previous", nil, nil)
+	return err
+}
+
+// SetVolume sets the volume of the active device, percent from 0 to 100
+func (c *SpotifyClient) SetVolume(ctx context.Context, percent int) error {
+	params := url.Values{}
+	params.Set("volume_percent", strconv.Itoa(min(max(percent, 0), 100)))
+	_, err := c.do(ctx, http.MethodPut, "/v1/me/player/volume?"+params.Encode(), nil, nil)
+	return err
+}
+
+// SetShuffle turns shuffle on or off
+func (c *SpotifyClient) SetShuffle(ctx context.Context, shuffle bool) error {
+	params := url.Values{}
+	params.Set("state", strconv.FormatBool(shuffle))
+	_, err := c.do(ctx, http.MethodPut, "/v1/me/player/shuffle?"+params.Encode(), nil, nil)
+	return err
+}
+
+// SetRepeat sets the repeat mode: "off", "context" or "track"
+func (c *SpotifyClient) SetRepeat(ctx context.Context, state string) error {
+	params := url.Values{}
+	params.Set("state", state)
+	_, err := c.do(ctx, http.MethodPut, "/v1/me/player/repeat?"+params.Encode(), nil, nil)
+	return err
+}
+
+// SeekTo moves playback of the current track to positionMs
+func (c *SpotifyClient) SeekTo(ctx context.Context, positionMs int) error {
+	params := url.Values{}
+	params.Set("position_ms", strconv.Itoa(max(positionMs, 0)))
+	_, err := c.do(ctx, http.MethodPut, "/v1/me/player/seek?"+params.Encode(), nil, nil)
+	return err
+}
+
+// SearchTracks returns up to limit tracks matching query, best match first
+func (c *SpotifyClient) SearchTracks(ctx context.Context, query string, limit int) ([]SpotifyTrack, error) {
+	data, err := c.Search(ctx, query, []string{"track"}, limit)
+	if err != nil {
+		return nil, err
+	}
+	return data.Tracks.Items, nil
+}
+
+// Search returns up to limit results per type matching query, types being any of
+// "track", "album", "artist" and "playlist"
+func (c *SpotifyClient) Search(ctx context.Context, query string, types []string, limit int) (SpotifySearchResponse, error) {
+	params := url.Values{}
+	params.Set("q", query)
+	params.Set("type", strings.Join(types, ","))
+	params.Set("limit", strconv.Itoa(limit))
+
+	var data SpotifySearchResponse
+	if _, err := c.do(ctx, http.MethodGet, "/v1/search?"+params.Encode(), nil, &data); err != nil {
+		return SpotifySearchResponse{}, fmt.Errorf("search request failed: %w", err)
+	}
+	return data, nil
+}
+
+// PlayURI starts playing uri on deviceId, or on the active device when deviceId is empty.
+// A track is played on its own, albums, artists and playlists are played as a context.
+func (c *SpotifyClient) PlayURI(ctx context.Context, uri string, deviceId string) error {
+	var body interface{}
+	if strings.HasPrefix(uri, "spotify:track:") || strings.HasPrefix(uri, "spotify:episode:") {
+		body = map[string][]string{"uris": {uri}}
+	} else {
+		body = map[string]string{"context_uri": uri}
+	}
+	payload, err := json.Marshal(body)
+	if err != nil {
+		return fmt.Errorf("failed to encode play request: %w", err)
+	}
+
+	path := "/v1/me/player/play"
+	if deviceId != "" {
+		path += "?" + url.Values{"device_id": {deviceId}}.Encode()
+	}
+	_, err = c.do(ctx, http.MethodPut, path, payload, nil)
+	return err
+}
+
+// AddToQueue appends the track or episode with the given URI to the queue of deviceId,
+// or of the active device when deviceId is empty
+func (c *SpotifyClient) AddToQueue(ctx context.Context, uri string, deviceId string) error {
+	params := url.Values{}
+	params.Set("uri", uri)
+	if deviceId != "" {
+		params.Set("device_id", deviceId)
+	}
+	_, err := c.do(ctx, http.MethodPost, "/v1/me/player/queue?"+params.Encode(), nil, nil)
+	return err
+}
+
+// playlistPages bounds how many pages of 50 playlists GetPlaylists reads
+const playlistPages = 4
+
+// GetPlaylists lists the playlists the user owns or follows, in the order Spotify shows them
+func (c *SpotifyClient) GetPlaylists(ctx context.Context) ([]SpotifyPlaylist, error) {
+	var playlists []SpotifyPlaylist
+	for page := 0; page < playlistPages; page++ {
+		params := url.Values{}
+		params.Set("limit", "50")
+		params.Set("offset", strconv.Itoa(page*50))
+
+		var data SpotifyPlaylistsResponse
+		if _, err := c.do(ctx, http.MethodGet, "/v1/me/playlists?"+params.Encode(), nil, &data); err != nil {
+			return nil, fmt.Errorf("playlists request failed: %w", err)
+		}
+		for _, playlist := range data.Items {
+			if playlist != nil {
+				playlists = append(playlists, *playlist)
+			}
+		}
+		if data.Next == "" {
+			break
+		}
+	}
+	return playlists, nil
+}
+
+// GetCurrentUserID returns the Spotify user ID of the account
+func (c *SpotifyClient) GetCurrentUserID(ctx context.Context) (string, error) {
+	var data struct {
+		ID string `json:"id"`
+	}
+	if _, err := c.do(ctx, http.MethodGet, "/v1/me", nil, &data); err != nil {
+		return "", fmt.Errorf("profile request failed: %w", err)
+	}
+	return data.ID, nil
+}
+
+// CreatePlaylist creates a public playlist owned by the account
+func (c *SpotifyClient) CreatePlaylist(ctx context.Context, name string, description string) (SpotifyPlaylist, error) {
+	userId, err := c.GetCurrentUserID(ctx)
+	if err != nil {
+		return SpotifyPlaylist{}, err
+	}
+	payload, err := json.Marshal(map[string]interface{}{"name": name, "description": description, "public": true})
+	if err != nil {
+		return SpotifyPlaylist{}, fmt.Errorf("failed to encode playlist: %w", err)
+	}
+
+	var playlist SpotifyPlaylist
+	if _, err := c.do(ctx, http.MethodPost, "/v1/users/"+url.PathEscape(userId)+"/playlists", payload, &playlist); err != nil {
+		return SpotifyPlaylist{}, fmt.Errorf("create playlist request failed: %w", err)
+	}
+	return playlist, nil
+}
+
+// PlaylistContains reports whether the track or episode with the given URI is in the playlist
+func (c *SpotifyClient) PlaylistContains(ctx context.Context, playlistId string, uri string) (bool, error) {
+	for offset := 0; ; offset += playlistBatchSize {
+		params := url.Values{}
+		params.Set("fields", "items(track(uri)),next")
+		params.Set("limit", strconv.Itoa(playlistBatchSize))
+		params.Set("offset", strconv.Itoa(offset))
+
+		var data struct {
+			Items []struct {
+				Track *struct {
+					URI string `json:"uri"`
+				} `json:"track"`
+			} `json:"items"`
+			Next string `json:"next"`
+		}
+		if _, err := c.do(ctx, http.MethodGet, "/v1/playlists/"+url.PathEscape(playlistId)+"/tracks?"+params.Encode(), nil, &data); err != nil {
+			return false, fmt.Errorf("playlist items request failed: %w", err)
+		}
+		for _, item := range data.Items {
+			// Spotify returns a null track for items it cannot show
+			if item.Track != nil && item.Track.URI == uri {
+				return true, nil
+			}
+		}
+		if data.Next == "" {
+			return false, nil
+		}
+	}
+}
+
+// playlistBatchSize is as many items as Spotify adds to a playlist in one request
+const playlistBatchSize = 100
+
+// AddToPlaylist appends the tracks or episodes with the given URIs to the playlist, in batches
+// of playlistBatchSize
+func (c *SpotifyClient) AddToPlaylist(ctx context.Context, playlistId string, uris []string) error {
+	for len(uris) > 0 {
+		batch := uris[:min(len(uris), playlistBatchSize)]
+		uris = uris[len(batch):]
+
+		payload, err := json.Marshal(map[string][]string{"uris": batch})
+		if err != nil {
+			return fmt.Errorf("failed to encode playlist items: %w", err)
+		}
+		if _, err := c.do(ctx, http.MethodPost, "/v1/playlists/"+url.PathEscape(playlistId)+"/tracks", payload, nil); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// FormatDuration renders milliseconds as m:ss
+func FormatDuration(durationMs int) string {
+	return fmt.Sprintf("%d:%02d", durationMs/60000, (durationMs/1000)%60)
+}
@@ -0,0 +1,296 @@
+package misc
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+func newTestSpotifyClient(t *testing.T, handler http.Handler) *SpotifyClient {
+	t.Helper()
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+	tokens := TokenSourceFunc(func(ctx context.Context) (string, error) {
+		return "test-token", nil
+	})
+	return NewSpotifyClient(server.URL, server.Client(), tokens)
+}
+
+func TestSpotifyClientGetCurrentPlayingTrack(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/v1/me/player", func(w http.ResponseWriter, r *http.Request) {
+		if got := r.Header.Get("Authorization"); got != "Bearer test-token" {
+			t.Errorf("Authorization header = %q", got)
+		}
+		w.Write([]byte(`{
+			"device":{"id":"speaker","name":"Kitchen","is_active":true,"volume_percent":40},
+			"shuffle_state":true,"repeat_state":"context","is_playing":true,"progress_ms":1000,
+			"item":{"name":"Dancing Queen","duration_ms":231000,"explicit":false,
+				"artists":[{"name":"ABBA"},{"name":"Benny"}],
+				"album":{"name":"Arrival","images":[{"url":"big"},{"url":"medium"}]},
+				"external_urls":{"spotify":"https://open.spotify.com/track/1"}}}`))
+	})
+	client := newTestSpotifyClient(t, mux)
+
+	track, err := client.GetCurrentPlayingTrack(context.Background())
+	if err != nil {
+		t.Fatalf("GetCurrentPlayingTrack returned error: %v", err)
+	}
+	volume := 40
+	want := CurrentPlayingTrackResponse{
+		Artist:        "ABBA",
+		Artists:       []string{"ABBA", "Benny"},
+		Song:          "Dancing Queen",
+		Album:         "Arrival",
+		ImageURL:      "medium",
+		TrackURL:      "https://open.spotify.com/track/1",
+		IsPlaying:     true,
+		DeviceId:      "speaker",
+		DeviceName:    "Kitchen",
+		VolumePercent: &volume,
+		ShuffleState:  true,
+		RepeatState:   "context",
+		ProgressMs:    1000,
+		DurationMs:    231000,
+	}
+	if !reflect.DeepEqual(track, want) {
+		t.Errorf("GetCurrentPlayingTrack = %+v, want %+v", track, want)
+	}
+}
+
+func TestSpotifyClientNothingPlaying(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/v1/me/player", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"device":{"id":"speaker","is_active":true},"is_playing":false,"item":null}`))
+	})
+	client := newTestSpotifyClient(t, mux)
+
+	if _, err := client.GetCurrentPlayingTrack(context.Background()); !errors.Is(err, ErrNothingPlaying) {
+		t.Fatalf("GetCurrentPlayingTrack error = %v, want ErrNothingPlaying", err)
+	}
+}
+
+func TestSpotifyClientGetUserQueueHandlesMissingImages(t *testing.T) {
+	client := newTestSpotifyClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"queue":[{"name":"Intro","duration_ms":65000,"artists":[],"album":{"images":[]}}]}`))
+	}))
+
+	queue, err := client.GetUserQueue(context.Background())
+	if err != nil {
+		t.Fatalf("GetUserQueue returned error: %v", err)
+	}
+	if queue.CurrentlyPlaying != nil || len(queue.Items) != 1 || queue.Items[0].SongTitle != "Intro" || queue.Items[0].Duration != "1:05" {
+		t.Errorf("unexpected queue: %+v", queue)
+	}
+}
+
+func TestSpotifyClientPlaybackControls(t *testing.T) {
+	var calls []string
+	client := newTestSpotifyClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls = append(calls, r.Method+" "+r.URL.Path)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+
+	ctx := context.Background()
+	for _, call := range []func(context.Context) error{
+		client.PauseTrack,
+		client.StartResumeTrack,
+		client.SkipToNextTrack,
+		client.SkipToPreviousTrack,
+	} {
+		if err := call(ctx); err != nil {
+			t.Fatalf("playback call returned error: %v", err)
+		}
+	}
+
+	want := []string{
+		"PUT /v1/me/player/pause",
+		"PUT /v1/me/player/play",
+		"POST /v1/me/player/next",
+		"POST /v1/me/player/previous",
+	}
+	if len(calls) != len(want) {
+		t.Fatalf("calls = %v, want %v", calls, want)
+	}
+	for i := range want {
+		if calls[i] != want[i] {
+			t.Errorf("call %d = %q, want %q", i, calls[i], want[i])
+		}
+	}
+}
+
+func TestSpotifyClientSearchAndQueue(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/v1/search", func(w http.ResponseWriter, r *http.Request) {
+		if q := r.URL.Query(); q.Get("q") != "dancing queen" || q.Get("type") != "track" || q.Get("limit") != "5" {
+			t.Errorf("search query = %s", r.URL.RawQuery)
+		}
+		w.Write([]byte(`{"tracks":{"items":[{"name":"Dancing Queen","uri":"spotify:track:1","artists":[{"name":"ABBA"}]}]}}`))
+	})
+	mux.HandleFunc("/v1/me/player/queue", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.URL.Query().Get("uri") != "spotify:track:1" || r.URL.Query().Get("device_id") != "speaker" {
+			t.Errorf("queue request = %s %s", r.Method, r.URL.RawQuery)
+		}
+		w.WriteHeader(http.StatusNoContent)
+	})
+	client := newTestSpotifyClient(t, mux)
+
+	tracks, err := client.SearchTracks(context.Background(), "dancing queen", 5)
+	if err != nil || len(tracks) != 1 || tracks[0].URI != "spotify:track:1" {
+		t.Fatalf("SearchTracks = %+v, %v", tracks, err)
+	}
+	if err := client.AddToQueue(context.Background(), tracks[0].URI, "speaker"); err != nil {
+		t.Errorf("AddToQueue returned error: %v", err)
+	}
+}
+
+func TestSpotifyClientSearchAndPlay(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/v1/search", func(w http.ResponseWriter, r *http.Request) {
+		if q := r.URL.Query(); q.Get("type") != "track,album,artist,playlist" {
+			t.Errorf("search query = %s", r.URL.RawQuery)
+		}
+		w.Write([]byte(`{"tracks":{"items":[{"name":"Dancing Queen","uri":"spotify:track:1","artists":[{"name":"ABBA"}]}]},` +
+			`"albums":{"items":[{"name":"Arrival","uri":"spotify:album:2","artists":[{"name":"ABBA"}]}]},` +
+			`"artists":{"items":[]},"playlists":{"items":[null,{"name":"Disco","uri":"spotify:playlist:3","owner":{"display_name":"DJ"}}]}}`))
+	})
+	var played []string
+	mux.HandleFunc("/v1/me/player/play", func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		played = append(played, r.URL.Query().Get("device_id")+" "+string(body))
+		w.WriteHeader(http.StatusNoContent)
+	})
+	client := newTestSpotifyClient(t, mux)
+
+	options, err := client.SearchOptions(context.Background(), "abba")
+	if err != nil {
+		t.Fatalf("SearchOptions returned error: %v", err)
+	}
+	var labels []string
+	for _, group := range options.OptionGroups {
+		labels = append(labels, group.Label.Text+": "+group.Options[0].Text.Text)
+	}
+	want := []string{"🎵 Tracks: Dancing Queen — ABBA", "💿 Albums: Arrival — ABBA", "📜 Playlists: Disco — by DJ"}
+	if !reflect.DeepEqual(labels, want) {
+		t.Errorf("option groups = %q, want %q", labels, want)
+	}
+
+	if err := client.PlayURI(context.Background(), "spotify:track:1", "speaker"); err != nil {
+		t.Fatalf("PlayURI returned error: %v", err)
+	}
+	if err := client.PlayURI(context.Background(), "spotify:album:2", ""); err != nil {
+		t.Fatalf("PlayURI returned error: %v", err)
+	}
+	want = []string{`speaker {"uris":["spotify:track:1"]}`, ` {"context_uri":"spotify:album:2"}`}
+	if !reflect.DeepEqual(played, want) {
+		t.Errorf("play requests = %q, want %q", played, want)
+	}
+}
+
+func TestSpotifyClientPlayerSettings(t *testing.T) {
+	var calls []string
+	client := newTestSpotifyClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls = append(calls, r.Method+" "+r.URL.Path+"?"+r.URL.RawQuery)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+
+	ctx := context.Background()
+	for _, err := range []error{
+		client.SetVolume(ctx, 110),
+		client.SetShuffle(ctx, true),
+		client.SetRepeat(ctx, "context"),
+		client.SeekTo(ctx, 90000),
+	} {
+		if err != nil {
+			t.Fatalf("player setting returned error: %v", err)
+		}
+	}
+	want := []string{
+		"PUT /v1/me/player/volume?volume_percent=100",
+		"PUT /v1/me/player/shuffle?state=true",
+		"PUT /v1/me/player/repeat?state=context",
+		"PUT /v1/me/player/seek?position_ms=90000",
+	}
+	if !reflect.DeepEqual(calls, want) {
+		t.Errorf("calls = %q, want %q", calls, want)
+	}
+}
+
+func TestSpotifyClientWakesDefaultDevice(t *testing.T) {
+	var transferred string
+	mux := http.NewServeMux()
+	mux.HandleFunc("/v1/me/player/devices", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"devices":[{"id":"laptop","name":"Laptop","is_active":false},{"id":"speaker","name":"Office Speaker","is_active":false}]}`))
+	})
+	mux.HandleFunc("/v1/me/player", func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		transferred = r.Method + " " + string(body)
+		w.WriteHeader(http.StatusNoContent)
+	})
+	client := newTestSpotifyClient(t, mux)
+
+	if _, err := client.GetActiveDevice(context.Background()); !errors.Is(err, ErrNoActiveDevice) {
+		t.Fatalf("GetActiveDevice error = %v, want ErrNoActiveDevice without a default device", err)
+	}
+
+	deviceId, err := client.WithDefaultDevice("office speaker").GetActiveDevice(context.Background())
+	if err != nil || deviceId != "speaker" {
+		t.Fatalf("GetActiveDevice = %q, %v, want the default device", deviceId, err)
+	}
+	if want := `PUT {"device_ids":["speaker"],"play":false}`; transferred != want {
+		t.Errorf("transfer request = %s, want %s", transferred, want)
+	}
+}
+
+func TestSpotifyClientPlayWakesDefaultDevice(t *testing.T) {
+	var requests []string
+	awake := false
+	mux := http.NewServeMux()
+	mux.HandleFunc("/v1/me/player/devices", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"devices":[{"id":"speaker","name":"Office Speaker","is_active":false}]}`))
+	})
+	mux.HandleFunc("/v1/me/player", func(w http.ResponseWriter, r *http.Request) {
+		requests = append(requests, r.Method+" /v1/me/player")
+		if r.Method == http.MethodPut {
+			awake = true
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		if !awake {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		w.Write([]byte(`{"device":{"id":"speaker","is_active":true},"is_playing":false,"item":{"uri":"spotify:track:1","name":"Dancing Queen","artists":[{"name":"ABBA"}]}}`))
+	})
+	mux.HandleFunc("/v1/me/player/play", func(w http.ResponseWriter, r *http.Request) {
+		requests = append(requests, r.Method+" /v1/me/player/play")
+		w.WriteHeader(http.StatusNoContent)
+	})
+	client := newTestSpotifyClient(t, mux)
+
+	if _, err := client.GetCurrentPlayingTrackOrWake(context.Background()); !errors.Is(err, ErrNoActiveDevice) {
+		t.Fatalf("GetCurrentPlayingTrackOrWake error = %v, want ErrNoActiveDevice without a default device", err)
+	}
+
+	// ▶️ on the dashboard of an idle office speaker
+	requests = nil
+	client = client.WithDefaultDevice("Office Speaker")
+	cpt, err := client.GetCurrentPlayingTrackOrWake(context.Background())
+	if err != nil {
+		t.Fatalf("GetCurrentPlayingTrackOrWake returned error: %v", err)
+	}
+	if cpt.DeviceId != "speaker" || cpt.IsPlaying {
+		t.Errorf("track = %+v, want the paused default device", cpt)
+	}
+	if err := client.StartResumeTrack(context.Background()); err != nil {
+		t.Fatalf("StartResumeTrack returned error: %v", err)
+	}
+	want := []string{"GET /v1/me/player", "PUT /v1/me/player", "GET /v1/me/player", "PUT /v1/me/player/play"}
+	if !reflect.DeepEqual(requests, want) {
+		t.Errorf("requests = %q, want %q", requests, want)
+	}
+}
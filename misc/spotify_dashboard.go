@@ -3,9 +3,10 @@
 package misc
 
 import (
-	"encoding/json"
+	"context"
 	"fmt"
-	"net/http"
+	"log"
+	"strings"
 	"sync"
 	"time"
 
@@ -16,427 +17,531 @@ import (
 type SpotifyDashboard struct {
 	Artist                string
 	Song                  string
+	TrackURI              string // the track shown, empty if unknown
 	ImageURL              string
 	SlackMessageTimestamp string
 	SlackChannelId        string
 	IsPlaying             bool
 	DeviceId              string
+	AccountKey            string          // token key of the Spotify account the dashboard controls
+	LastAction            string          // shown as "Last Action" until the next click
+	LastUserName          string          // shown next to "Last Action" until the next click
+	PlayerState           string          // device, volume, shuffle and repeat as last rendered, see playerStateKey
+	mutedVolume           int             // the volume before the dashboard muted the player, 0 if unknown
+	voteTrackURI          string          // the track the skip votes are for
+	skipVotes             map[string]bool // Slack user IDs who voted to skip voteTrackURI
+	skipVotesRequired     int             // votes needed to skip, as of the last vote
+	renderedSkipVotes     int             // skip votes shown in the message
+	renderedProgress      int             // the progress step shown in the message, see progressStepMs
+	lastActivity          time.Time       // when the dashboard was posted, clicked or its track changed
+	wake                  chan struct{}   // shared with the other dashboards of its DashboardManager
+	store                 DashboardStore
 	mu                    sync.Mutex // Add a sync.Mutex for synchronization
 }
 
-var MySpotifyDashboard SpotifyDashboard
-var stopPolling chan struct{} // Channel to signal stopping the polling
-
-// StartSpotifyPolling starts the polling mechanism
-func StartSpotifyPolling(client *slack.Client) {
-	//stopPolling = make(chan struct{})
-	//
-	//go func() {
-	//	for {
-	//		select {
-	//		case <-stopPolling:
-	//			return // Stop polling when signal is received
-	//		default:
-	//			// Polling logic here
-	//			// For example, update the dashboard
-	//			_, err := MySpotifyDashboard.AutoUpdateCurrentSpotifyDashboard(client)
-	//			if err != nil {
-	//				fmt.Printf("Error updating Spotify dashboard: %v\n", err)
-	//			}
-	//
-	//			// Sleep for a specified interval (e.g., 1 second)
-	//			time.Sleep(1 * time.Second)
-	//		}
-	//	}
-	//}()
-}
-
-// StopSpotifyPolling stops the polling mechanism
-func StopSpotifyPolling() {
-	if stopPolling != nil {
-		close(stopPolling)
-	}
-}
-
-// AutoUpdateCurrentSpotifyDashboard updates the SpotifyDashboard with the latest information
-func (sd *SpotifyDashboard) AutoUpdateCurrentSpotifyDashboard(client *slack.Client, lastAction string, userName string) (slack.Attachment, error) {
+// AutoUpdateCurrentSpotifyDashboard updates the SpotifyDashboard with the latest information. It always
+// edits the message, so the click shows as the last action even when nothing else changed.
+func (sd *SpotifyDashboard) AutoUpdateCurrentSpotifyDashboard(ctx context.Context, client *slack.Client, lastAction string, userName string) (slack.Attachment, error) {
 	sd.mu.Lock()
 	defer sd.mu.Unlock()
 
-	currentPlayingTrack, err := GetCurrentPlayingTrack()
+	sd.LastAction = lastAction
+	sd.LastUserName = userName
+	sd.touch()
+	sd.save()
+
+	spotifyAttachment, _, err := sd.update(ctx, client, true)
 	if err != nil {
-		// Check if the error is a 429 response
+		// Spotify asked us to slow down. The client already holds back every request until
+		// then, so skip this update rather than sleep on the lock and stall the other controls.
 		if isRateLimitError(err) {
-			// Retry after the specified time
-			retryAfter, err := getRetryAfterValue(err)
-			if err != nil {
-				return slack.Attachment{}, fmt.Errorf("failed to parse Retry-After header: %w", err)
-			}
-			time.Sleep(time.Duration(retryAfter) * time.Second)
+			retryAfter, _ := getRetryAfterValue(err)
+			log.Printf("Spotify rate limited the dashboard update, retrying after %s", retryAfter)
 			return slack.Attachment{}, nil
 		}
 		return slack.Attachment{}, fmt.Errorf("GetCurrentPlayingTrack failed with error: %w", err)
 	}
+	return spotifyAttachment, nil
+}
+
+// Poll brings the dashboard message in line with what is playing, keeping the last action,
+// and returns how much of the current track is left while it is playing
+func (sd *SpotifyDashboard) Poll(ctx context.Context, client *slack.Client) (time.Duration, error) {
+	sd.mu.Lock()
+	defer sd.mu.Unlock()
+
+	if sd.SlackMessageTimestamp == "" {
+		return 0, nil
+	}
+	_, currentPlayingTrack, err := sd.update(ctx, client, false)
+	if err != nil {
+		return 0, err
+	}
+	if !currentPlayingTrack.IsPlaying || currentPlayingTrack.DurationMs == 0 {
+		return 0, nil
+	}
+	return time.Duration(currentPlayingTrack.DurationMs-currentPlayingTrack.ProgressMs) * time.Millisecond, nil
+}
+
+// update fetches the currently playing track and edits the dashboard message when it changed, or always
+// if force is set, returning an empty attachment when there was nothing to edit. The caller holds sd.mu.
+func (sd *SpotifyDashboard) update(ctx context.Context, client *slack.Client, force bool) (slack.Attachment, CurrentPlayingTrackResponse, error) {
+	currentPlayingTrack, err := SpotifyForAccount(sd.AccountKey).GetDashboardTrack(ctx, false)
+	if err != nil {
+		return slack.Attachment{}, CurrentPlayingTrackResponse{}, err
+	}
+	currentPlayingTrack.RequestedBy = QueueRequests.RequestedBy(sd.AccountKey, currentPlayingTrack.TrackURI)
+	PlayHistory.Observe(sd.SlackChannelId, sd.AccountKey, currentPlayingTrack)
+	if currentPlayingTrack.TrackURI != sd.voteTrackURI {
+		// A new track, the votes to skip the previous one don't carry over
+		sd.voteTrackURI = currentPlayingTrack.TrackURI
+		sd.skipVotes = nil
+	}
+	currentPlayingTrack.SkipVotes = len(sd.skipVotes)
+	currentPlayingTrack.SkipVotesRequired = sd.skipVotesRequired
 
 	// Check if the currently playing track is the same as the one in the dashboard
-	// and the state is the same
-	if currentPlayingTrack.Song == sd.Song && currentPlayingTrack.IsPlaying == sd.IsPlaying {
-		// No need to update, as the track is the same and the state as well.
-		return slack.Attachment{}, nil
+	// and the state, player controls and skip votes are the same
+	playerState := playerStateKey(currentPlayingTrack)
+	changed := currentPlayingTrack.TrackURI != sd.TrackURI || currentPlayingTrack.IsPlaying != sd.IsPlaying ||
+		playerState != sd.PlayerState || len(sd.skipVotes) != sd.renderedSkipVotes
+	// Playback moving on only redraws the progress bar and elapsed time
+	progress := currentPlayingTrack.ProgressMs / progressStepMs
+	if !force && !changed && progress == sd.renderedProgress {
+		// No need to update, as the track is the same and the state, controls, votes and progress as well.
+		return slack.Attachment{}, currentPlayingTrack, nil
 	}
 
 	sd.Artist = currentPlayingTrack.Artist
 	sd.Song = currentPlayingTrack.Song
+	sd.TrackURI = currentPlayingTrack.TrackURI
 	sd.ImageURL = currentPlayingTrack.ImageURL
 	sd.IsPlaying = currentPlayingTrack.IsPlaying
 	sd.DeviceId = currentPlayingTrack.DeviceId
-
-	spotifyAttachment := BuildSpotifyAttachment(currentPlayingTrack, lastAction, userName)
-	_, _, _, err = client.UpdateMessage(
+	sd.PlayerState = playerState
+	sd.renderedSkipVotes = len(sd.skipVotes)
+	sd.renderedProgress = progress
+	if changed {
+		// The track or its state changed, someone is still listening
+		sd.lastActivity = time.Now()
+		sd.save()
+	}
+
+	spotifyAttachment := BuildSpotifyAttachment(currentPlayingTrack, sd.LastAction, sd.LastUserName)
+	_, _, _, err = client.UpdateMessageContext(
+		ctx,
 		sd.SlackChannelId,
 		sd.SlackMessageTimestamp,
 		slack.MsgOptionAttachments(spotifyAttachment),
 	)
 	if err != nil {
-		return slack.Attachment{}, fmt.Errorf("client.UpdateMessage failed to update message: %w", err)
+		return slack.Attachment{}, currentPlayingTrack, fmt.Errorf("client.UpdateMessage failed to update message: %w", err)
 	}
-	return spotifyAttachment, nil
+	return spotifyAttachment, currentPlayingTrack, nil
 }
 
-func (sd *SpotifyDashboard) CreateSpotifyDashboard(cpt CurrentPlayingTrackResponse, timestamp string, channelId string) {
+// touch records activity on the dashboard and wakes the poller. The caller holds sd.mu.
+func (sd *SpotifyDashboard) touch() {
+	sd.lastActivity = time.Now()
+	if sd.wake == nil {
+		return
+	}
+	select {
+	case sd.wake <- struct{}{}:
+	default:
+	}
+}
+
+// save persists the dashboard, if it belongs to a store. The caller holds sd.mu.
+func (sd *SpotifyDashboard) save() {
+	if sd.store == nil {
+		return
+	}
+	if err := sd.store.Save(sd.record()); err != nil {
+		log.Printf("failed to persist spotify dashboard %s/%s: %v", sd.SlackChannelId, sd.SlackMessageTimestamp, err)
+	}
+}
+
+// record returns what is persisted of the dashboard. The caller holds sd.mu.
+func (sd *SpotifyDashboard) record() DashboardRecord {
+	return DashboardRecord{
+		ChannelId:        sd.SlackChannelId,
+		MessageTimestamp: sd.SlackMessageTimestamp,
+		AccountKey:       sd.AccountKey,
+		DeviceId:         sd.DeviceId,
+		Artist:           sd.Artist,
+		Song:             sd.Song,
+		TrackURI:         sd.TrackURI,
+		ImageURL:         sd.ImageURL,
+		IsPlaying:        sd.IsPlaying,
+		LastAction:       sd.LastAction,
+		LastUserName:     sd.LastUserName,
+		LastActivity:     sd.lastActivity,
+	}
+}
+
+// Active reports whether the dashboard was posted and saw activity within idleTimeout
+func (sd *SpotifyDashboard) Active(idleTimeout time.Duration) bool {
+	sd.mu.Lock()
+	defer sd.mu.Unlock()
+	return sd.SlackMessageTimestamp != "" && time.Since(sd.lastActivity) < idleTimeout
+}
+
+func (sd *SpotifyDashboard) CreateSpotifyDashboard(cpt CurrentPlayingTrackResponse, timestamp string, channelId string, accountKey string, lastAction string, userName string) {
 	sd.mu.Lock()
 	defer sd.mu.Unlock()
 
 	sd.Artist = cpt.Artist
 	sd.Song = cpt.Song
+	sd.TrackURI = cpt.TrackURI
 	sd.ImageURL = cpt.ImageURL
 	sd.IsPlaying = cpt.IsPlaying
 	sd.DeviceId = cpt.DeviceId
+	sd.voteTrackURI = cpt.TrackURI
 	sd.SlackMessageTimestamp = timestamp
 	sd.SlackChannelId = channelId
+	sd.AccountKey = accountKey
+	sd.LastAction = lastAction
+	sd.LastUserName = userName
+	sd.touch()
+	sd.save()
 }
 
-type CurrentPlayingTrackResponse struct {
-	Artist    string
-	Song      string
-	ImageURL  string
-	IsPlaying bool
-	DeviceId  string
+// GetAccountKey returns the token key of the account the dashboard controls
+func (sd *SpotifyDashboard) GetAccountKey() string {
+	sd.mu.Lock()
+	defer sd.mu.Unlock()
+	return sd.AccountKey
 }
 
-// GetActiveDevice retrieves the active device ID
-func GetActiveDevice() (string, error) {
-	// Make a GET request to Spotify API to get the list of devices
-	accessToken := Shared.GetSpotifyAccessToken()
-	devicesURL := "https://api.spotify.com/v1/me/player/devices"
-	devicesReq, err := http.NewRequest("GET", devicesURL, nil)
-	if err != nil {
-		return "", fmt.Errorf("failed to create devices request: %w", err)
-	}
+// GetChannelId returns the channel the dashboard was posted in
+func (sd *SpotifyDashboard) GetChannelId() string {
+	sd.mu.Lock()
+	defer sd.mu.Unlock()
+	return sd.SlackChannelId
+}
+
+// GetMessageTimestamp returns the timestamp of the dashboard message
+func (sd *SpotifyDashboard) GetMessageTimestamp() string {
+	sd.mu.Lock()
+	defer sd.mu.Unlock()
+	return sd.SlackMessageTimestamp
+}
 
-	devicesReq.Header.Set("Authorization", "Bearer "+accessToken)
+// Retire replaces the dashboard's controls with reason, after which its buttons are gone
+func (sd *SpotifyDashboard) Retire(ctx context.Context, client *slack.Client, reason string) error {
+	sd.mu.Lock()
+	defer sd.mu.Unlock()
 
-	httpClient := &http.Client{}
-	devicesResp, err := httpClient.Do(devicesReq)
+	track := CurrentPlayingTrackResponse{
+		Artist:    sd.Artist,
+		Song:      sd.Song,
+		ImageURL:  sd.ImageURL,
+		IsPlaying: sd.IsPlaying,
+		DeviceId:  sd.DeviceId,
+	}
+	_, _, _, err := client.UpdateMessageContext(
+		ctx,
+		sd.SlackChannelId,
+		sd.SlackMessageTimestamp,
+		slack.MsgOptionAttachments(BuildRetiredSpotifyAttachment(track, reason)),
+	)
 	if err != nil {
-		return "", fmt.Errorf("failed to make devices request: %w", err)
+		return fmt.Errorf("client.UpdateMessage failed to retire dashboard: %w", err)
 	}
-	defer devicesResp.Body.Close()
+	return nil
+}
 
-	if devicesResp.StatusCode != http.StatusOK {
-		return "", fmt.Errorf("unexpected devices response: %s", devicesResp.Status)
-	}
+// VoteSkip records userId's vote to skip trackURI, one per user and track. It returns the votes so far
+// and whether they reached required. The votes are kept until ResetSkipVotes or the track changes, so
+// a skip that failed can be retried by any voter.
+func (sd *SpotifyDashboard) VoteSkip(trackURI string, userId string, required int) (int, bool, error) {
+	sd.mu.Lock()
+	defer sd.mu.Unlock()
 
-	var devicesData map[string][]map[string]interface{}
-	if err := json.NewDecoder(devicesResp.Body).Decode(&devicesData); err != nil {
-		return "", fmt.Errorf("failed to decode devices response: %w", err)
+	if trackURI != sd.voteTrackURI {
+		sd.voteTrackURI = trackURI
+		sd.skipVotes = nil
 	}
-
-	// Find the active device
-	for _, device := range devicesData["devices"] {
-		if isActive := device["is_active"].(bool); isActive {
-			return device["id"].(string), nil
+	if sd.skipVotes[userId] {
+		if len(sd.skipVotes) >= required {
+			return len(sd.skipVotes), true, nil
 		}
+		return len(sd.skipVotes), false, UserError("You already voted to skip this track.")
 	}
-
-	return "", fmt.Errorf("no active device found")
-}
-
-func GetCurrentPlayingTrack() (CurrentPlayingTrackResponse, error) {
-	accessToken := Shared.GetSpotifyAccessToken()
-	deviceId, err := GetActiveDevice()
-	if err != nil {
-		return CurrentPlayingTrackResponse{}, fmt.Errorf("failed to retrieve active device")
-	}
-	// Make a GET request to Spotify API
-	url := "https://api.spotify.com/v1/me/player/currently-playing"
-	req, err := http.NewRequest("GET", url, nil)
-	if err != nil {
-		return CurrentPlayingTrackResponse{}, fmt.Errorf("failed to create request: %w", err)
+	if sd.skipVotes == nil {
+		sd.skipVotes = map[string]bool{}
 	}
+	sd.skipVotes[userId] = true
+	sd.skipVotesRequired = required
 
-	req.Header.Set("Authorization", "Bearer "+accessToken)
+	votes := len(sd.skipVotes)
+	return votes, votes >= required, nil
+}
 
-	httpClient := &http.Client{}
-	resp, err := httpClient.Do(req)
-	if err != nil {
-		return CurrentPlayingTrackResponse{}, fmt.Errorf("failed to make request: %w", err)
+// ResetSkipVotes forgets the votes to skip trackURI once it was skipped, the count starts over for the next track
+func (sd *SpotifyDashboard) ResetSkipVotes(trackURI string) {
+	sd.mu.Lock()
+	defer sd.mu.Unlock()
+	if trackURI == sd.voteTrackURI {
+		sd.skipVotes = nil
 	}
-	defer resp.Body.Close()
+}
 
-	if resp.StatusCode == http.StatusNoContent {
-		// No currently playing track
-		return CurrentPlayingTrackResponse{}, fmt.Errorf("no currently playing track")
-	}
+// Mute remembers the volume from before muting, for Unmute to restore
+func (sd *SpotifyDashboard) Mute(volumePercent int) {
+	sd.mu.Lock()
+	defer sd.mu.Unlock()
+	sd.mutedVolume = volumePercent
+}
 
-	if resp.StatusCode != http.StatusOK {
-		return CurrentPlayingTrackResponse{}, fmt.Errorf("unexpected response: %s", resp.Status)
+// Unmute returns the volume to restore, the one from before muting if the dashboard knows it
+func (sd *SpotifyDashboard) Unmute() int {
+	sd.mu.Lock()
+	defer sd.mu.Unlock()
+	volume := sd.mutedVolume
+	sd.mutedVolume = 0
+	if volume == 0 {
+		return defaultUnmuteVolume
 	}
+	return volume
+}
 
-	var data map[string]interface{}
-	if err := json.NewDecoder(resp.Body).Decode(&data); err != nil {
-		return CurrentPlayingTrackResponse{}, fmt.Errorf("failed to decode response: %w", err)
-	}
+// AuditEntry starts the audit entry of an action the user of the interaction took on the dashboard,
+// naming the track it shows
+func (sd *SpotifyDashboard) AuditEntry(action string, interaction slack.InteractionCallback) AuditEntry {
+	sd.mu.Lock()
+	defer sd.mu.Unlock()
+	track := CurrentPlayingTrackResponse{TrackURI: sd.voteTrackURI, Song: sd.Song, Artist: sd.Artist}
+	return NewAuditEntry(action, interaction, sd.AccountKey).WithTrack(track)
+}
 
-	// Extract the information from 'data'
-	artist := data["item"].(map[string]interface{})["artists"].([]interface{})[0].(map[string]interface{})["name"].(string)
-	song := data["item"].(map[string]interface{})["name"].(string)
-	imageURL := data["item"].(map[string]interface{})["album"].(map[string]interface{})["images"].([]interface{})[1].(map[string]interface{})["url"].(string)
-	isPlaying := data["is_playing"].(bool)
+// SpotifyClient returns a client for the account the dashboard controls
+func (sd *SpotifyDashboard) SpotifyClient() *SpotifyClient {
+	sd.mu.Lock()
+	defer sd.mu.Unlock()
+	return SpotifyForAccount(sd.AccountKey)
+}
 
-	return CurrentPlayingTrackResponse{
-		Artist:    artist,
-		Song:      song,
-		ImageURL:  imageURL,
-		IsPlaying: isPlaying,
-		DeviceId:  deviceId,
-	}, nil
+type CurrentPlayingTrackResponse struct {
+	TrackURI          string
+	RequestedBy       string   // who requested the track through the DJ queue, if anyone did
+	Artist            string   // main artist
+	Artists           []string // every artist, the main one first
+	Song              string
+	Album             string
+	ImageURL          string
+	TrackURL          string // opens the track in Spotify
+	Explicit          bool
+	IsPlaying         bool
+	DeviceId          string
+	DeviceName        string
+	VolumePercent     *int // nil when the device does not report its volume
+	ShuffleState      bool
+	RepeatState       string // off, track or context
+	ProgressMs        int
+	DurationMs        int
+	Devices           []SpotifyDevice // where playback can move to, filled in for dashboards only
+	SkipVotes         int             // votes to skip the track so far, see SkipVotePolicy
+	SkipVotesRequired int             // votes it takes to skip the track, 0 when skipping isn't put to a vote
 }
 
 type UserQueueItem struct {
-	AlbumLogo string
-	SongTitle string
-	Artist    string
-	Duration  string
+	URI        string
+	AlbumLogo  string
+	SongTitle  string
+	Artist     string
+	Duration   string
+	DurationMs int
 }
 
-func GetUserQueue() ([]UserQueueItem, error) {
-	accessToken := Shared.GetSpotifyAccessToken()
-
-	// Make a GET request to Spotify API for the user's queue
-	url := "https://api.spotify.com/v1/me/player/queue"
-	req, err := http.NewRequest("GET", url, nil)
-	if err != nil {
-		return nil, fmt.Errorf("failed to create request: %w", err)
-	}
-
-	req.Header.Set("Authorization", "Bearer "+accessToken)
-
-	httpClient := &http.Client{}
-	resp, err := httpClient.Do(req)
-	if err != nil {
-		return nil, fmt.Errorf("failed to make request: %w", err)
-	}
-	defer resp.Body.Close()
+func BuildSpotifyAttachment(track CurrentPlayingTrackResponse, lastAction string, userName string) slack.Attachment {
 
-	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("unexpected response: %s", resp.Status)
+	// The DJ is whoever requested the playing track through the DJ queue, Spotify itself otherwise
+	dj := track.RequestedBy
+	if dj == "" {
+		dj = "🎲 Spotify"
 	}
 
-	var data map[string]interface{}
-	if err := json.NewDecoder(resp.Body).Decode(&data); err != nil {
-		return nil, fmt.Errorf("failed to decode response: %w", err)
-	}
+	// Create a section block for displaying last action and user
+	lastActionBlock := slack.NewSectionBlock(
+		slack.NewTextBlockObject(slack.MarkdownType, fmt.Sprintf("*Last Action:* %s by %s\n*DJ:* %s", lastAction, userName, dj), false, false),
+		nil,
+		nil,
+	)
 
-	// Check if the 'queue' key exists in the response and is an array
-	queue, exists := data["queue"].([]interface{})
-	if !exists {
-		return nil, fmt.Errorf("queue not found in response or not an array")
-	}
+	// Create a divider block
+	dividerBlock := slack.NewDividerBlock()
 
-	var userQueue []UserQueueItem
+	// Create the section block with artist, song and album details
+	songMetadataBlock := slack.NewSectionBlock(
+		slack.NewTextBlockObject(slack.MarkdownType, trackMetadataText(track), false, false),
+		nil,
+		nil,
+	)
 
-	// Iterate through the queue items and extract relevant information
-	for _, item := range queue {
-		itemData, ok := item.(map[string]interface{})
-		if !ok {
-			continue
-		}
+	// Elapsed and total time around a text progress bar
+	progressBlock := slack.NewSectionBlock(
+		slack.NewTextBlockObject(slack.MarkdownType, fmt.Sprintf("`%s` %s `%s`",
+			FormatDuration(track.ProgressMs),
+			progressBar(track.ProgressMs, track.DurationMs, progressBarWidth),
+			FormatDuration(track.DurationMs),
+		), false, false),
+		nil,
+		nil,
+	)
 
-		artist := itemData["artists"].([]interface{})[0].(map[string]interface{})["name"].(string)
-		song := itemData["name"].(string)
-		album := itemData["album"].(map[string]interface{})
-		imageURL := album["images"].([]interface{})[1].(map[string]interface{})["url"].(string)
-		durationMs := int(itemData["duration_ms"].(float64))
-		duration := fmt.Sprintf("%d:%02d", durationMs/60000, (durationMs/1000)%60)
-
-		queueItem := UserQueueItem{
-			AlbumLogo: imageURL,
-			SongTitle: song,
-			Artist:    artist,
-			Duration:  duration,
-		}
+	// Create buttons for controls
+	previousButton := slack.NewButtonBlockElement("skip_previous", "skip_previous", slack.NewTextBlockObject(slack.PlainTextType, "⏪", false, false))
 
-		userQueue = append(userQueue, queueItem)
+	// Create play/pause button based on track state
+	var playPauseButton *slack.ButtonBlockElement
+	if track.IsPlaying {
+		playPauseButton = slack.NewButtonBlockElement("pause", "pause", slack.NewTextBlockObject(slack.PlainTextType, "⏸️", false, false))
+	} else {
+		playPauseButton = slack.NewButtonBlockElement("play", "play", slack.NewTextBlockObject(slack.PlainTextType, "▶️", false, false))
 	}
 
-	return userQueue, nil
-}
-
-func PauseTrack() error {
-	accessToken := Shared.GetSpotifyAccessToken()
-
-	url := "https://api.spotify.com/v1/me/player/pause"
-	req, err := http.NewRequest("PUT", url, nil)
-	if err != nil {
-		return fmt.Errorf("failed to create request: %w", err)
+	// In democratic mode the skip button keeps the tally of votes
+	nextText := "⏩"
+	if track.SkipVotes > 0 && track.SkipVotesRequired > 0 {
+		nextText = fmt.Sprintf("⏩ %d/%d", track.SkipVotes, track.SkipVotesRequired)
 	}
+	nextButton := slack.NewButtonBlockElement("skip_next", "skip_next", slack.NewTextBlockObject(slack.PlainTextType, nextText, false, false))
 
-	req.Header.Set("Authorization", "Bearer "+accessToken)
+	searchButton := slack.NewButtonBlockElement("spotify_search", "spotify_search", slack.NewTextBlockObject(slack.PlainTextType, "🔎 Search", false, false))
 
-	httpClient := &http.Client{}
-	resp, err := httpClient.Do(req)
-	if err != nil {
-		return fmt.Errorf("failed to make request: %w", err)
+	controls := []slack.BlockElement{previousButton, playPauseButton, nextButton, searchButton}
+	if TeamPlaylist != "" && track.TrackURI != "" {
+		controls = append(controls, slack.NewButtonBlockElement(SaveToPlaylistActionID, SaveToPlaylistActionID, slack.NewTextBlockObject(slack.PlainTextType, "💾 Save to team playlist", false, false)))
 	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusNoContent {
-		return fmt.Errorf("unexpected response: %s", resp.Status)
+	if track.TrackURL != "" {
+		openButton := slack.NewButtonBlockElement("open_in_spotify", "open_in_spotify", slack.NewTextBlockObject(slack.PlainTextType, "Open in Spotify", false, false))
+		openButton.URL = track.TrackURL
+		controls = append(controls, openButton)
 	}
 
-	return nil
-}
-
-func StartResumeTrack() error {
-	accessToken := Shared.GetSpotifyAccessToken()
+	// Create an action block with buttons
+	actionBlock := slack.NewActionBlock("controls", controls...)
 
-	url := "https://api.spotify.com/v1/me/player/play"
-	req, err := http.NewRequest("PUT", url, nil)
-	if err != nil {
-		return fmt.Errorf("failed to create request: %w", err)
-	}
+	// Volume, shuffle, repeat and seek
+	playerControlsBlock := buildPlayerControls(track)
 
-	req.Header.Set("Authorization", "Bearer "+accessToken)
+	// Device, volume, shuffle and repeat in small print
+	playerContextBlock := slack.NewContextBlock("player",
+		slack.NewTextBlockObject(slack.MarkdownType, playerStateText(track), false, false),
+	)
 
-	httpClient := &http.Client{}
-	resp, err := httpClient.Do(req)
-	if err != nil {
-		return fmt.Errorf("failed to make request: %w", err)
+	var blocks []slack.Block
+	// Slack rejects image blocks without a URL, local files and some podcasts have no art
+	if track.ImageURL != "" {
+		blocks = append(blocks, slack.NewImageBlock(track.ImageURL, "Album Cover", "", nil))
 	}
-	defer resp.Body.Close()
+	blocks = append(blocks,
+		songMetadataBlock,
+		progressBlock,
+		actionBlock,
+		playerControlsBlock,
+	)
+	// Move playback to another device
+	if devicePicker := buildDevicePicker(track.Devices); devicePicker != nil {
+		blocks = append(blocks, devicePicker)
+	}
+	blocks = append(blocks,
+		playerContextBlock,
+		dividerBlock,
+		lastActionBlock,
+	)
 
-	if resp.StatusCode != http.StatusNoContent {
-		return fmt.Errorf("unexpected response: %s", resp.Status)
+	// Create the attachment
+	attachment := slack.Attachment{
+		Blocks: slack.Blocks{
+			BlockSet: blocks,
+		},
 	}
 
-	return nil
+	return attachment
 }
 
-func SkipToNextTrack() error {
-	accessToken := Shared.GetSpotifyAccessToken()
-
-	url := "https://api.spotify.com/v1/me/player/next"
-	req, err := http.NewRequest("POST", url, nil)
-	if err != nil {
-		return fmt.Errorf("failed to create request: %w", err)
-	}
+// progressBarWidth is the number of characters in the dashboard's progress bar
+const progressBarWidth = 20
 
-	req.Header.Set("Authorization", "Bearer "+accessToken)
+// progressStepMs is how far playback moves before the dashboard redraws its progress bar and elapsed time,
+// about one poll interval
+const progressStepMs = 10000
 
-	httpClient := &http.Client{}
-	resp, err := httpClient.Do(req)
-	if err != nil {
-		return fmt.Errorf("failed to make request: %w", err)
+// progressBar renders how far into the track playback is, e.g. ▓▓▓▓░░░░░░
+func progressBar(progressMs int, durationMs int, width int) string {
+	filled := 0
+	if durationMs > 0 {
+		filled = min(max(progressMs*width/durationMs, 0), width)
 	}
-	defer resp.Body.Close()
+	return strings.Repeat("▓", filled) + strings.Repeat("░", width-filled)
+}
 
-	if resp.StatusCode != http.StatusNoContent {
-		return fmt.Errorf("unexpected response: %s", resp.Status)
+// trackMetadataText lists artists, song and album, flagging explicit tracks
+func trackMetadataText(track CurrentPlayingTrackResponse) string {
+	artists := strings.Join(track.Artists, ", ")
+	if artists == "" {
+		artists = track.Artist
 	}
-
-	return nil
+	song := track.Song
+	if track.Explicit {
+		song += " 🅴"
+	}
+	text := fmt.Sprintf("*Artist:* %s\n*Song:* %s", artists, song)
+	if track.Album != "" {
+		text += fmt.Sprintf("\n*Album:* %s", track.Album)
+	}
+	return text
 }
 
-func SkipToPreviousTrack() error {
-	accessToken := Shared.GetSpotifyAccessToken()
-
-	url := "https://api.spotify.com/v1/me/player/previous"
-	req, err := http.NewRequest("POST", url, nil)
-	if err != nil {
-		return fmt.Errorf("failed to create request: %w", err)
+// playerStateText describes the device, its volume and the shuffle and repeat modes
+func playerStateText(track CurrentPlayingTrackResponse) string {
+	var parts []string
+	if track.DeviceName != "" {
+		device := "🔊 " + track.DeviceName
+		if track.VolumePercent != nil {
+			device += fmt.Sprintf(" (%d%%)", *track.VolumePercent)
+		}
+		parts = append(parts, device)
 	}
 
-	req.Header.Set("Authorization", "Bearer "+accessToken)
-
-	httpClient := &http.Client{}
-	resp, err := httpClient.Do(req)
-	if err != nil {
-		return fmt.Errorf("failed to make request: %w", err)
+	shuffle := "off"
+	if track.ShuffleState {
+		shuffle = "on"
 	}
-	defer resp.Body.Close()
+	parts = append(parts, "🔀 Shuffle "+shuffle)
 
-	if resp.StatusCode != http.StatusNoContent {
-		return fmt.Errorf("unexpected response: %s", resp.Status)
+	switch track.RepeatState {
+	case "track":
+		parts = append(parts, "🔂 Repeat track")
+	case "context":
+		parts = append(parts, "🔁 Repeat all")
+	default:
+		parts = append(parts, "🔁 Repeat off")
 	}
-
-	return nil
+	return strings.Join(parts, "  ·  ")
 }
 
-func BuildSpotifyAttachment(track CurrentPlayingTrackResponse, lastAction string, userName string) slack.Attachment {
-
-	// Create a section block for displaying last action and user
-	lastActionBlock := slack.NewSectionBlock(
-		slack.NewTextBlockObject(slack.MarkdownType, fmt.Sprintf("*Last Action:* %s\n*DJ:* %s", lastAction, userName), false, false),
+// BuildRetiredSpotifyAttachment shows the last known track of a dashboard without its controls
+func BuildRetiredSpotifyAttachment(track CurrentPlayingTrackResponse, reason string) slack.Attachment {
+	songMetadataBlock := slack.NewSectionBlock(
+		slack.NewTextBlockObject(slack.MarkdownType, trackMetadataText(track), false, false),
 		nil,
 		nil,
 	)
-
-	// Create a divider block
-	dividerBlock := slack.NewDividerBlock()
-
-	// Create the image block
-	albumImageBlock := slack.NewImageBlock(track.ImageURL, "Album Cover", "", nil)
-
-	// Create the text block with artist and song details
-	textBlock := slack.NewTextBlockObject(slack.MarkdownType, fmt.Sprintf("*Artist:* %s\n*Song:* %s", track.Artist, track.Song), false, false)
-
-	// Create the section block with the text and image blocks
-	songMetadataBlock := slack.NewSectionBlock(textBlock, nil, nil)
-
-	// Create buttons for controls
-	previousButton := slack.NewButtonBlockElement("skip_previous", "skip_previous", slack.NewTextBlockObject(slack.PlainTextType, "⏪", false, false))
-
-	// Create play/pause button based on track state
-	var playPauseButton *slack.ButtonBlockElement
-	if track.IsPlaying {
-		playPauseButton = slack.NewButtonBlockElement("pause", "pause", slack.NewTextBlockObject(slack.PlainTextType, "⏸️", false, false))
-	} else {
-		playPauseButton = slack.NewButtonBlockElement("play", "play", slack.NewTextBlockObject(slack.PlainTextType, "▶️", false, false))
-	}
-
-	nextButton := slack.NewButtonBlockElement("skip_next", "skip_next", slack.NewTextBlockObject(slack.PlainTextType, "⏩", false, false))
-
-	// Create an action block with buttons
-	actionBlock := slack.NewActionBlock(
-		"controls",
-		previousButton,
-		playPauseButton,
-		nextButton,
+	reasonBlock := slack.NewContextBlock("retired",
+		slack.NewTextBlockObject(slack.MarkdownType, reason, false, false),
 	)
 
-	// Create the attachment
-	attachment := slack.Attachment{
+	var blocks []slack.Block
+	if track.ImageURL != "" {
+		blocks = append(blocks, slack.NewImageBlock(track.ImageURL, "Album Cover", "", nil))
+	}
+	return slack.Attachment{
 		Blocks: slack.Blocks{
-			BlockSet: []slack.Block{
-				albumImageBlock,
-				songMetadataBlock,
-				actionBlock,
-				dividerBlock,
-				lastActionBlock,
-			},
+			BlockSet: append(blocks, songMetadataBlock, reasonBlock),
 		},
 	}
-
-	return attachment
 }
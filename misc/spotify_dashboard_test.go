@@ -0,0 +1,58 @@
+package misc
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestProgressBar(t *testing.T) {
+	tests := []struct {
+		progressMs, durationMs int
+		want                   string
+	}{
+		{0, 200000, "░░░░░░░░░░"},
+		{100000, 200000, "▓▓▓▓▓░░░░░"},
+		{250000, 200000, "▓▓▓▓▓▓▓▓▓▓"},
+		{1000, 0, "░░░░░░░░░░"},
+	}
+	for _, tt := range tests {
+		if got := progressBar(tt.progressMs, tt.durationMs, 10); got != tt.want {
+			t.Errorf("progressBar(%d, %d) = %s, want %s", tt.progressMs, tt.durationMs, got, tt.want)
+		}
+	}
+}
+
+func TestBuildSpotifyAttachment(t *testing.T) {
+	volume := 65
+	track := CurrentPlayingTrackResponse{
+		Artist:        "Daft Punk",
+		Artists:       []string{"Daft Punk", "Pharrell Williams"},
+		Song:          "Get Lucky",
+		Album:         "Random Access Memories",
+		TrackURL:      "https://open.spotify.com/track/2",
+		Explicit:      true,
+		IsPlaying:     true,
+		DeviceName:    "Office",
+		VolumePercent: &volume,
+		RepeatState:   "track",
+		ProgressMs:    65000,
+		DurationMs:    369000,
+	}
+
+	text := trackMetadataText(track)
+	for _, want := range []string{"Daft Punk, Pharrell Williams", "Get Lucky 🅴", "Random Access Memories"} {
+		if !strings.Contains(text, want) {
+			t.Errorf("metadata %q does not mention %q", text, want)
+		}
+	}
+	if got := playerStateText(track); got != "🔊 Office (65%)  ·  🔀 Shuffle off  ·  🔂 Repeat track" {
+		t.Errorf("player state = %q", got)
+	}
+
+	attachment := BuildSpotifyAttachment(track, "/spotify", "alice")
+	for _, block := range attachment.Blocks.BlockSet {
+		if block.BlockType() == "image" {
+			t.Error("a track without album art must not get an image block")
+		}
+	}
+}
@@ -0,0 +1,148 @@
+package misc
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/slack-go/slack"
+)
+
+// The device picker of the dashboard and the "Play here" buttons of /spotify-devices
+const (
+	DashboardDeviceActionID = "dashboard_device"
+	TransferDeviceActionID  = "transfer_device"
+)
+
+// FindDevice returns the device whose ID or name (ignoring case) is device
+func FindDevice(devices []SpotifyDevice, device string) (SpotifyDevice, bool) {
+	if device == "" {
+		return SpotifyDevice{}, false
+	}
+	for _, d := range devices {
+		if d.ID == device || strings.EqualFold(d.Name, device) {
+			return d, true
+		}
+	}
+	return SpotifyDevice{}, false
+}
+
+// GetDashboardTrack is GetCurrentPlayingTrack plus the devices playback can move to, or
+// GetCurrentPlayingTrackOrWake if wake is set. The dashboard goes without its device picker
+// when the devices can't be listed.
+func (c *SpotifyClient) GetDashboardTrack(ctx context.Context, wake bool) (CurrentPlayingTrackResponse, error) {
+	read := c.GetCurrentPlayingTrack
+	if wake {
+		read = c.GetCurrentPlayingTrackOrWake
+	}
+	cpt, err := read(ctx)
+	if err != nil {
+		return cpt, err
+	}
+	devices, err := c.GetDevices(ctx)
+	if err != nil {
+		log.Printf("failed to list spotify devices for the dashboard: %v", err)
+		return cpt, nil
+	}
+	cpt.Devices = devices
+	return cpt, nil
+}
+
+// deviceEmoji pictures the device type Spotify reports
+func deviceEmoji(deviceType string) string {
+	switch strings.ToLower(deviceType) {
+	case "computer":
+		return "💻"
+	case "smartphone", "tablet":
+		return "📱"
+	case "speaker", "avr", "stb", "audiodongle":
+		return "🔈"
+	case "tv", "castvideo":
+		return "📺"
+	}
+	return "🎧"
+}
+
+// deviceVolumeText returns the device's volume, or that Spotify can't tell it
+func deviceVolumeText(device SpotifyDevice) string {
+	if device.VolumePercent == nil {
+		return "volume n/a"
+	}
+	return fmt.Sprintf("%d%%", *device.VolumePercent)
+}
+
+// controllableDevices leaves out the devices Spotify won't take commands for
+func controllableDevices(devices []SpotifyDevice) []SpotifyDevice {
+	var controllable []SpotifyDevice
+	for _, device := range devices {
+		if !device.IsRestricted && device.ID != "" {
+			controllable = append(controllable, device)
+		}
+	}
+	return controllable
+}
+
+// buildDevicePicker renders a menu of the devices playback can move to, with the active one
+// selected. It returns nil when there is nowhere to move to.
+func buildDevicePicker(devices []SpotifyDevice) *slack.ActionBlock {
+	devices = controllableDevices(devices)
+	if len(devices) == 0 {
+		return nil
+	}
+
+	var options []*slack.OptionBlockObject
+	var active *slack.OptionBlockObject
+	for _, device := range devices {
+		text := fmt.Sprintf("%s %s · %s", deviceEmoji(device.Type), device.Name, deviceVolumeText(device))
+		if device.IsActive {
+			text = "● " + text
+		}
+		option := searchOption(device.ID, text)
+		if device.IsActive {
+			active = option
+		}
+		options = append(options, option)
+	}
+
+	picker := slack.NewOptionsSelectBlockElement(slack.OptTypeStatic,
+		slack.NewTextBlockObject(slack.PlainTextType, "Play on…", false, false),
+		DashboardDeviceActionID, options...)
+	picker.InitialOption = active
+	return slack.NewActionBlock("devices", picker)
+}
+
+// BuildDeviceList renders every device of the account for /spotify-devices: its type, volume,
+// whether it is active or the default device, and a button that moves playback to it
+func BuildDeviceList(devices []SpotifyDevice, defaultDevice string) []slack.Block {
+	headerText := slack.NewTextBlockObject(slack.MarkdownType, "*🔈 Your Spotify devices*", false, false)
+	blocks := []slack.Block{slack.NewSectionBlock(headerText, nil, nil)}
+	if len(devices) == 0 {
+		emptyText := slack.NewTextBlockObject(slack.MarkdownType, "No devices are available. Open Spotify on a computer, phone or speaker and try again!", false, false)
+		return append(blocks, slack.NewSectionBlock(emptyText, nil, nil, slack.SectionBlockOptionBlockID("no_devices")))
+	}
+
+	fallback, hasFallback := FindDevice(devices, defaultDevice)
+	for i, device := range devices {
+		details := []string{device.Type, deviceVolumeText(device)}
+		if device.IsActive {
+			details = append(details, "▶️ active")
+		}
+		if hasFallback && device.ID == fallback.ID {
+			details = append(details, "⭐ default")
+		}
+		if device.IsRestricted {
+			details = append(details, "🔒 can't be controlled")
+		}
+		deviceText := slack.NewTextBlockObject(slack.MarkdownType,
+			fmt.Sprintf("%s *%s*\n%s", deviceEmoji(device.Type), device.Name, strings.Join(details, " · ")), false, false)
+
+		var accessory *slack.Accessory
+		if !device.IsActive && !device.IsRestricted && device.ID != "" {
+			button := slack.NewButtonBlockElement(TransferDeviceActionID, device.ID, slack.NewTextBlockObject(slack.PlainTextType, "Play here", false, false))
+			accessory = slack.NewAccessory(button)
+		}
+		blocks = append(blocks, slack.NewSectionBlock(deviceText, nil, accessory, slack.SectionBlockOptionBlockID(fmt.Sprintf("device_%d", i))))
+	}
+	return blocks
+}
@@ -0,0 +1,60 @@
+package misc
+
+import (
+	"testing"
+
+	"github.com/slack-go/slack"
+)
+
+func TestBuildDevicePicker(t *testing.T) {
+	volume := 40
+	devices := []SpotifyDevice{
+		{ID: "laptop", Name: "Laptop", Type: "Computer", IsActive: true, VolumePercent: &volume},
+		{ID: "speaker", Name: "Office Speaker", Type: "Speaker"},
+		{ID: "car", Name: "Car", Type: "Automobile", IsRestricted: true},
+	}
+
+	picker := buildDevicePicker(devices)
+	if picker == nil {
+		t.Fatal("expected a device picker")
+	}
+	menu := picker.Elements.ElementSet[0].(*slack.SelectBlockElement)
+	if menu.ActionID != DashboardDeviceActionID || len(menu.Options) != 2 {
+		t.Fatalf("picker = %s with %d options, want the 2 controllable devices", menu.ActionID, len(menu.Options))
+	}
+	if menu.InitialOption == nil || menu.InitialOption.Value != "laptop" {
+		t.Errorf("initial option = %+v, want the active device", menu.InitialOption)
+	}
+	if got := menu.Options[0].Text.Text; got != "● 💻 Laptop · 40%" {
+		t.Errorf("option text = %q", got)
+	}
+
+	if buildDevicePicker(devices[2:]) != nil {
+		t.Error("expected no picker without controllable devices")
+	}
+}
+
+func TestBuildDeviceList(t *testing.T) {
+	devices := []SpotifyDevice{
+		{ID: "laptop", Name: "Laptop", Type: "Computer", IsActive: true},
+		{ID: "speaker", Name: "Office Speaker", Type: "Speaker"},
+	}
+	blocks := BuildDeviceList(devices, "speaker")
+	if len(blocks) != 3 {
+		t.Fatalf("got %d blocks, want a header and 2 devices", len(blocks))
+	}
+	active, idle := blocks[1].(*slack.SectionBlock), blocks[2].(*slack.SectionBlock)
+	if active.Accessory != nil {
+		t.Error("the active device should have no Play here button")
+	}
+	if idle.Accessory == nil || idle.Accessory.ButtonElement.Value != "speaker" {
+		t.Errorf("idle device accessory = %+v, want a Play here button", idle.Accessory)
+	}
+	if want := "🔈 *Office Speaker*\nSpeaker · volume n/a · ⭐ default"; idle.Text.Text != want {
+		t.Errorf("idle device text = %q, want %q", idle.Text.Text, want)
+	}
+
+	if blocks := BuildDeviceList(nil, ""); len(blocks) != 2 {
+		t.Errorf("got %d blocks for no devices, want a header and a hint", len(blocks))
+	}
+}
@@ -1,35 +1,140 @@
 package misc
 
 import (
+	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"net/http"
 	"strconv"
+	"time"
 )
 
+// Spotify states the handlers tell the user about instead of treating them as failures
+var (
+	// ErrNotAuthenticated means the account never ran /spotify-auth or its login can no longer be refreshed
+	ErrNotAuthenticated = errors.New("not connected to Spotify")
+	// ErrNoActiveDevice means Spotify is not open on any of the account's devices
+	ErrNoActiveDevice = errors.New("no active device found")
+	// ErrNothingPlaying means a device is active but no track is loaded
+	ErrNothingPlaying = errors.New("no currently playing track")
+	// ErrPremiumRequired means Spotify refused a playback command because the account is not Premium
+	ErrPremiumRequired = errors.New("Spotify Premium required")
+)
+
+// RateLimitError is returned when Spotify answers 429 Too Many Requests
+type RateLimitError struct {
+	RetryAfter time.Duration
+}
+
+func (e *RateLimitError) Error() string {
+	return fmt.Sprintf("rate limited by Spotify, retry after %s", e.RetryAfter)
+}
+
+// spotifyErrorKind classifies the Spotify errors above, ok is false for anything else
+func spotifyErrorKind(err error) (kind ErrorKind, message string, ok bool) {
+	var rateLimited *RateLimitError
+	switch {
+	case errors.Is(err, ErrNotAuthenticated):
+		return ErrorKindUser, "Not connected to Spotify. Run /spotify-auth to enable this!", true
+	case errors.Is(err, ErrNoActiveDevice):
+		return ErrorKindUser, "No active device is currently playing anything!", true
+	case errors.Is(err, ErrNothingPlaying):
+		return ErrorKindUser, "No track is currently playing.", true
+	case errors.Is(err, ErrPremiumRequired):
+		return ErrorKindUser, "Spotify only lets Premium accounts control playback.", true
+	case errors.As(err, &rateLimited):
+		wait := rateLimited.RetryAfter.Round(time.Second)
+		if wait < time.Second {
+			wait = time.Second
+		}
+		return ErrorKindUpstream, fmt.Sprintf("Spotify asked us to slow down, please try again in %s", wait), true
+	}
+	return ErrorKindInternal, "", false
+}
+
+// parseRetryAfter reads a Retry-After header given in seconds, defaulting to one second
+func parseRetryAfter(header http.Header) time.Duration {
+	seconds, err := strconv.Atoi(header.Get("Retry-After"))
+	if err != nil || seconds < 1 {
+		return time.Second
+	}
+	return time.Duration(seconds) * time.Second
+}
+
+// spotifyAPIError is a non-2xx response from the Spotify Web API. It unwraps to the
+// sentinel or RateLimitError the response stands for, so handlers only use errors.Is/As.
 type spotifyAPIError struct {
 	StatusCode int
-	Response   *http.Response
+	Status     string
+	Body       []byte
+	RetryAfter time.Duration // only set for 429 Too Many Requests
+	Reason     string        // e.g. PREMIUM_REQUIRED, from player endpoints
+	Message    string
+}
+
+// newSpotifyAPIError reads the response body, which Spotify fills with an error object
+func newSpotifyAPIError(resp *http.Response) *spotifyAPIError {
+	apiErr := &spotifyAPIError{
+		StatusCode: resp.StatusCode,
+		Status:     resp.Status,
+	}
+	apiErr.Body, _ = io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+
+	var body struct {
+		Error struct {
+			Message string `json:"message"`
+			Reason  string `json:"reason"`
+		} `json:"error"`
+	}
+	if json.Unmarshal(apiErr.Body, &body) == nil {
+		apiErr.Reason = body.Error.Reason
+		apiErr.Message = body.Error.Message
+	}
+	if resp.StatusCode == http.StatusTooManyRequests {
+		apiErr.RetryAfter = parseRetryAfter(resp.Header)
+	}
+	return apiErr
 }
 
 func (e *spotifyAPIError) Error() string {
-	return fmt.Sprintf("Spotify API error: %d %s", e.StatusCode, e.Response.Status)
+	if e.Message == "" {
+		return fmt.Sprintf("Spotify API error: %s", e.Status)
+	}
+	return fmt.Sprintf("Spotify API error: %s: %s", e.Status, e.Message)
 }
 
-func isRateLimitError(err error) bool {
-	if respErr, ok := err.(*spotifyAPIError); ok && respErr.StatusCode == 429 {
-		return true
+// Unwrap returns the typed error the response stands for, nil when it is none of them
+func (e *spotifyAPIError) Unwrap() error {
+	switch {
+	case e.StatusCode == http.StatusTooManyRequests:
+		return &RateLimitError{RetryAfter: e.RetryAfter}
+	case e.StatusCode == http.StatusUnauthorized:
+		return ErrNotAuthenticated
+	case e.Reason == "PREMIUM_REQUIRED":
+		return ErrPremiumRequired
+	case e.Reason == "NO_ACTIVE_DEVICE":
+		return ErrNoActiveDevice
 	}
-	return false
+	return nil
 }
 
-func getRetryAfterValue(err error) (int, error) {
-	if respErr, ok := err.(*spotifyAPIError); ok {
-		retryAfterStr := respErr.Response.Header.Get("Retry-After")
-		retryAfter, err := strconv.Atoi(retryAfterStr)
-		if err != nil {
-			return 0, err
-		}
-		return retryAfter, nil
+// temporary reports whether the same request may succeed when sent again later
+func (e *spotifyAPIError) temporary() bool {
+	return e.StatusCode == http.StatusTooManyRequests || e.StatusCode >= 500
+}
+
+// isRateLimitError reports whether err comes from Spotify answering 429 Too Many Requests
+func isRateLimitError(err error) bool {
+	var rateLimited *RateLimitError
+	return errors.As(err, &rateLimited)
+}
+
+// getRetryAfterValue returns how long Spotify asked us to wait before the next request
+func getRetryAfterValue(err error) (time.Duration, error) {
+	var rateLimited *RateLimitError
+	if errors.As(err, &rateLimited) {
+		return rateLimited.RetryAfter, nil
 	}
-	return 0, fmt.Errorf("error is not a Spotify API error")
+	return 0, fmt.Errorf("error is not a Spotify rate limit error")
 }
@@ -0,0 +1,123 @@
+package misc
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestSpotifyClientTypedErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		status int
+		header string
+		body   string
+		check  func(err error) bool
+		kind   ErrorKind
+	}{
+		{"premium", http.StatusForbidden, "", `{"error":{"status":403,"message":"Premium required","reason":"PREMIUM_REQUIRED"}}`,
+			func(err error) bool { return errors.Is(err, ErrPremiumRequired) }, ErrorKindUser},
+		{"no device", http.StatusNotFound, "", `{"error":{"status":404,"reason":"NO_ACTIVE_DEVICE"}}`,
+			func(err error) bool { return errors.Is(err, ErrNoActiveDevice) }, ErrorKindUser},
+		{"unauthorized", http.StatusUnauthorized, "", `{"error":{"status":401}}`,
+			func(err error) bool { return errors.Is(err, ErrNotAuthenticated) }, ErrorKindUser},
+		{"rate limited", http.StatusTooManyRequests, "7", ``,
+			func(err error) bool {
+				var rateLimited *RateLimitError
+				return errors.As(err, &rateLimited) && rateLimited.RetryAfter == 7*time.Second
+			}, ErrorKindUpstream},
+		{"server error", http.StatusBadGateway, "", `{"error":{"status":502,"message":"Bad gateway"}}`,
+			func(err error) bool { return err != nil }, ErrorKindUpstream},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := newTestSpotifyClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if tt.header != "" {
+					w.Header().Set("Retry-After", tt.header)
+				}
+				w.WriteHeader(tt.status)
+				w.Write([]byte(tt.body))
+			}))
+
+			err := client.SkipToNextTrack(context.Background())
+			if !tt.check(err) {
+				t.Errorf("SkipToNextTrack error = %v", err)
+			}
+			if kind := KindOf(err); kind != tt.kind {
+				t.Errorf("KindOf(%v) = %s, want %s", err, kind, tt.kind)
+			}
+		})
+	}
+}
+
+func TestGetCurrentPlayingTrackKeepsCause(t *testing.T) {
+	client := newTestSpotifyClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+
+	_, err := client.GetCurrentPlayingTrack(context.Background())
+	if !errors.Is(err, ErrNoActiveDevice) {
+		t.Fatalf("GetCurrentPlayingTrack error = %v, want ErrNoActiveDevice", err)
+	}
+	if attachment := BuildErrorAttachment(err); attachment.Text != "No active device is currently playing anything!" {
+		t.Errorf("rendered %q", attachment.Text)
+	}
+}
+
+func TestSpotifyClientRetriesGets(t *testing.T) {
+	calls := 0
+	client := newTestSpotifyClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		if calls == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte(`{"devices":[{"id":"speaker","is_active":true}]}`))
+	}))
+	client.retry.baseDelay = time.Millisecond
+
+	if _, err := client.GetDevices(context.Background()); err != nil {
+		t.Fatalf("GetDevices returned error: %v", err)
+	}
+	if calls != 2 {
+		t.Errorf("server saw %d calls, want 2", calls)
+	}
+
+	calls = 0
+	client = newTestSpotifyClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	if err := client.SkipToNextTrack(context.Background()); err == nil || calls != 1 {
+		t.Errorf("SkipToNextTrack: err=%v after %d calls, want one failed call", err, calls)
+	}
+}
+
+func TestSpotifyClientSharesRateLimitBackoff(t *testing.T) {
+	calls := 0
+	client := newTestSpotifyClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.Header().Set("Retry-After", "30")
+		w.WriteHeader(http.StatusTooManyRequests)
+	}))
+	if err := client.PauseTrack(context.Background()); !isRateLimitError(err) {
+		t.Fatalf("PauseTrack error = %v, want a rate limit error", err)
+	}
+
+	// Another account's client must not hit Spotify until the backoff is over
+	other := client.WithTokenSource(TokenSourceFunc(func(ctx context.Context) (string, error) {
+		return "other-token", nil
+	}))
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	err := other.StartResumeTrack(ctx)
+	retryAfter, parseErr := getRetryAfterValue(err)
+	if parseErr != nil || retryAfter <= 20*time.Second {
+		t.Errorf("StartResumeTrack error = %v, want a rate limit error with the remaining backoff", err)
+	}
+	if calls != 1 {
+		t.Errorf("server saw %d calls, want 1", calls)
+	}
+}
@@ -0,0 +1,68 @@
+package misc
+
+import (
+	"context"
+	"math/rand"
+	"sync"
+	"time"
+)
+
+// spotifyLimiter holds back every request of the clients sharing it while Spotify
+// has asked us to slow down. Spotify rate limits the whole app, not single users,
+// so SpotifyForAccount clients share the limiter of the Spotify client.
+type spotifyLimiter struct {
+	until time.Time
+	mutex sync.Mutex
+}
+
+// Backoff blocks requests for d, unless an earlier 429 already blocks them for longer
+func (l *spotifyLimiter) Backoff(d time.Duration) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	if until := time.Now().Add(d); until.After(l.until) {
+		l.until = until
+	}
+}
+
+// Wait blocks until requests are allowed again. When ctx would expire before that,
+// it returns a RateLimitError right away instead of waiting for nothing.
+func (l *spotifyLimiter) Wait(ctx context.Context) error {
+	l.mutex.Lock()
+	remaining := time.Until(l.until)
+	l.mutex.Unlock()
+	if remaining <= 0 {
+		return nil
+	}
+
+	if deadline, ok := ctx.Deadline(); ok && deadline.Before(time.Now().Add(remaining)) {
+		return &RateLimitError{RetryAfter: remaining}
+	}
+	return sleepContext(ctx, remaining)
+}
+
+// spotifyRetry decides how often and how long apart idempotent requests are retried
+type spotifyRetry struct {
+	attempts  int
+	baseDelay time.Duration
+}
+
+var defaultSpotifyRetry = spotifyRetry{attempts: 3, baseDelay: 250 * time.Millisecond}
+
+// delay returns the pause before retry number attempt (0 based): exponential backoff
+// with half of it jittered, so dashboards polling in lockstep spread out
+func (r spotifyRetry) delay(attempt int) time.Duration {
+	d := r.baseDelay << attempt
+	return d/2 + time.Duration(rand.Int63n(int64(d/2)+1))
+}
+
+// sleepContext waits for d or until ctx is done, whichever comes first
+func sleepContext(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,111 @@
+package misc
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/slack-go/slack"
+)
+
+// VolumeStep is how much the dashboard's volume buttons turn the volume up or down
+const VolumeStep = 10
+
+// defaultUnmuteVolume is what unmuting restores when we don't know the volume from before muting
+const defaultUnmuteVolume = 50
+
+// seekStepMs is how far the seek menu jumps back or forward
+const seekStepMs = 15000
+
+// NextRepeatState cycles the repeat mode: off, then the whole context, then the track
+func NextRepeatState(state string) string {
+	switch state {
+	case "off":
+		return "context"
+	case "context":
+		return "track"
+	}
+	return "off"
+}
+
+// SeekPosition returns where a seek menu option moves playback to: "start", "back",
+// "forward" or a percentage of the track such as "50"
+func SeekPosition(option string, progressMs int, durationMs int) (int, error) {
+	switch option {
+	case "start":
+		return 0, nil
+	case "back":
+		return max(progressMs-seekStepMs, 0), nil
+	case "forward":
+		return min(progressMs+seekStepMs, durationMs), nil
+	}
+	percent, err := strconv.Atoi(option)
+	if err != nil || percent < 0 || percent > 100 {
+		return 0, fmt.Errorf("unknown seek option: %s", option)
+	}
+	return durationMs * percent / 100, nil
+}
+
+// buildPlayerControls renders volume, mute, shuffle, repeat and seek controls for the track's player
+func buildPlayerControls(track CurrentPlayingTrackResponse) *slack.ActionBlock {
+	var controls []slack.BlockElement
+
+	// Some devices, e.g. phones, don't let Spotify Connect change their volume
+	if track.VolumePercent != nil {
+		controls = append(controls,
+			slack.NewButtonBlockElement("volume_down", "volume_down", slack.NewTextBlockObject(slack.PlainTextType, "🔉", false, false)),
+			slack.NewButtonBlockElement("volume_up", "volume_up", slack.NewTextBlockObject(slack.PlainTextType, "🔊", false, false)),
+		)
+		if *track.VolumePercent == 0 {
+			controls = append(controls, slack.NewButtonBlockElement("unmute", "unmute", slack.NewTextBlockObject(slack.PlainTextType, "🔈 Unmute", false, false)))
+		} else {
+			controls = append(controls, slack.NewButtonBlockElement("mute", "mute", slack.NewTextBlockObject(slack.PlainTextType, "🔇 Mute", false, false)))
+		}
+	}
+
+	shuffleButton := slack.NewButtonBlockElement("shuffle", "shuffle", slack.NewTextBlockObject(slack.PlainTextType, "🔀 Shuffle", false, false))
+	if track.ShuffleState {
+		shuffleButton.Style = slack.StylePrimary
+	}
+
+	repeatText := "🔁 Repeat"
+	if track.RepeatState == "track" {
+		repeatText = "🔂 Repeat"
+	}
+	repeatButton := slack.NewButtonBlockElement("repeat", "repeat", slack.NewTextBlockObject(slack.PlainTextType, repeatText, false, false))
+	if track.RepeatState == "context" || track.RepeatState == "track" {
+		repeatButton.Style = slack.StylePrimary
+	}
+	controls = append(controls, shuffleButton, repeatButton)
+
+	if track.DurationMs > 0 {
+		var options []*slack.OptionBlockObject
+		for _, option := range []struct{ value, text string }{
+			{"start", "⏮ Back to the start"},
+			{"back", fmt.Sprintf("⏪ Back %ds", seekStepMs/1000)},
+			{"forward", fmt.Sprintf("⏩ Forward %ds", seekStepMs/1000)},
+			{"25", "Jump to " + FormatDuration(track.DurationMs/4)},
+			{"50", "Jump to " + FormatDuration(track.DurationMs/2)},
+			{"75", "Jump to " + FormatDuration(track.DurationMs*3/4)},
+		} {
+			options = append(options, slack.NewOptionBlockObject(option.value, slack.NewTextBlockObject(slack.PlainTextType, option.text, false, false), nil))
+		}
+		controls = append(controls, slack.NewOverflowBlockElement("seek", options...))
+	}
+
+	return slack.NewActionBlock("player_controls", controls...)
+}
+
+// playerStateKey sums up what the player controls and the device picker show,
+// so the dashboard notices when they change
+func playerStateKey(track CurrentPlayingTrackResponse) string {
+	volume := "-"
+	if track.VolumePercent != nil {
+		volume = strconv.Itoa(*track.VolumePercent)
+	}
+	state := []string{track.DeviceId, volume, strconv.FormatBool(track.ShuffleState), track.RepeatState}
+	for _, device := range controllableDevices(track.Devices) {
+		state = append(state, device.ID+"="+device.Name)
+	}
+	return strings.Join(state, "|")
+}
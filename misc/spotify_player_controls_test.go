@@ -0,0 +1,72 @@
+package misc
+
+import (
+	"testing"
+
+	"github.com/slack-go/slack"
+)
+
+func TestNextRepeatState(t *testing.T) {
+	state := "off"
+	var cycle []string
+	for i := 0; i < 3; i++ {
+		state = NextRepeatState(state)
+		cycle = append(cycle, state)
+	}
+	if cycle[0] != "context" || cycle[1] != "track" || cycle[2] != "off" {
+		t.Errorf("repeat cycle = %v", cycle)
+	}
+}
+
+func TestSeekPosition(t *testing.T) {
+	tests := []struct {
+		option string
+		want   int
+	}{
+		{"start", 0},
+		{"back", 0},
+		{"forward", 25000},
+		{"50", 100000},
+	}
+	for _, tt := range tests {
+		if got, err := SeekPosition(tt.option, 10000, 200000); err != nil || got != tt.want {
+			t.Errorf("SeekPosition(%q) = %d, %v, want %d", tt.option, got, err, tt.want)
+		}
+	}
+	if _, err := SeekPosition("150", 0, 200000); err == nil {
+		t.Error("expected an error for a position past the end")
+	}
+}
+
+func TestBuildPlayerControls(t *testing.T) {
+	actionIDs := func(block *slack.ActionBlock) []string {
+		var ids []string
+		for _, element := range block.Elements.ElementSet {
+			switch e := element.(type) {
+			case *slack.ButtonBlockElement:
+				ids = append(ids, e.ActionID)
+			case *slack.OverflowBlockElement:
+				ids = append(ids, e.ActionID)
+			}
+		}
+		return ids
+	}
+
+	muted := 0
+	got := actionIDs(buildPlayerControls(CurrentPlayingTrackResponse{VolumePercent: &muted, DurationMs: 200000}))
+	want := []string{"volume_down", "volume_up", "unmute", "shuffle", "repeat", "seek"}
+	if len(got) != len(want) {
+		t.Fatalf("controls = %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("controls = %v, want %v", got, want)
+		}
+	}
+
+	// No volume control and nothing to seek in
+	got = actionIDs(buildPlayerControls(CurrentPlayingTrackResponse{}))
+	if len(got) != 2 || got[0] != "shuffle" || got[1] != "repeat" {
+		t.Errorf("controls = %v, want [shuffle repeat]", got)
+	}
+}
@@ -0,0 +1,104 @@
+package misc
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/slack-go/slack"
+)
+
+const (
+	// SaveToPlaylistActionID is the dashboard button that saves the playing track to the team playlist
+	SaveToPlaylistActionID = "save_to_playlist"
+	// PlaylistPlayActionID is the "Play" button of a playlist listed by /playlist list
+	PlaylistPlayActionID = "playlist_play"
+)
+
+// playlistListSize is how many playlists /playlist list shows
+const playlistListSize = 20
+
+// TeamPlaylist is the ID of the shared playlist the dashboards save tracks to, empty if there is none.
+// main sets it from the configuration.
+var TeamPlaylist string
+
+// PlaylistID reads a playlist ID from a spotify:playlist: URI, an open.spotify.com link or the ID itself
+func PlaylistID(playlist string) string {
+	playlist = strings.TrimSpace(playlist)
+	if id, ok := strings.CutPrefix(playlist, "spotify:playlist:"); ok {
+		return id
+	}
+	if link, err := url.Parse(playlist); err == nil && link.Host != "" {
+		if id, ok := strings.CutPrefix(link.Path, "/playlist/"); ok {
+			return id
+		}
+	}
+	return playlist
+}
+
+// FindPlaylist picks the playlist called name, ignoring case, or else the first whose name contains it
+func FindPlaylist(playlists []SpotifyPlaylist, name string) (SpotifyPlaylist, bool) {
+	for _, playlist := range playlists {
+		if strings.EqualFold(playlist.Name, name) {
+			return playlist, true
+		}
+	}
+	for _, playlist := range playlists {
+		if strings.Contains(strings.ToLower(playlist.Name), strings.ToLower(name)) {
+			return playlist, true
+		}
+	}
+	return SpotifyPlaylist{}, false
+}
+
+// BuildPlaylistList lists the playlists, each with a button that plays it
+func BuildPlaylistList(playlists []SpotifyPlaylist) ([]slack.Block, error) {
+	headerText := slack.NewTextBlockObject(slack.MarkdownType, "*📜 Your Spotify playlists*", false, false)
+	blocks := []slack.Block{slack.NewSectionBlock(headerText, nil, nil)}
+	if len(playlists) == 0 {
+		emptyText := slack.NewTextBlockObject(slack.MarkdownType, "You have no playlists yet. Make one from the music of this channel with /playlist create!", false, false)
+		return append(blocks, slack.NewSectionBlock(emptyText, nil, nil, slack.SectionBlockOptionBlockID("no_playlists"))), nil
+	}
+
+	for i, playlist := range playlists[:min(len(playlists), playlistListSize)] {
+		details := fmt.Sprintf("%d tracks", playlist.Tracks.Total)
+		if playlist.Owner.DisplayName != "" {
+			details += " · by " + playlist.Owner.DisplayName
+		}
+		playlistText := slack.NewTextBlockObject(slack.MarkdownType, fmt.Sprintf("*%s*\n%s", playlist.Name, details), false, false)
+
+		value, err := json.Marshal(TrackChoice{URI: playlist.URI, Name: playlist.Name})
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode playlist choice: %w", err)
+		}
+		button := slack.NewButtonBlockElement(PlaylistPlayActionID, string(value), slack.NewTextBlockObject(slack.PlainTextType, "▶️ Play", false, false))
+		blocks = append(blocks, slack.NewSectionBlock(playlistText, nil, slack.NewAccessory(button), slack.SectionBlockOptionBlockID(fmt.Sprintf("playlist_%d", i))))
+	}
+	if len(playlists) > playlistListSize {
+		moreText := slack.NewTextBlockObject(slack.MarkdownType, fmt.Sprintf("…and %d more, play any of them with /playlist play <name>", len(playlists)-playlistListSize), false, false)
+		blocks = append(blocks, slack.NewContextBlock("more_playlists", moreText))
+	}
+	return blocks, nil
+}
+
+// ChannelTracks returns the last n distinct tracks the dashboards of the channel saw playing,
+// oldest first as they were played
+func ChannelTracks(channelId string, n int) ([]PlayRecord, error) {
+	seen := map[string]bool{}
+	latest, err := PlayHistory.Latest(n, func(record PlayRecord) bool {
+		if record.ChannelID != channelId || seen[record.TrackURI] {
+			return false
+		}
+		seen[record.TrackURI] = true
+		return true
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the play history: %w", err)
+	}
+	tracks := make([]PlayRecord, len(latest))
+	for i, record := range latest {
+		tracks[len(latest)-1-i] = record
+	}
+	return tracks, nil
+}
@@ -0,0 +1,179 @@
+package misc
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/slack-go/slack"
+)
+
+func TestPlaylistID(t *testing.T) {
+	for _, playlist := range []string{
+		"37i9dQZF1DXcBWIGoYBM5M",
+		"spotify:playlist:37i9dQZF1DXcBWIGoYBM5M",
+		"https://open.spotify.com/playlist/37i9dQZF1DXcBWIGoYBM5M?si=abc",
+	} {
+		if got := PlaylistID(playlist); got != "37i9dQZF1DXcBWIGoYBM5M" {
+			t.Errorf("PlaylistID(%q) = %q", playlist, got)
+		}
+	}
+}
+
+func TestFindPlaylist(t *testing.T) {
+	playlists := []SpotifyPlaylist{{Name: "Friday Disco Classics"}, {Name: "Disco"}, {Name: "Focus"}}
+	tests := []struct {
+		name  string
+		want  string
+		found bool
+	}{
+		{"disco", "Disco", true},
+		{"friday", "Friday Disco Classics", true},
+		{"metal", "", false},
+	}
+	for _, tt := range tests {
+		got, found := FindPlaylist(playlists, tt.name)
+		if got.Name != tt.want || found != tt.found {
+			t.Errorf("FindPlaylist(%q) = %q, %v, want %q, %v", tt.name, got.Name, found, tt.want, tt.found)
+		}
+	}
+}
+
+func TestSpotifyClientPlaylists(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/v1/me/playlists", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("offset") == "0" {
+			w.Write([]byte(`{"items":[{"id":"1","name":"Disco","tracks":{"total":12}},null],"next":"more"}`))
+			return
+		}
+		w.Write([]byte(`{"items":[{"id":"2","name":"Focus"}],"next":null}`))
+	})
+	mux.HandleFunc("/v1/me", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"id":"office"}`))
+	})
+	var requests []string
+	mux.HandleFunc("/v1/users/office/playlists", func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		requests = append(requests, string(body))
+		w.Write([]byte(`{"id":"3","name":"#music","external_urls":{"spotify":"https://open.spotify.com/playlist/3"}}`))
+	})
+	mux.HandleFunc("/v1/playlists/3/tracks", func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		requests = append(requests, string(body))
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte(`{"snapshot_id":"s"}`))
+	})
+	client := newTestSpotifyClient(t, mux)
+
+	playlists, err := client.GetPlaylists(context.Background())
+	if err != nil {
+		t.Fatalf("GetPlaylists returned error: %v", err)
+	}
+	if len(playlists) != 2 || playlists[0].Tracks.Total != 12 || playlists[1].Name != "Focus" {
+		t.Errorf("playlists = %+v, want both pages without the null", playlists)
+	}
+
+	playlist, err := client.CreatePlaylist(context.Background(), "#music", "The last tracks")
+	if err != nil {
+		t.Fatalf("CreatePlaylist returned error: %v", err)
+	}
+	if err := client.AddToPlaylist(context.Background(), playlist.ID, []string{"spotify:track:1", "spotify:track:2"}); err != nil {
+		t.Fatalf("AddToPlaylist returned error: %v", err)
+	}
+	want := []string{`{"description":"The last tracks","name":"#music","public":true}`, `{"uris":["spotify:track:1","spotify:track:2"]}`}
+	if !reflect.DeepEqual(requests, want) {
+		t.Errorf("requests = %q, want %q", requests, want)
+	}
+
+	// Spotify takes 100 items per request
+	requests = nil
+	uris := make([]string, 150)
+	for i := range uris {
+		uris[i] = fmt.Sprintf("spotify:track:%d", i)
+	}
+	if err := client.AddToPlaylist(context.Background(), playlist.ID, uris); err != nil {
+		t.Fatalf("AddToPlaylist returned error: %v", err)
+	}
+	if len(requests) != 2 || strings.Count(requests[0], "spotify:track:") != 100 || strings.Count(requests[1], "spotify:track:") != 50 {
+		t.Errorf("150 items were added in %d requests, want batches of 100 and 50", len(requests))
+	}
+}
+
+func TestChannelTracks(t *testing.T) {
+	previous := PlayHistory
+	PlayHistory = NewPlayHistoryLog(NewMemoryPlayHistoryStore(0, 0))
+	t.Cleanup(func() { PlayHistory = previous })
+
+	// The shared account also played in C2, and nothing shows it in C1 any more
+	for i, play := range []struct{ channel, uri string }{
+		{"C1", "a"}, {"C2", "x"}, {"C1", "b"}, {"C1", "a"}, {"C2", "y"}, {"C1", "c"},
+	} {
+		PlayHistory.store.Append(PlayRecord{At: time.Now().Add(time.Duration(i) * time.Second), ChannelID: play.channel, AccountKey: "office", TrackURI: "spotify:track:" + play.uri})
+	}
+
+	tracks, err := ChannelTracks("C1", 3)
+	if err != nil {
+		t.Fatalf("ChannelTracks returned error: %v", err)
+	}
+	var uris []string
+	for _, track := range tracks {
+		uris = append(uris, strings.TrimPrefix(track.TrackURI, "spotify:track:"))
+	}
+	if got := fmt.Sprint(uris); got != "[b a c]" {
+		t.Errorf("ChannelTracks = %s, want the last three distinct tracks of C1, oldest first", got)
+	}
+	if tracks, _ := ChannelTracks("C3", 3); len(tracks) != 0 {
+		t.Errorf("a channel where nothing played has tracks %+v", tracks)
+	}
+}
+
+func TestDashboardSaveToPlaylistButton(t *testing.T) {
+	previous := TeamPlaylist
+	t.Cleanup(func() { TeamPlaylist = previous })
+	track := CurrentPlayingTrackResponse{TrackURI: "spotify:track:1", Song: "Dancing Queen", Artist: "ABBA"}
+
+	for _, playlist := range []string{"", "team"} {
+		TeamPlaylist = playlist
+		attachment := BuildSpotifyAttachment(track, "/spotify", "alice")
+		found := false
+		for _, block := range attachment.Blocks.BlockSet {
+			if actions, ok := block.(*slack.ActionBlock); ok {
+				for _, element := range actions.Elements.ElementSet {
+					if button, ok := element.(*slack.ButtonBlockElement); ok && button.ActionID == SaveToPlaylistActionID {
+						found = true
+					}
+				}
+			}
+		}
+		if found != (playlist != "") {
+			t.Errorf("with team playlist %q the save button shown = %v", playlist, found)
+		}
+	}
+}
+
+func TestPlaylistContains(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/v1/playlists/team/tracks", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("offset") == "0" {
+			w.Write([]byte(`{"items":[{"track":{"uri":"spotify:track:1"}},{"track":null}],"next":"https://api.spotify.com/v1/playlists/team/tracks?offset=100"}`))
+			return
+		}
+		w.Write([]byte(`{"items":[{"track":{"uri":"spotify:track:2"}}],"next":null}`))
+	})
+	client := newTestSpotifyClient(t, mux)
+
+	for uri, want := range map[string]bool{"spotify:track:1": true, "spotify:track:2": true, "spotify:track:3": false} {
+		got, err := client.PlaylistContains(context.Background(), "team", uri)
+		if err != nil {
+			t.Fatalf("PlaylistContains(%s) returned error: %v", uri, err)
+		}
+		if got != want {
+			t.Errorf("PlaylistContains(%s) = %v, want %v", uri, got, want)
+		}
+	}
+}
@@ -0,0 +1,137 @@
+package misc
+
+import (
+	"context"
+	"errors"
+	"log"
+	"time"
+
+	"github.com/slack-go/slack"
+)
+
+const (
+	// minPollInterval keeps a track that is about to end from making us poll in a tight loop
+	minPollInterval = 2 * time.Second
+	// pollTimeout bounds a single poll, the Spotify client retries within it
+	pollTimeout = 15 * time.Second
+)
+
+// SpotifyPoller keeps the dashboard messages in sync with what is playing between clicks.
+// It polls each dashboard when its track should end and at least every interval, stops
+// polling a dashboard after idleTimeout without activity and retires it after retention.
+type SpotifyPoller struct {
+	dashboards  *DashboardManager
+	interval    time.Duration
+	idleTimeout time.Duration
+	retention   time.Duration
+}
+
+// MySpotifyPoller polls the Dashboards, main replaces it with the configured intervals
+var MySpotifyPoller = NewSpotifyPoller(Dashboards, 10*time.Second, 30*time.Minute, 24*time.Hour)
+
+// NewSpotifyPoller creates a poller for the dashboards of a manager
+func NewSpotifyPoller(dashboards *DashboardManager, interval time.Duration, idleTimeout time.Duration, retention time.Duration) *SpotifyPoller {
+	return &SpotifyPoller{
+		dashboards:  dashboards,
+		interval:    interval,
+		idleTimeout: idleTimeout,
+		retention:   retention,
+	}
+}
+
+// Run polls until ctx is done
+func (p *SpotifyPoller) Run(ctx context.Context, client *slack.Client) {
+	due := map[*SpotifyDashboard]time.Time{}
+	for {
+		p.retireStale(ctx, client)
+
+		// Dashboards that went idle are dropped from the schedule, a click puts them back
+		// one interval later, the click itself already updated the message
+		next := time.Time{}
+		scheduled := map[*SpotifyDashboard]time.Time{}
+		for _, dashboard := range p.dashboards.All() {
+			if !dashboard.Active(p.idleTimeout) {
+				continue
+			}
+			at, ok := due[dashboard]
+			if !ok {
+				at = time.Now().Add(p.interval)
+			}
+			if !time.Now().Before(at) {
+				at = time.Now().Add(p.poll(ctx, client, dashboard))
+			}
+			scheduled[dashboard] = at
+			if next.IsZero() || at.Before(next) {
+				next = at
+			}
+		}
+		due = scheduled
+
+		// A poller without active dashboards only wakes up for a new click or dashboard
+		var timer *time.Timer
+		var elapsed <-chan time.Time
+		if !next.IsZero() {
+			timer = time.NewTimer(time.Until(next))
+			elapsed = timer.C
+		}
+
+		select {
+		case <-ctx.Done():
+		case <-p.dashboards.Woken():
+		case <-elapsed:
+		}
+		if timer != nil {
+			timer.Stop()
+		}
+		if ctx.Err() != nil {
+			return
+		}
+	}
+}
+
+// retireStale removes the controls of dashboards nobody used for the retention period
+func (p *SpotifyPoller) retireStale(ctx context.Context, client *slack.Client) {
+	for _, dashboard := range p.dashboards.Stale(p.retention) {
+		retireCtx, cancel := context.WithTimeout(ctx, pollTimeout)
+		err := dashboard.Retire(retireCtx, client, "This dashboard went to sleep. Run /spotify for a fresh one!")
+		cancel()
+		if err != nil {
+			log.Printf("failed to retire stale Spotify dashboard: %v", err)
+		}
+	}
+}
+
+// poll updates a dashboard once and returns how long to wait before its next poll
+func (p *SpotifyPoller) poll(ctx context.Context, client *slack.Client, dashboard *SpotifyDashboard) time.Duration {
+	ctx, cancel := context.WithTimeout(ctx, pollTimeout)
+	defer cancel()
+
+	remaining, err := dashboard.Poll(ctx, client)
+	var rateLimited *RateLimitError
+	var slackErr slack.SlackErrorResponse
+	switch {
+	case err == nil:
+		return nextPollDelay(remaining, p.interval)
+	case errors.As(err, &rateLimited):
+		return max(rateLimited.RetryAfter, p.interval)
+	case errors.Is(err, ErrNothingPlaying), errors.Is(err, ErrNoActiveDevice), errors.Is(err, ErrNotAuthenticated):
+		// Nothing to show, keep looking in case playback starts again
+	case errors.As(err, &slackErr) && (slackErr.Err == "message_not_found" || slackErr.Err == "channel_not_found"):
+		// The message or its channel was deleted, there is nothing left to keep in sync
+		p.dashboards.Remove(dashboard)
+	case ctx.Err() != nil && !errors.Is(ctx.Err(), context.DeadlineExceeded):
+		// Shutting down
+	default:
+		log.Printf("failed to poll Spotify dashboard: %v", err)
+	}
+	return p.interval
+}
+
+// nextPollDelay aims for just after the current track ends, but polls at least every
+// interval so skips and pauses made outside Slack show up too
+func nextPollDelay(remaining time.Duration, interval time.Duration) time.Duration {
+	if remaining <= 0 {
+		return interval
+	}
+	return min(max(remaining+500*time.Millisecond, minPollInterval), interval)
+}
@@ -0,0 +1,97 @@
+package misc
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/slack-go/slack"
+)
+
+func TestNextPollDelay(t *testing.T) {
+	tests := []struct {
+		remaining time.Duration
+		want      time.Duration
+	}{
+		{0, 10 * time.Second},
+		{time.Minute, 10 * time.Second},
+		{4 * time.Second, 4500 * time.Millisecond},
+		{100 * time.Millisecond, minPollInterval},
+	}
+	for _, tt := range tests {
+		if got := nextPollDelay(tt.remaining, 10*time.Second); got != tt.want {
+			t.Errorf("nextPollDelay(%s) = %s, want %s", tt.remaining, got, tt.want)
+		}
+	}
+}
+
+func TestSpotifyDashboardPoll(t *testing.T) {
+	progressMs := 60000
+	mux := http.NewServeMux()
+	mux.HandleFunc("/v1/me/player", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, `{"device":{"id":"speaker","is_active":true},"is_playing":true,"progress_ms":%d,"item":{"uri":"spotify:track:take-on-me","name":"Take On Me","duration_ms":225000,"artists":[{"name":"a-ha"}]}}`, progressMs)
+	})
+	spotify := newTestSpotifyClient(t, mux)
+	previous := Spotify
+	Spotify = spotify
+	t.Cleanup(func() { Spotify = previous })
+
+	updates := 0
+	slackServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/chat.update" {
+			updates++
+		}
+		w.Write([]byte(`{"ok":true,"channel":"C1","ts":"1.1"}`))
+	}))
+	t.Cleanup(slackServer.Close)
+	client := slack.New("xoxb-test", slack.OptionAPIURL(slackServer.URL+"/"))
+
+	var dashboard SpotifyDashboard
+	if _, err := dashboard.Poll(context.Background(), client); err != nil || updates != 0 {
+		t.Fatalf("polling an unposted dashboard: err=%v updates=%d", err, updates)
+	}
+
+	Shared.SetSpotifyTokenFor("poller-test", SpotifyToken{AccessToken: "a"})
+	dashboard.CreateSpotifyDashboard(CurrentPlayingTrackResponse{Song: "Hunting High and Low", IsPlaying: true}, "1.1", "C1", "poller-test", "/spotify", "morten")
+	if !dashboard.Active(time.Minute) {
+		t.Fatal("a new dashboard should be active")
+	}
+
+	remaining, err := dashboard.Poll(context.Background(), client)
+	if err != nil {
+		t.Fatalf("Poll returned error: %v", err)
+	}
+	if remaining != 165*time.Second {
+		t.Errorf("remaining = %s, want 2m45s", remaining)
+	}
+	if updates != 1 || dashboard.Song != "Take On Me" || dashboard.LastUserName != "morten" {
+		t.Errorf("after poll: updates=%d song=%q dj=%q", updates, dashboard.Song, dashboard.LastUserName)
+	}
+
+	// Same track and progress step, nothing to edit
+	progressMs = 65000
+	if _, err := dashboard.Poll(context.Background(), client); err != nil || updates != 1 {
+		t.Errorf("second poll: err=%v updates=%d", err, updates)
+	}
+
+	// Only the elapsed time moved on, into the next progress step. That is no activity,
+	// a dashboard left playing still goes idle.
+	progressMs = 72000
+	idleSince := time.Now().Add(-time.Hour)
+	dashboard.lastActivity = idleSince
+	if _, err := dashboard.Poll(context.Background(), client); err != nil || updates != 2 {
+		t.Errorf("poll after playback moved on: err=%v updates=%d", err, updates)
+	}
+	if !dashboard.lastActivity.Equal(idleSince) {
+		t.Errorf("redrawing the progress bar moved lastActivity to %s", dashboard.lastActivity)
+	}
+
+	// A click is shown even when it changed nothing the poll compares, e.g. a seek within the step
+	progressMs = 74000
+	if _, err := dashboard.AutoUpdateCurrentSpotifyDashboard(context.Background(), client, "seek", "sigrid"); err != nil || updates != 3 {
+		t.Errorf("click: err=%v updates=%d", err, updates)
+	}
+}
@@ -0,0 +1,166 @@
+package misc
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/slack-go/slack"
+)
+
+// queuePageSize is how many queued tracks one page of /queue shows
+const queuePageSize = 5
+
+// The /queue pagination buttons
+const (
+	QueuePreviousActionID = "queue_previous"
+	QueueNextActionID     = "queue_next"
+)
+
+// UserQueue is what plays now and what plays after it
+type UserQueue struct {
+	CurrentlyPlaying *UserQueueItem
+	Items            []UserQueueItem
+	ProgressMs       int // how far into CurrentlyPlaying playback is
+}
+
+// RemainingMs returns how long until everything queued has played
+func (q UserQueue) RemainingMs() int {
+	remaining := 0
+	if q.CurrentlyPlaying != nil {
+		remaining = max(q.CurrentlyPlaying.DurationMs-q.ProgressMs, 0)
+	}
+	for _, item := range q.Items {
+		remaining += item.DurationMs
+	}
+	return remaining
+}
+
+// Pages returns how many pages the queue takes, an empty queue still has one
+func (q UserQueue) Pages() int {
+	return max((len(q.Items)+queuePageSize-1)/queuePageSize, 1)
+}
+
+func newUserQueueItem(track SpotifyTrack) UserQueueItem {
+	return UserQueueItem{
+		URI:        track.URI,
+		AlbumLogo:  track.Album.ImageURL(),
+		SongTitle:  track.Name,
+		Artist:     track.FirstArtist(),
+		Duration:   FormatDuration(track.DurationMs),
+		DurationMs: track.DurationMs,
+	}
+}
+
+// CurrentQueue fetches the queue along with how far the current track has played,
+// waking the default device if nothing is active
+func (c *SpotifyClient) CurrentQueue(ctx context.Context) (UserQueue, error) {
+	// The player state tells nothing playing apart from no active device, the queue endpoint doesn't
+	cpt, err := c.GetCurrentPlayingTrackOrWake(ctx)
+	if err != nil {
+		return UserQueue{}, fmt.Errorf("GetCurrentPlayingTrackOrWake failed with error: %w", err)
+	}
+	queue, err := c.GetUserQueue(ctx)
+	if err != nil {
+		return UserQueue{}, fmt.Errorf("GetUserQueue failed with error: %w", err)
+	}
+	queue.ProgressMs = cpt.ProgressMs
+	return queue, nil
+}
+
+// QueuePage is the value of the /queue pagination buttons: the page they lead to and
+// the account whose queue is shown, so anyone clicking pages through the same queue
+type QueuePage struct {
+	Page       int    `json:"page"`
+	AccountKey string `json:"account_key"`
+}
+
+// QueuePageOf decodes the page a /queue pagination button leads to
+func QueuePageOf(interaction slack.InteractionCallback) (QueuePage, error) {
+	actions := interaction.ActionCallback.BlockActions
+	if len(actions) == 0 || (actions[0].ActionID != QueuePreviousActionID && actions[0].ActionID != QueueNextActionID) {
+		return QueuePage{}, fmt.Errorf("not a queue pagination button")
+	}
+	var page QueuePage
+	if err := json.Unmarshal([]byte(actions[0].Value), &page); err != nil {
+		return QueuePage{}, fmt.Errorf("failed to decode queue page: %w", err)
+	}
+	return page, nil
+}
+
+// BuildQueueAttachment renders page (counting from 0) of the queue of accountKey,
+// with the playing track on top and buttons to the neighbouring pages
+func BuildQueueAttachment(queue UserQueue, page int, accountKey string) (slack.Attachment, error) {
+	page = min(max(page, 0), queue.Pages()-1)
+
+	headerText := slack.NewTextBlockObject(slack.MarkdownType, "*🎶 Next in the queue 🎶*", false, false)
+	blocks := []slack.Block{slack.NewSectionBlock(headerText, nil, nil)}
+
+	if current := queue.CurrentlyPlaying; current != nil {
+		currentText := slack.NewTextBlockObject(slack.MarkdownType,
+			fmt.Sprintf("*▶️ Now playing*\n*%s*\n%s\n%s left", current.SongTitle, current.Artist, FormatDuration(max(current.DurationMs-queue.ProgressMs, 0))),
+			false, false)
+		blocks = append(blocks, slack.NewSectionBlock(currentText, nil, queueItemAccessory(*current), slack.SectionBlockOptionBlockID("now_playing")))
+		blocks = append(blocks, slack.NewDividerBlock())
+	}
+
+	if len(queue.Items) == 0 {
+		emptyText := slack.NewTextBlockObject(slack.MarkdownType, "Nothing is queued. Add something with `/queue-add`!", false, false)
+		blocks = append(blocks, slack.NewSectionBlock(emptyText, nil, nil, slack.SectionBlockOptionBlockID("empty")))
+	}
+
+	start := page * queuePageSize
+	end := min(start+queuePageSize, len(queue.Items))
+	for i, item := range queue.Items[start:end] {
+		position := start + i + 1 // Count from 1 instead of 0
+		// Create the section title dynamically with the position in the queue
+		sectionTitle := slack.NewTextBlockObject(slack.PlainTextType, fmt.Sprintf("Song #%d", position), false, false)
+		songText := slack.NewTextBlockObject(slack.MarkdownType, fmt.Sprintf("*%s*\n%s\n%s", item.SongTitle, item.Artist, item.Duration), false, false)
+		blocks = append(blocks, slack.NewSectionBlock(sectionTitle, []*slack.TextBlockObject{songText}, queueItemAccessory(item)))
+	}
+
+	summary := fmt.Sprintf("⏱ %d queued · %s until the queue runs out", len(queue.Items), FormatDuration(queue.RemainingMs()))
+	if queue.Pages() > 1 {
+		summary += fmt.Sprintf(" · Page %d of %d", page+1, queue.Pages())
+	}
+	blocks = append(blocks, slack.NewContextBlock("queue_summary", slack.NewTextBlockObject(slack.MarkdownType, summary, false, false)))
+
+	var buttons []slack.BlockElement
+	if page > 0 {
+		button, err := queuePageButton(QueuePreviousActionID, "◀️ Previous", QueuePage{Page: page - 1, AccountKey: accountKey})
+		if err != nil {
+			return slack.Attachment{}, err
+		}
+		buttons = append(buttons, button)
+	}
+	if page < queue.Pages()-1 {
+		button, err := queuePageButton(QueueNextActionID, "Next ▶️", QueuePage{Page: page + 1, AccountKey: accountKey})
+		if err != nil {
+			return slack.Attachment{}, err
+		}
+		buttons = append(buttons, button)
+	}
+	if len(buttons) > 0 {
+		blocks = append(blocks, slack.NewActionBlock("queue_pages", buttons...))
+	}
+
+	return slack.Attachment{
+		Blocks: slack.Blocks{BlockSet: blocks},
+	}, nil
+}
+
+// queueItemAccessory shows the album art of a queue item, if Spotify has any
+func queueItemAccessory(item UserQueueItem) *slack.Accessory {
+	if item.AlbumLogo == "" {
+		return nil
+	}
+	return slack.NewAccessory(slack.NewImageBlockElement(item.AlbumLogo, "album logo"))
+}
+
+func queuePageButton(actionID string, text string, page QueuePage) (*slack.ButtonBlockElement, error) {
+	value, err := json.Marshal(page)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode queue page: %w", err)
+	}
+	return slack.NewButtonBlockElement(actionID, string(value), slack.NewTextBlockObject(slack.PlainTextType, text, false, false)), nil
+}
@@ -0,0 +1,91 @@
+package misc
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/slack-go/slack"
+)
+
+func TestBuildQueueAttachment(t *testing.T) {
+	item := func(i int) UserQueueItem {
+		return UserQueueItem{SongTitle: fmt.Sprintf("Song %d", i), Duration: "1:00", DurationMs: 60000}
+	}
+	current := item(0)
+
+	tests := []struct {
+		name     string
+		queue    UserQueue
+		page     int
+		songs    int
+		buttons  []string
+		nowTitle bool
+	}{
+		{"empty", UserQueue{}, 0, 0, nil, false},
+		{"short", UserQueue{CurrentlyPlaying: &current, Items: []UserQueueItem{item(1), item(2)}}, 0, 2, nil, true},
+		{"first page", UserQueue{Items: make([]UserQueueItem, 12)}, 0, 5, []string{QueueNextActionID}, false},
+		{"middle page", UserQueue{Items: make([]UserQueueItem, 12)}, 1, 5, []string{QueuePreviousActionID, QueueNextActionID}, false},
+		{"last page", UserQueue{Items: make([]UserQueueItem, 12)}, 2, 2, []string{QueuePreviousActionID}, false},
+		{"page out of range", UserQueue{Items: make([]UserQueueItem, 12)}, 7, 2, []string{QueuePreviousActionID}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			attachment, err := BuildQueueAttachment(tt.queue, tt.page, "T1:U1")
+			if err != nil {
+				t.Fatalf("BuildQueueAttachment returned error: %v", err)
+			}
+
+			songs, nowPlaying := 0, false
+			var buttons []string
+			for _, block := range attachment.Blocks.BlockSet {
+				switch b := block.(type) {
+				case *slack.SectionBlock:
+					if len(b.Fields) > 0 {
+						songs++
+					}
+					nowPlaying = nowPlaying || b.BlockID == "now_playing"
+				case *slack.ActionBlock:
+					for _, element := range b.Elements.ElementSet {
+						buttons = append(buttons, element.(*slack.ButtonBlockElement).ActionID)
+					}
+				}
+			}
+			if songs != tt.songs || nowPlaying != tt.nowTitle || fmt.Sprint(buttons) != fmt.Sprint(tt.buttons) {
+				t.Errorf("got %d songs, now playing %v, buttons %v", songs, nowPlaying, buttons)
+			}
+		})
+	}
+}
+
+func TestUserQueueRemainingMs(t *testing.T) {
+	current := UserQueueItem{DurationMs: 200000}
+	queue := UserQueue{
+		CurrentlyPlaying: &current,
+		ProgressMs:       50000,
+		Items:            []UserQueueItem{{DurationMs: 60000}, {DurationMs: 90000}},
+	}
+	if got := queue.RemainingMs(); got != 300000 {
+		t.Errorf("RemainingMs = %d, want 300000", got)
+	}
+}
+
+func TestQueuePageOf(t *testing.T) {
+	attachment, err := BuildQueueAttachment(UserQueue{Items: make([]UserQueueItem, 6)}, 0, "T1:U1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	actions := attachment.Blocks.BlockSet[len(attachment.Blocks.BlockSet)-1].(*slack.ActionBlock)
+	next := actions.Elements.ElementSet[0].(*slack.ButtonBlockElement)
+
+	var interaction slack.InteractionCallback
+	interaction.ActionCallback.BlockActions = []*slack.BlockAction{{ActionID: next.ActionID, Value: next.Value}}
+	page, err := QueuePageOf(interaction)
+	if err != nil || page != (QueuePage{Page: 1, AccountKey: "T1:U1"}) {
+		t.Errorf("QueuePageOf = %+v, %v", page, err)
+	}
+
+	interaction.ActionCallback.BlockActions[0].ActionID = "skip_next"
+	if _, err := QueuePageOf(interaction); err == nil {
+		t.Error("expected an error for a button that is not a queue page")
+	}
+}
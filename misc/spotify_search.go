@@ -0,0 +1,198 @@
+package misc
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/slack-go/slack"
+)
+
+// The search modal and its typeahead menu
+const (
+	SpotifySearchCallbackID     = "spotify_search"
+	SpotifySearchSelectActionID = "spotify_search_select"
+)
+
+// searchResultsPerType keeps the typeahead short, Slack shows at most 100 options anyway
+const searchResultsPerType = 5
+
+// searchMinQueryLength is how many characters the user types before Slack asks us for options
+const searchMinQueryLength = 2
+
+// optionTextLimit is the longest option text Slack accepts
+const optionTextLimit = 75
+
+// searchTypes are the kinds of results the search modal offers
+var searchTypes = []string{"track", "album", "artist", "playlist"}
+
+// SearchContext travels in the search modal's private metadata: the channel results are
+// shared to and the Spotify account they are played on
+type SearchContext struct {
+	ChannelID  string `json:"channel_id"`
+	AccountKey string `json:"account_key"`
+}
+
+// SearchContextOf decodes the context of a search modal
+func SearchContextOf(view slack.View) (SearchContext, error) {
+	var search SearchContext
+	if err := json.Unmarshal([]byte(view.PrivateMetadata), &search); err != nil {
+		return SearchContext{}, fmt.Errorf("failed to decode search context: %w", err)
+	}
+	return search, nil
+}
+
+// BuildSpotifySearchModal builds the search modal. selected is the result the user picked,
+// nil until they pick one, and status reports the outcome of the last button they clicked.
+func BuildSpotifySearchModal(search SearchContext, selected *slack.OptionBlockObject, status string) (slack.ModalViewRequest, error) {
+	metadata, err := json.Marshal(search)
+	if err != nil {
+		return slack.ModalViewRequest{}, fmt.Errorf("failed to encode search context: %w", err)
+	}
+
+	minQueryLength := searchMinQueryLength
+	menu := slack.NewOptionsSelectBlockElement(slack.OptTypeExternal,
+		slack.NewTextBlockObject(slack.PlainTextType, "Songs, albums, artists, playlists", false, false),
+		SpotifySearchSelectActionID)
+	menu.MinQueryLength = &minQueryLength
+	menu.InitialOption = selected
+
+	searchText := slack.NewTextBlockObject(slack.MarkdownType, "*What do you want to hear?*", false, false)
+	blocks := []slack.Block{
+		slack.NewSectionBlock(searchText, nil, slack.NewAccessory(menu), slack.SectionBlockOptionBlockID("search")),
+	}
+
+	if selected != nil {
+		choice, err := json.Marshal(TrackChoice{URI: selected.Value, Name: selected.Text.Text})
+		if err != nil {
+			return slack.ModalViewRequest{}, fmt.Errorf("failed to encode search choice: %w", err)
+		}
+		selectedText := slack.NewTextBlockObject(slack.MarkdownType,
+			fmt.Sprintf("%s <%s|%s>", searchKindEmoji(selected.Value), SpotifyURL(selected.Value), selected.Text.Text), false, false)
+
+		buttons := []slack.BlockElement{
+			slack.NewButtonBlockElement("search_play", string(choice), slack.NewTextBlockObject(slack.PlainTextType, "▶️ Play now", false, false)),
+		}
+		// Spotify only queues tracks and episodes
+		if strings.HasPrefix(selected.Value, "spotify:track:") {
+			buttons = append(buttons, slack.NewButtonBlockElement("search_queue", string(choice), slack.NewTextBlockObject(slack.PlainTextType, "➕ Add to queue", false, false)))
+		}
+		buttons = append(buttons, slack.NewButtonBlockElement("search_share", string(choice), slack.NewTextBlockObject(slack.PlainTextType, "📣 Share to channel", false, false)))
+
+		blocks = append(blocks,
+			slack.NewDividerBlock(),
+			slack.NewSectionBlock(selectedText, nil, nil, slack.SectionBlockOptionBlockID("selected")),
+			slack.NewActionBlock("search_actions", buttons...),
+		)
+	}
+	if status != "" {
+		blocks = append(blocks, slack.NewContextBlock("status", slack.NewTextBlockObject(slack.MarkdownType, status, false, false)))
+	}
+
+	return slack.ModalViewRequest{
+		Type:            slack.VTModal,
+		CallbackID:      SpotifySearchCallbackID,
+		Title:           slack.NewTextBlockObject(slack.PlainTextType, "Search Spotify", false, false),
+		Close:           slack.NewTextBlockObject(slack.PlainTextType, "Done", false, false),
+		Blocks:          slack.Blocks{BlockSet: blocks},
+		PrivateMetadata: string(metadata),
+	}, nil
+}
+
+// SearchOptions searches Spotify for the typeahead of the search modal
+func (c *SpotifyClient) SearchOptions(ctx context.Context, query string) (slack.OptionGroupsResponse, error) {
+	results, err := c.Search(ctx, query, searchTypes, searchResultsPerType)
+	if err != nil {
+		return slack.OptionGroupsResponse{}, err
+	}
+	return BuildSearchOptionGroups(results), nil
+}
+
+// BuildSearchOptionGroups turns search results into the typeahead's option groups, one per
+// kind of result. The option value is the Spotify URI of the result.
+func BuildSearchOptionGroups(results SpotifySearchResponse) slack.OptionGroupsResponse {
+	var tracks, albums, artists, playlists []*slack.OptionBlockObject
+	for _, track := range results.Tracks.Items {
+		tracks = append(tracks, searchOption(track.URI, fmt.Sprintf("%s — %s", track.Name, strings.Join(track.ArtistNames(), ", "))))
+	}
+	for _, album := range results.Albums.Items {
+		text := album.Name
+		if len(album.Artists) > 0 {
+			text = fmt.Sprintf("%s — %s", album.Name, album.Artists[0].Name)
+		}
+		albums = append(albums, searchOption(album.URI, text))
+	}
+	for _, artist := range results.Artists.Items {
+		artists = append(artists, searchOption(artist.URI, artist.Name))
+	}
+	for _, playlist := range results.Playlists.Items {
+		if playlist == nil {
+			continue
+		}
+		text := playlist.Name
+		if playlist.Owner.DisplayName != "" {
+			text = fmt.Sprintf("%s — by %s", playlist.Name, playlist.Owner.DisplayName)
+		}
+		playlists = append(playlists, searchOption(playlist.URI, text))
+	}
+
+	var response slack.OptionGroupsResponse
+	for _, group := range []struct {
+		label   string
+		options []*slack.OptionBlockObject
+	}{
+		{"🎵 Tracks", tracks},
+		{"💿 Albums", albums},
+		{"🎤 Artists", artists},
+		{"📜 Playlists", playlists},
+	} {
+		// Slack rejects empty groups
+		if len(group.options) == 0 {
+			continue
+		}
+		label := slack.NewTextBlockObject(slack.PlainTextType, group.label, false, false)
+		response.OptionGroups = append(response.OptionGroups, slack.NewOptionGroupBlockElement(label, group.options...))
+	}
+	return response
+}
+
+func searchOption(uri string, text string) *slack.OptionBlockObject {
+	if runes := []rune(text); len(runes) > optionTextLimit {
+		text = string(runes[:optionTextLimit-1]) + "…"
+	}
+	return slack.NewOptionBlockObject(uri, slack.NewTextBlockObject(slack.PlainTextType, text, false, false), nil)
+}
+
+// searchKindEmoji marks a result with the emoji of its group
+func searchKindEmoji(uri string) string {
+	switch searchKind(uri) {
+	case "track":
+		return "🎵"
+	case "album":
+		return "💿"
+	case "artist":
+		return "🎤"
+	case "playlist":
+		return "📜"
+	}
+	return "🎧"
+}
+
+// searchKind returns the type part of a Spotify URI, e.g. "track" for spotify:track:<id>
+func searchKind(uri string) string {
+	parts := strings.Split(uri, ":")
+	if len(parts) != 3 || parts[0] != "spotify" {
+		return ""
+	}
+	return parts[1]
+}
+
+// SpotifyURL returns the open.spotify.com link of a Spotify URI, empty if it isn't one
+func SpotifyURL(uri string) string {
+	kind := searchKind(uri)
+	if kind == "" {
+		return ""
+	}
+	return fmt.Sprintf("https://open.spotify.com/%s/%s", kind, strings.Split(uri, ":")[2])
+}
@@ -0,0 +1,117 @@
+package misc
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/georgecpp/mimir/oauth"
+)
+
+// spotifyTokenExpiryMargin is how long before expiry an access token is refreshed proactively
+const spotifyTokenExpiryMargin = time.Minute
+
+var spotifyTokenHTTPClient = &http.Client{Timeout: 10 * time.Second}
+
+// SpotifyToken is the full token set returned by the Spotify accounts service
+type SpotifyToken struct {
+	AccessToken  string    `json:"access_token"`
+	TokenType    string    `json:"token_type"`
+	Scope        string    `json:"scope"`
+	RefreshToken string    `json:"refresh_token"`
+	ExpiresAt    time.Time `json:"expires_at"`
+}
+
+// Expired reports whether the access token is expired or about to expire
+func (t SpotifyToken) Expired() bool {
+	if t.ExpiresAt.IsZero() {
+		return false
+	}
+	return time.Now().Add(spotifyTokenExpiryMargin).After(t.ExpiresAt)
+}
+
+// spotifyTokenResponse is the raw body of a token endpoint response
+type spotifyTokenResponse struct {
+	AccessToken  string `json:"access_token"`
+	TokenType    string `json:"token_type"`
+	Scope        string `json:"scope"`
+	ExpiresIn    int    `json:"expires_in"`
+	RefreshToken string `json:"refresh_token"`
+}
+
+// ExchangeSpotifyCode trades an authorization code for a token set.
+// codeVerifier is the PKCE verifier of the login, empty when PKCE is disabled.
+func ExchangeSpotifyCode(ctx context.Context, config Config, code string, codeVerifier string) (SpotifyToken, error) {
+	return requestSpotifyToken(ctx, config, oauth.AuthorizationCodeForm(code, config.SpotifyRedirectUri, codeVerifier))
+}
+
+// RefreshSpotifyToken uses a refresh token to obtain a new access token.
+// Spotify may omit the refresh token in the response, in which case the old one is kept.
+func RefreshSpotifyToken(ctx context.Context, config Config, refreshToken string) (SpotifyToken, error) {
+	token, err := requestSpotifyToken(ctx, config, oauth.RefreshTokenForm(refreshToken))
+	if err != nil {
+		return SpotifyToken{}, err
+	}
+	if token.RefreshToken == "" {
+		token.RefreshToken = refreshToken
+	}
+	return token, nil
+}
+
+// NewSpotifyTokenRefresher returns a refresher bound to the given config, for use with SharedData
+func NewSpotifyTokenRefresher(config Config) func(ctx context.Context, refreshToken string) (SpotifyToken, error) {
+	return func(ctx context.Context, refreshToken string) (SpotifyToken, error) {
+		return RefreshSpotifyToken(ctx, config, refreshToken)
+	}
+}
+
+func requestSpotifyToken(ctx context.Context, config Config, form url.Values) (SpotifyToken, error) {
+	// PKCE clients identify themselves in the body and never send the secret
+	clientSecret := config.SpotifyClientSecret
+	if config.SpotifyUsePkce {
+		clientSecret = ""
+	}
+
+	req, err := oauth.NewTokenRequest(ctx, config.SpotifyAccessTokenUrl, config.SpotifyClientId, clientSecret, form)
+	if err != nil {
+		return SpotifyToken{}, err
+	}
+
+	resp, err := spotifyTokenHTTPClient.Do(req)
+	if err != nil {
+		return SpotifyToken{}, fmt.Errorf("token request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return SpotifyToken{}, fmt.Errorf("failed to read token response: %w", err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return SpotifyToken{}, fmt.Errorf("unexpected token response: %s %s", resp.Status, body)
+	}
+
+	var data spotifyTokenResponse
+	if err := json.Unmarshal(body, &data); err != nil {
+		return SpotifyToken{}, fmt.Errorf("failed to decode token response: %w", err)
+	}
+	if data.AccessToken == "" {
+		return SpotifyToken{}, fmt.Errorf("token response has no access_token")
+	}
+
+	token := SpotifyToken{
+		AccessToken:  data.AccessToken,
+		TokenType:    data.TokenType,
+		Scope:        data.Scope,
+		RefreshToken: data.RefreshToken,
+	}
+	if data.ExpiresIn > 0 {
+		token.ExpiresAt = time.Now().Add(time.Duration(data.ExpiresIn) * time.Second)
+	}
+	return token, nil
+}
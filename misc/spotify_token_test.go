@@ -0,0 +1,124 @@
+package misc
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func newTestTokenServer(t *testing.T, handler http.HandlerFunc) Config {
+	t.Helper()
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+	return Config{
+		SpotifyClientId:       "client",
+		SpotifyClientSecret:   "secret",
+		SpotifyRedirectUri:    "http://localhost:3000/",
+		SpotifyAccessTokenUrl: server.URL,
+	}
+}
+
+func TestExchangeSpotifyCodeKeepsRefreshToken(t *testing.T) {
+	config := newTestTokenServer(t, func(w http.ResponseWriter, r *http.Request) {
+		if err := r.ParseForm(); err != nil {
+			t.Fatal(err)
+		}
+		if r.Form.Get("grant_type") != "authorization_code" || r.Form.Get("code") != "abc" {
+			t.Errorf("unexpected form: %v", r.Form)
+		}
+		if user, pass, ok := r.BasicAuth(); !ok || user != "client" || pass != "secret" {
+			t.Errorf("unexpected basic auth: %q %q", user, pass)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"access_token":"access","refresh_token":"refresh","expires_in":3600}`))
+	})
+
+	token, err := ExchangeSpotifyCode(context.Background(), config, "abc", "")
+	if err != nil {
+		t.Fatalf("ExchangeSpotifyCode returned error: %v", err)
+	}
+	if token.AccessToken != "access" || token.RefreshToken != "refresh" {
+		t.Errorf("unexpected token: %+v", token)
+	}
+	if until := time.Until(token.ExpiresAt); until < 59*time.Minute || until > time.Hour {
+		t.Errorf("ExpiresAt is %v away, want about an hour", until)
+	}
+}
+
+func TestSharedDataRefreshesExpiredToken(t *testing.T) {
+	config := newTestTokenServer(t, func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		if r.Form.Get("grant_type") != "refresh_token" || r.Form.Get("refresh_token") != "refresh" {
+			t.Errorf("unexpected form: %v", r.Form)
+		}
+		w.Write([]byte(`{"access_token":"fresh","expires_in":3600}`))
+	})
+
+	var shared SharedData
+	shared.SetSpotifyTokenRefresher(NewSpotifyTokenRefresher(config))
+	shared.SetSpotifyToken(SpotifyToken{
+		AccessToken:  "stale",
+		RefreshToken: "refresh",
+		ExpiresAt:    time.Now().Add(10 * time.Second),
+	})
+
+	token, err := shared.Token(context.Background())
+	if err != nil {
+		t.Fatalf("Token returned error: %v", err)
+	}
+	if token != "fresh" {
+		t.Errorf("Token = %q, want fresh", token)
+	}
+	if got := shared.GetSpotifyToken().RefreshToken; got != "refresh" {
+		t.Errorf("refresh token = %q, want the old one kept", got)
+	}
+}
+
+func TestSpotifyClientRefreshesOnUnauthorized(t *testing.T) {
+	var refreshes int32
+	var shared SharedData
+	shared.SetSpotifyToken(SpotifyToken{AccessToken: "revoked", RefreshToken: "refresh"})
+	shared.SetSpotifyTokenRefresher(func(ctx context.Context, refreshToken string) (SpotifyToken, error) {
+		atomic.AddInt32(&refreshes, 1)
+		return SpotifyToken{AccessToken: "fresh", RefreshToken: refreshToken}, nil
+	})
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer fresh" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	client := NewSpotifyClient(server.URL, server.Client(), &shared)
+	if err := client.PauseTrack(context.Background()); err != nil {
+		t.Fatalf("PauseTrack returned error: %v", err)
+	}
+	if refreshes != 1 {
+		t.Errorf("refreshed %d times, want 1", refreshes)
+	}
+}
+
+func TestExchangeSpotifyCodeWithPKCE(t *testing.T) {
+	config := newTestTokenServer(t, func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		if _, _, ok := r.BasicAuth(); ok {
+			t.Error("PKCE exchange sent the client secret")
+		}
+		if r.Form.Get("client_id") != "client" || r.Form.Get("code_verifier") != "verifier" {
+			t.Errorf("unexpected form: %v", r.Form)
+		}
+		w.Write([]byte(`{"access_token":"access","refresh_token":"refresh","expires_in":3600}`))
+	})
+	config.SpotifyUsePkce = true
+	config.SpotifyClientSecret = ""
+
+	if _, err := ExchangeSpotifyCode(context.Background(), config, "abc", "verifier"); err != nil {
+		t.Fatalf("ExchangeSpotifyCode returned error: %v", err)
+	}
+}
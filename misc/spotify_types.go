@@ -0,0 +1,150 @@
+package misc
+
+// SpotifyImage is an image (album art, playlist cover) returned by the Spotify Web API
+type SpotifyImage struct {
+	URL    string `json:"url"`
+	Height int    `json:"height"`
+	Width  int    `json:"width"`
+}
+
+// SpotifyArtist is a simplified artist object
+type SpotifyArtist struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+	URI  string `json:"uri"`
+}
+
+// SpotifyAlbum is a simplified album object
+type SpotifyAlbum struct {
+	ID      string          `json:"id"`
+	Name    string          `json:"name"`
+	URI     string          `json:"uri"`
+	Images  []SpotifyImage  `json:"images"`
+	Artists []SpotifyArtist `json:"artists"`
+}
+
+// SpotifyTrack is a full track object
+type SpotifyTrack struct {
+	ID           string            `json:"id"`
+	Name         string            `json:"name"`
+	URI          string            `json:"uri"`
+	DurationMs   int               `json:"duration_ms"`
+	Explicit     bool              `json:"explicit"`
+	Artists      []SpotifyArtist   `json:"artists"`
+	Album        SpotifyAlbum      `json:"album"`
+	ExternalUrls map[string]string `json:"external_urls"`
+}
+
+// SpotifyDevice is a device the user can play music on
+type SpotifyDevice struct {
+	ID            string `json:"id"`
+	Name          string `json:"name"`
+	Type          string `json:"type"`
+	IsActive      bool   `json:"is_active"`
+	IsRestricted  bool   `json:"is_restricted"`
+	VolumePercent *int   `json:"volume_percent"`
+}
+
+// SpotifyDevicesResponse is the body of GET /v1/me/player/devices
+type SpotifyDevicesResponse struct {
+	Devices []SpotifyDevice `json:"devices"`
+}
+
+// SpotifyCurrentlyPlaying is the body of GET /v1/me/player/currently-playing
+type SpotifyCurrentlyPlaying struct {
+	IsPlaying            bool          `json:"is_playing"`
+	ProgressMs           int           `json:"progress_ms"`
+	CurrentlyPlayingType string        `json:"currently_playing_type"`
+	Item                 *SpotifyTrack `json:"item"`
+}
+
+// SpotifyPlaybackState is the body of GET /v1/me/player
+type SpotifyPlaybackState struct {
+	Device               SpotifyDevice `json:"device"`
+	ShuffleState         bool          `json:"shuffle_state"`
+	RepeatState          string        `json:"repeat_state"` // off, track or context
+	IsPlaying            bool          `json:"is_playing"`
+	ProgressMs           int           `json:"progress_ms"`
+	CurrentlyPlayingType string        `json:"currently_playing_type"`
+	Item                 *SpotifyTrack `json:"item"`
+}
+
+// SpotifyQueue is the body of GET /v1/me/player/queue
+type SpotifyQueue struct {
+	CurrentlyPlaying *SpotifyTrack  `json:"currently_playing"`
+	Queue            []SpotifyTrack `json:"queue"`
+}
+
+// SpotifyPlaylist is a simplified playlist object
+type SpotifyPlaylist struct {
+	ID     string         `json:"id"`
+	Name   string         `json:"name"`
+	URI    string         `json:"uri"`
+	Images []SpotifyImage `json:"images"`
+	Owner  struct {
+		DisplayName string `json:"display_name"`
+	} `json:"owner"`
+	Tracks struct {
+		Total int `json:"total"`
+	} `json:"tracks"`
+	ExternalURLs struct {
+		Spotify string `json:"spotify"`
+	} `json:"external_urls"`
+}
+
+// SpotifyPlaylistsResponse is a page of GET /v1/me/playlists
+type SpotifyPlaylistsResponse struct {
+	// Spotify returns null for playlists it cannot show, hence the pointers
+	Items []*SpotifyPlaylist `json:"items"`
+	Next  string             `json:"next"`
+}
+
+// SpotifySearchResponse is the body of GET /v1/search, only the requested types are filled in
+type SpotifySearchResponse struct {
+	Tracks struct {
+		Items []SpotifyTrack `json:"items"`
+	} `json:"tracks"`
+	Albums struct {
+		Items []SpotifyAlbum `json:"items"`
+	} `json:"albums"`
+	Artists struct {
+		Items []SpotifyArtist `json:"items"`
+	} `json:"artists"`
+	// Spotify returns null for playlists it cannot show, hence the pointers
+	Playlists struct {
+		Items []*SpotifyPlaylist `json:"items"`
+	} `json:"playlists"`
+}
+
+// ImageURL returns the medium sized album image, falling back to whatever is available
+func (a SpotifyAlbum) ImageURL() string {
+	switch {
+	case len(a.Images) > 1:
+		return a.Images[1].URL
+	case len(a.Images) == 1:
+		return a.Images[0].URL
+	}
+	return ""
+}
+
+// FirstArtist returns the name of the track's main artist
+func (t SpotifyTrack) FirstArtist() string {
+	if len(t.Artists) == 0 {
+		return ""
+	}
+	return t.Artists[0].Name
+}
+
+// ArtistNames returns the names of all the track's artists
+func (t SpotifyTrack) ArtistNames() []string {
+	names := make([]string, 0, len(t.Artists))
+	for _, artist := range t.Artists {
+		names = append(names, artist.Name)
+	}
+	return names
+}
+
+// URL returns the link that opens the track in Spotify
+func (t SpotifyTrack) URL() string {
+	return t.ExternalUrls["spotify"]
+}
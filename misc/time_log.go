@@ -0,0 +1,209 @@
+package misc
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// timeLog keeps values in the order of their time, forgetting them after a retention.
+// The audit trail and the play history are both built on one.
+type timeLog[T any] interface {
+	// append records value at time at and forgets the values older than the retention
+	append(at time.Time, value T) error
+	// newest visits the values since since, all if zero, newest first until visit returns false
+	newest(since time.Time, visit func(value T) bool) error
+	// between returns the values of [from, to), oldest first
+	between(from time.Time, to time.Time) ([]T, error)
+	close() error
+}
+
+// timedValue is a value of a memoryTimeLog and its time
+type timedValue[T any] struct {
+	at    time.Time
+	value T
+}
+
+// memoryTimeLog keeps a time log in memory, it is gone after a restart
+type memoryTimeLog[T any] struct {
+	values    []timedValue[T] // oldest first
+	retention time.Duration
+	limit     int
+	mutex     sync.Mutex
+}
+
+// newMemoryTimeLog creates an empty log that keeps values for retention and at most limit of them,
+// 0 lifts either bound
+func newMemoryTimeLog[T any](retention time.Duration, limit int) *memoryTimeLog[T] {
+	return &memoryTimeLog[T]{retention: retention, limit: limit}
+}
+
+func (l *memoryTimeLog[T]) append(at time.Time, value T) error {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	l.values = append(l.values, timedValue[T]{at, value})
+	drop := 0
+	if l.limit > 0 && len(l.values) > l.limit {
+		drop = len(l.values) - l.limit
+	}
+	if l.retention > 0 {
+		for drop < len(l.values) && time.Since(l.values[drop].at) > l.retention {
+			drop++
+		}
+	}
+	l.values = l.values[drop:]
+	return nil
+}
+
+func (l *memoryTimeLog[T]) newest(since time.Time, visit func(value T) bool) error {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	for i := len(l.values) - 1; i >= 0 && !l.values[i].at.Before(since); i-- {
+		if !visit(l.values[i].value) {
+			break
+		}
+	}
+	return nil
+}
+
+func (l *memoryTimeLog[T]) between(from time.Time, to time.Time) ([]T, error) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	var values []T
+	for _, v := range l.values {
+		if !v.at.Before(from) && v.at.Before(to) {
+			values = append(values, v.value)
+		}
+	}
+	return values, nil
+}
+
+func (l *memoryTimeLog[T]) close() error {
+	return nil
+}
+
+// boltTimeLog keeps a time log as JSON in a bucket of an embedded BoltDB database. Values are keyed
+// by time, so reads seek to the first one that is recent enough or stop at the first that is too old.
+// It needs its own file, BoltDB allows a single open handle per database.
+type boltTimeLog[T any] struct {
+	db        *bolt.DB
+	bucket    []byte
+	retention time.Duration
+}
+
+// openBoltTimeLog opens (or creates) the database at path with the log's bucket, keeping values for
+// retention, forever if 0
+func openBoltTimeLog[T any](path string, bucket []byte, retention time.Duration) (*boltTimeLog[T], error) {
+	db, err := bolt.Open(path, 0600, &bolt.Options{Timeout: time.Second})
+	if err != nil {
+		return nil, err
+	}
+	err = db.Update(func(tx *bolt.Tx) error {
+		_, err := tx.CreateBucketIfNotExists(bucket)
+		return err
+	})
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to create bucket %s: %w", bucket, err)
+	}
+	return &boltTimeLog[T]{db: db, bucket: bucket, retention: retention}, nil
+}
+
+// timeKey orders values by time, the sequence number keeps values of the same instant apart
+func timeKey(at time.Time, sequence uint64) []byte {
+	key := make([]byte, 16)
+	binary.BigEndian.PutUint64(key, uint64(at.UnixNano()))
+	binary.BigEndian.PutUint64(key[8:], sequence)
+	return key
+}
+
+func (l *boltTimeLog[T]) append(at time.Time, value T) error {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Errorf("failed to encode %s value: %w", l.bucket, err)
+	}
+	return l.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(l.bucket)
+		sequence, err := bucket.NextSequence()
+		if err != nil {
+			return err
+		}
+		if err := bucket.Put(timeKey(at, sequence), data); err != nil {
+			return err
+		}
+
+		if l.retention <= 0 {
+			return nil
+		}
+		expired := timeKey(time.Now().Add(-l.retention), 0)
+		cursor := bucket.Cursor()
+		for k, _ := cursor.First(); k != nil && bytes.Compare(k, expired) < 0; k, _ = cursor.First() {
+			if err := cursor.Delete(); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// decode reads a value stored by append
+func (l *boltTimeLog[T]) decode(data []byte) (T, error) {
+	var value T
+	if err := json.Unmarshal(data, &value); err != nil {
+		return value, fmt.Errorf("failed to decode %s value: %w", l.bucket, err)
+	}
+	return value, nil
+}
+
+func (l *boltTimeLog[T]) newest(since time.Time, visit func(value T) bool) error {
+	var first []byte
+	if !since.IsZero() {
+		first = timeKey(since, 0)
+	}
+	return l.db.View(func(tx *bolt.Tx) error {
+		cursor := tx.Bucket(l.bucket).Cursor()
+		for k, v := cursor.Last(); k != nil && bytes.Compare(k, first) >= 0; k, v = cursor.Prev() {
+			value, err := l.decode(v)
+			if err != nil {
+				return err
+			}
+			if !visit(value) {
+				return nil
+			}
+		}
+		return nil
+	})
+}
+
+func (l *boltTimeLog[T]) between(from time.Time, to time.Time) ([]T, error) {
+	var values []T
+	until := make([]byte, 8)
+	binary.BigEndian.PutUint64(until, uint64(to.UnixNano()))
+	err := l.db.View(func(tx *bolt.Tx) error {
+		cursor := tx.Bucket(l.bucket).Cursor()
+		for k, v := cursor.Seek(timeKey(from, 0)); k != nil && bytes.Compare(k[:8], until) < 0; k, v = cursor.Next() {
+			value, err := l.decode(v)
+			if err != nil {
+				return err
+			}
+			values = append(values, value)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return values, nil
+}
+
+func (l *boltTimeLog[T]) close() error {
+	return l.db.Close()
+}
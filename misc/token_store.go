@@ -0,0 +1,308 @@
+package misc
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// SharedSpotifyTokenKey is the store key of the workspace wide Spotify token
+const SharedSpotifyTokenKey = "shared"
+
+// TokenStore persists Spotify tokens so authorization survives restarts
+type TokenStore interface {
+	// Load returns every stored token keyed by the key it was saved under
+	Load() (map[string]SpotifyToken, error)
+	// Save stores or replaces the token under key
+	Save(key string, token SpotifyToken) error
+	// Delete removes the token stored under key, if any
+	Delete(key string) error
+	Close() error
+}
+
+// NewTokenStore builds the token store selected by config.TokenStoreType:
+// "file" for an encrypted JSON file, "bolt" for an embedded BoltDB database,
+// anything else keeps tokens in memory only
+func NewTokenStore(config Config) (TokenStore, error) {
+	switch config.TokenStoreType {
+	case "file":
+		return NewFileTokenStore(config.TokenStorePath, config.TokenStoreEncryptionKey)
+	case "bolt":
+		return NewBoltTokenStore(config.TokenStorePath, config.TokenStoreEncryptionKey)
+	case "", "memory":
+		return NewMemoryTokenStore(), nil
+	}
+	return nil, fmt.Errorf("unknown token store type: %s", config.TokenStoreType)
+}
+
+// MemoryTokenStore keeps tokens in memory, which is what the bot did before stores existed
+type MemoryTokenStore struct {
+	tokens map[string]SpotifyToken
+	mutex  sync.Mutex
+}
+
+// NewMemoryTokenStore creates an empty in-memory store
+func NewMemoryTokenStore() *MemoryTokenStore {
+	return &MemoryTokenStore{tokens: map[string]SpotifyToken{}}
+}
+
+func (s *MemoryTokenStore) Load() (map[string]SpotifyToken, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	tokens := make(map[string]SpotifyToken, len(s.tokens))
+	for key, token := range s.tokens {
+		tokens[key] = token
+	}
+	return tokens, nil
+}
+
+func (s *MemoryTokenStore) Save(key string, token SpotifyToken) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.tokens[key] = token
+	return nil
+}
+
+func (s *MemoryTokenStore) Delete(key string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	delete(s.tokens, key)
+	return nil
+}
+
+func (s *MemoryTokenStore) Close() error {
+	return nil
+}
+
+// FileTokenStore keeps all tokens in a single AES-GCM encrypted JSON file
+type FileTokenStore struct {
+	path   string
+	sealer *tokenSealer
+	mutex  sync.Mutex
+}
+
+// NewFileTokenStore creates a store backed by the file at path, encrypted with encryptionKey
+func NewFileTokenStore(path string, encryptionKey string) (*FileTokenStore, error) {
+	if path == "" {
+		return nil, fmt.Errorf("token store path is not configured")
+	}
+	sealer, err := newTokenSealer(encryptionKey)
+	if err != nil {
+		return nil, err
+	}
+	return &FileTokenStore{path: path, sealer: sealer}, nil
+}
+
+func (s *FileTokenStore) Load() (map[string]SpotifyToken, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.load()
+}
+
+func (s *FileTokenStore) Save(key string, token SpotifyToken) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	tokens, err := s.load()
+	if err != nil {
+		return err
+	}
+	tokens[key] = token
+	return s.write(tokens)
+}
+
+func (s *FileTokenStore) Delete(key string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	tokens, err := s.load()
+	if err != nil {
+		return err
+	}
+	delete(tokens, key)
+	return s.write(tokens)
+}
+
+func (s *FileTokenStore) Close() error {
+	return nil
+}
+
+func (s *FileTokenStore) load() (map[string]SpotifyToken, error) {
+	tokens := map[string]SpotifyToken{}
+
+	sealed, err := os.ReadFile(s.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return tokens, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read token store: %w", err)
+	}
+
+	plain, err := s.sealer.open(sealed)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt token store: %w", err)
+	}
+	if err := json.Unmarshal(plain, &tokens); err != nil {
+		return nil, fmt.Errorf("failed to decode token store: %w", err)
+	}
+	return tokens, nil
+}
+
+// write replaces the file atomically so a crash never leaves a half written store behind
+func (s *FileTokenStore) write(tokens map[string]SpotifyToken) error {
+	plain, err := json.Marshal(tokens)
+	if err != nil {
+		return fmt.Errorf("failed to encode token store: %w", err)
+	}
+	sealed, err := s.sealer.seal(plain)
+	if err != nil {
+		return fmt.Errorf("failed to encrypt token store: %w", err)
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(s.path), filepath.Base(s.path)+".tmp*")
+	if err != nil {
+		return fmt.Errorf("failed to create token store: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(sealed); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write token store: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write token store: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), s.path); err != nil {
+		return fmt.Errorf("failed to replace token store: %w", err)
+	}
+	return nil
+}
+
+var spotifyTokensBucket = []byte("spotify_tokens")
+
+// BoltTokenStore keeps tokens in an embedded BoltDB database, one encrypted record per key
+type BoltTokenStore struct {
+	db     *bolt.DB
+	sealer *tokenSealer
+}
+
+// NewBoltTokenStore opens (or creates) the database at path
+func NewBoltTokenStore(path string, encryptionKey string) (*BoltTokenStore, error) {
+	if path == "" {
+		return nil, fmt.Errorf("token store path is not configured")
+	}
+	sealer, err := newTokenSealer(encryptionKey)
+	if err != nil {
+		return nil, err
+	}
+
+	db, err := bolt.Open(path, 0600, &bolt.Options{Timeout: time.Second})
+	if err != nil {
+		return nil, fmt.Errorf("failed to open token store: %w", err)
+	}
+	err = db.Update(func(tx *bolt.Tx) error {
+		_, err := tx.CreateBucketIfNotExists(spotifyTokensBucket)
+		return err
+	})
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to create token bucket: %w", err)
+	}
+	return &BoltTokenStore{db: db, sealer: sealer}, nil
+}
+
+func (s *BoltTokenStore) Load() (map[string]SpotifyToken, error) {
+	tokens := map[string]SpotifyToken{}
+	err := s.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(spotifyTokensBucket).ForEach(func(k, v []byte) error {
+			plain, err := s.sealer.open(v)
+			if err != nil {
+				return fmt.Errorf("failed to decrypt token %s: %w", k, err)
+			}
+			var token SpotifyToken
+			if err := json.Unmarshal(plain, &token); err != nil {
+				return fmt.Errorf("failed to decode token %s: %w", k, err)
+			}
+			tokens[string(k)] = token
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return tokens, nil
+}
+
+func (s *BoltTokenStore) Save(key string, token SpotifyToken) error {
+	plain, err := json.Marshal(token)
+	if err != nil {
+		return fmt.Errorf("failed to encode token: %w", err)
+	}
+	sealed, err := s.sealer.seal(plain)
+	if err != nil {
+		return fmt.Errorf("failed to encrypt token: %w", err)
+	}
+	return s.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(spotifyTokensBucket).Put([]byte(key), sealed)
+	})
+}
+
+func (s *BoltTokenStore) Delete(key string) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(spotifyTokensBucket).Delete([]byte(key))
+	})
+}
+
+func (s *BoltTokenStore) Close() error {
+	return s.db.Close()
+}
+
+// tokenSealer encrypts tokens at rest with AES-256-GCM
+type tokenSealer struct {
+	aead cipher.AEAD
+}
+
+// newTokenSealer derives a 256 bit key from the configured secret
+func newTokenSealer(encryptionKey string) (*tokenSealer, error) {
+	if encryptionKey == "" {
+		return nil, fmt.Errorf("token store encryption key is not configured")
+	}
+	key := sha256.Sum256([]byte(encryptionKey))
+	block, err := aes.NewCipher(key[:])
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	return &tokenSealer{aead: aead}, nil
+}
+
+// seal returns nonce || ciphertext
+func (s *tokenSealer) seal(plain []byte) ([]byte, error) {
+	nonce := make([]byte, s.aead.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, err
+	}
+	return s.aead.Seal(nonce, nonce, plain, nil), nil
+}
+
+func (s *tokenSealer) open(sealed []byte) ([]byte, error) {
+	if len(sealed) < s.aead.NonceSize() {
+		return nil, fmt.Errorf("ciphertext too short")
+	}
+	nonce, ciphertext := sealed[:s.aead.NonceSize()], sealed[s.aead.NonceSize():]
+	return s.aead.Open(nil, nonce, ciphertext, nil)
+}
@@ -0,0 +1,80 @@
+package misc
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestTokenStoresRoundTrip(t *testing.T) {
+	dir := t.TempDir()
+	token := SpotifyToken{
+		AccessToken:  "access-secret",
+		RefreshToken: "refresh-secret",
+		ExpiresAt:    time.Now().Add(time.Hour).Round(time.Second),
+	}
+
+	stores := map[string]func(path string) (TokenStore, error){
+		"file": func(path string) (TokenStore, error) { return NewFileTokenStore(path, "passphrase") },
+		"bolt": func(path string) (TokenStore, error) { return NewBoltTokenStore(path, "passphrase") },
+	}
+	for name, open := range stores {
+		t.Run(name, func(t *testing.T) {
+			path := filepath.Join(dir, name+".db")
+
+			store, err := open(path)
+			if err != nil {
+				t.Fatalf("open: %v", err)
+			}
+			if err := store.Save(SharedSpotifyTokenKey, token); err != nil {
+				t.Fatalf("Save: %v", err)
+			}
+			store.Close()
+
+			raw, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if bytes.Contains(raw, []byte("refresh-secret")) {
+				t.Error("token is stored in plain text")
+			}
+
+			// A new store over the same path stands in for a restart
+			store, err = open(path)
+			if err != nil {
+				t.Fatalf("reopen: %v", err)
+			}
+			defer store.Close()
+			tokens, err := store.Load()
+			if err != nil {
+				t.Fatalf("Load: %v", err)
+			}
+			got := tokens[SharedSpotifyTokenKey]
+			if got.AccessToken != token.AccessToken || got.RefreshToken != token.RefreshToken || !got.ExpiresAt.Equal(token.ExpiresAt) {
+				t.Errorf("loaded %+v, want %+v", got, token)
+			}
+
+			if err := store.Delete(SharedSpotifyTokenKey); err != nil {
+				t.Fatalf("Delete: %v", err)
+			}
+			if tokens, _ := store.Load(); len(tokens) != 0 {
+				t.Errorf("tokens left after Delete: %v", tokens)
+			}
+		})
+	}
+}
+
+func TestFileTokenStoreRejectsWrongKey(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "tokens")
+	store, _ := NewFileTokenStore(path, "right")
+	if err := store.Save(SharedSpotifyTokenKey, SpotifyToken{AccessToken: "a"}); err != nil {
+		t.Fatal(err)
+	}
+
+	other, _ := NewFileTokenStore(path, "wrong")
+	if _, err := other.Load(); err == nil {
+		t.Error("expected an error loading with the wrong key")
+	}
+}
@@ -0,0 +1,100 @@
+// Package oauth builds OAuth 2.0 authorization code flow URLs and token requests
+// with proper URL encoding, shared by the auth server and the Slack commands.
+package oauth
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// AuthorizeParams are the query parameters of an authorization request
+type AuthorizeParams struct {
+	ClientID      string
+	RedirectURI   string
+	Scopes        []string
+	State         string
+	CodeChallenge string // S256 PKCE challenge, omitted when empty
+}
+
+// AuthorizeURL appends the authorization request parameters to baseURL,
+// keeping any query parameters baseURL already has
+func AuthorizeURL(baseURL string, params AuthorizeParams) (string, error) {
+	u, err := url.Parse(baseURL)
+	if err != nil {
+		return "", fmt.Errorf("invalid authorize url: %w", err)
+	}
+
+	query := u.Query()
+	query.Set("response_type", "code")
+	query.Set("client_id", params.ClientID)
+	query.Set("redirect_uri", params.RedirectURI)
+	query.Set("state", params.State)
+	if len(params.Scopes) > 0 {
+		query.Set("scope", strings.Join(params.Scopes, " "))
+	}
+	if params.CodeChallenge != "" {
+		query.Set("code_challenge_method", "S256")
+		query.Set("code_challenge", params.CodeChallenge)
+	}
+
+	// Encode escapes spaces as '+', spell them %20 so scope lists survive any decoder.
+	// Literal plus signs are already escaped as %2B, so this cannot change a value.
+	u.RawQuery = strings.ReplaceAll(query.Encode(), "+", "%20")
+	return u.String(), nil
+}
+
+// ParseScopes splits a scope list separated by spaces and/or commas
+func ParseScopes(scopes string) []string {
+	return strings.FieldsFunc(scopes, func(r rune) bool {
+		return r == ' ' || r == ',' || r == '\t' || r == '\n'
+	})
+}
+
+// AuthorizationCodeForm is the token request body that trades a code for tokens.
+// codeVerifier is the PKCE verifier, left out when empty.
+func AuthorizationCodeForm(code string, redirectURI string, codeVerifier string) url.Values {
+	form := url.Values{}
+	form.Set("grant_type", "authorization_code")
+	form.Set("code", code)
+	form.Set("redirect_uri", redirectURI)
+	if codeVerifier != "" {
+		form.Set("code_verifier", codeVerifier)
+	}
+	return form
+}
+
+// RefreshTokenForm is the token request body that renews an access token
+func RefreshTokenForm(refreshToken string) url.Values {
+	form := url.Values{}
+	form.Set("grant_type", "refresh_token")
+	form.Set("refresh_token", refreshToken)
+	return form
+}
+
+// NewTokenRequest builds a form encoded POST to tokenURL. Confidential clients
+// authenticate with HTTP basic auth, public (PKCE) clients pass an empty
+// clientSecret and send their client_id in the body instead.
+func NewTokenRequest(ctx context.Context, tokenURL string, clientID string, clientSecret string, form url.Values) (*http.Request, error) {
+	body := url.Values{}
+	for key, values := range form {
+		body[key] = append([]string(nil), values...)
+	}
+	if clientSecret == "" {
+		body.Set("client_id", clientID)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, tokenURL, strings.NewReader(body.Encode()))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create token request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	if clientSecret != "" {
+		credentials := url.QueryEscape(clientID) + ":" + url.QueryEscape(clientSecret)
+		req.Header.Set("Authorization", "Basic "+base64.StdEncoding.EncodeToString([]byte(credentials)))
+	}
+	return req, nil
+}
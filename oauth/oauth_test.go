@@ -0,0 +1,204 @@
+package oauth
+
+import (
+	"context"
+	"io"
+	"net/url"
+	"reflect"
+	"testing"
+)
+
+func TestAuthorizeURL(t *testing.T) {
+	tests := []struct {
+		name    string
+		baseURL string
+		params  AuthorizeParams
+		want    map[string]string
+	}{
+		{
+			name:    "scope list is space separated",
+			baseURL: "https://accounts.spotify.com/authorize",
+			params: AuthorizeParams{
+				ClientID:    "client",
+				RedirectURI: "http://localhost:3000/",
+				Scopes:      []string{"user-read-playback-state", "user-modify-playback-state"},
+				State:       "abc",
+			},
+			want: map[string]string{
+				"response_type": "code",
+				"client_id":     "client",
+				"redirect_uri":  "http://localhost:3000/",
+				"scope":         "user-read-playback-state user-modify-playback-state",
+				"state":         "abc",
+			},
+		},
+		{
+			name:    "redirect uri with its own query string",
+			baseURL: "https://accounts.spotify.com/authorize",
+			params: AuthorizeParams{
+				ClientID:    "client",
+				RedirectURI: "https://bot.example.com/callback?team=T1&mode=a b",
+				State:       "abc",
+			},
+			want: map[string]string{
+				"response_type": "code",
+				"client_id":     "client",
+				"redirect_uri":  "https://bot.example.com/callback?team=T1&mode=a b",
+				"state":         "abc",
+			},
+		},
+		{
+			name:    "special characters in state",
+			baseURL: "https://accounts.spotify.com/authorize",
+			params: AuthorizeParams{
+				ClientID:    "client",
+				RedirectURI: "http://localhost:3000/",
+				State:       "a+b/c=d&e==",
+			},
+			want: map[string]string{
+				"response_type": "code",
+				"client_id":     "client",
+				"redirect_uri":  "http://localhost:3000/",
+				"state":         "a+b/c=d&e==",
+			},
+		},
+		{
+			name:    "base url query is kept and PKCE challenge added",
+			baseURL: "https://accounts.example.com/authorize?show_dialog=true",
+			params: AuthorizeParams{
+				ClientID:      "client",
+				RedirectURI:   "http://localhost:3000/",
+				State:         "abc",
+				CodeChallenge: "E9Melhoa2OwvFrEMTJguCHaoeK1t8URWbuGJSstw-cM",
+			},
+			want: map[string]string{
+				"show_dialog":           "true",
+				"response_type":         "code",
+				"client_id":             "client",
+				"redirect_uri":          "http://localhost:3000/",
+				"state":                 "abc",
+				"code_challenge_method": "S256",
+				"code_challenge":        "E9Melhoa2OwvFrEMTJguCHaoeK1t8URWbuGJSstw-cM",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			raw, err := AuthorizeURL(tt.baseURL, tt.params)
+			if err != nil {
+				t.Fatalf("AuthorizeURL returned error: %v", err)
+			}
+			u, err := url.Parse(raw)
+			if err != nil {
+				t.Fatalf("AuthorizeURL built an unparsable url %q: %v", raw, err)
+			}
+
+			got := map[string]string{}
+			for key, values := range u.Query() {
+				if len(values) != 1 {
+					t.Errorf("parameter %s appears %d times", key, len(values))
+				}
+				got[key] = values[0]
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("query of %q\n got %v\nwant %v", raw, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAuthorizeURLRejectsInvalidBase(t *testing.T) {
+	if _, err := AuthorizeURL("://bad", AuthorizeParams{}); err == nil {
+		t.Error("expected an error for an invalid base url")
+	}
+}
+
+func TestParseScopes(t *testing.T) {
+	tests := []struct {
+		in   string
+		want []string
+	}{
+		{"", []string{}},
+		{"user-read-private", []string{"user-read-private"}},
+		{"a b  c", []string{"a", "b", "c"}},
+		{"a,b, c", []string{"a", "b", "c"}},
+	}
+	for _, tt := range tests {
+		got := ParseScopes(tt.in)
+		if len(got) == 0 && len(tt.want) == 0 {
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("ParseScopes(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestNewTokenRequest(t *testing.T) {
+	tests := []struct {
+		name         string
+		clientSecret string
+		form         url.Values
+		wantForm     url.Values
+		wantBasic    bool
+	}{
+		{
+			name:         "confidential client uses basic auth",
+			clientSecret: "secret",
+			form:         AuthorizationCodeForm("code&x=1", "http://localhost:3000/?a=b", ""),
+			wantForm: url.Values{
+				"grant_type":   {"authorization_code"},
+				"code":         {"code&x=1"},
+				"redirect_uri": {"http://localhost:3000/?a=b"},
+			},
+			wantBasic: true,
+		},
+		{
+			name: "public client sends client_id and verifier",
+			form: AuthorizationCodeForm("code", "http://localhost:3000/", "verifier"),
+			wantForm: url.Values{
+				"grant_type":    {"authorization_code"},
+				"code":          {"code"},
+				"redirect_uri":  {"http://localhost:3000/"},
+				"code_verifier": {"verifier"},
+				"client_id":     {"client"},
+			},
+		},
+		{
+			name:         "refresh",
+			clientSecret: "secret",
+			form:         RefreshTokenForm("r+t/="),
+			wantForm: url.Values{
+				"grant_type":    {"refresh_token"},
+				"refresh_token": {"r+t/="},
+			},
+			wantBasic: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, err := NewTokenRequest(context.Background(), "https://accounts.example.com/api/token", "client", tt.clientSecret, tt.form)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if ct := req.Header.Get("Content-Type"); ct != "application/x-www-form-urlencoded" {
+				t.Errorf("Content-Type = %q", ct)
+			}
+			user, pass, ok := req.BasicAuth()
+			if ok != tt.wantBasic || (ok && (user != "client" || pass != tt.clientSecret)) {
+				t.Errorf("basic auth = %q %q %v, want %v", user, pass, ok, tt.wantBasic)
+			}
+
+			body, _ := io.ReadAll(req.Body)
+			got, err := url.ParseQuery(string(body))
+			if err != nil {
+				t.Fatalf("unparsable body %q: %v", body, err)
+			}
+			if !reflect.DeepEqual(got, tt.wantForm) {
+				t.Errorf("body\n got %v\nwant %v", got, tt.wantForm)
+			}
+		})
+	}
+}